@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"ogimg/cmd/ogimg/wire"
+	"ogimg/internal/model"
+	"ogimg/pkg/imaging"
+	"ogimg/pkg/policy"
+)
+
+func runFetch(ctx context.Context, app *wire.App, args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	asJson := fs.Bool("json", false, "print as json")
+	pageUrl, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	decision, err := checkPolicy(ctx, app, pageUrl)
+	if err != nil {
+		return err
+	}
+	meta, err := app.ImageService.ExtractByUrl(ctx, pageUrl)
+	if err != nil {
+		return err
+	}
+
+	if *asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{"metadata": meta, "policy": decision})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "url:\t%s\n", meta.Url)
+	fmt.Fprintf(w, "title:\t%s\n", meta.Title)
+	fmt.Fprintf(w, "description:\t%s\n", meta.Description)
+	fmt.Fprintf(w, "logo:\t%s\n", meta.Logo)
+	fmt.Fprintf(w, "image:\t%s\n", meta.Image)
+	fmt.Fprintf(w, "policy:\tallowed=%t %s\n", decision.Allowed, decision.Rule)
+	fmt.Fprintln(w, "candidates:")
+	for _, c := range meta.Images {
+		fmt.Fprintf(w, "  %s\t%s\n", c.Source, c.Url)
+	}
+	return w.Flush()
+}
+
+func runImage(ctx context.Context, app *wire.App, args []string) error {
+	fs := flag.NewFlagSet("image", flag.ExitOnError)
+	out := fs.String("o", "", "output file, - for stdout")
+	width := fs.Int("width", 0, "resize to this width")
+	height := fs.Int("height", 0, "resize to this height")
+	fit := fs.String("fit", imaging.FitContain, "contain or cover when both width and height are set")
+	pageUrl, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-o is required")
+	}
+
+	if _, err := checkPolicy(ctx, app, pageUrl); err != nil {
+		return err
+	}
+	img, err := app.ImageService.GetOgImageByUrl(ctx, pageUrl)
+	if err != nil {
+		return err
+	}
+	data := img.Data
+	if *width > 0 || *height > 0 {
+		format := strings.TrimPrefix(strings.ToLower(filepath.Ext(*out)), ".")
+		if format != "jpg" && format != "jpeg" {
+			format = "png"
+		}
+		data, _, err = imaging.Resize(data, imaging.ResizeOptions{Width: *width, Height: *height, Fit: *fit, Format: format})
+		if err != nil {
+			return err
+		}
+	}
+
+	if *out == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "saved %s (%d bytes, cache %s)\n", *out, len(data), img.Cache)
+	return nil
+}
+
+func runWarm(ctx context.Context, app *wire.App, args []string) error {
+	fs := flag.NewFlagSet("warm", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", app.Conf.GetInt("warmup.concurrency"), "number of concurrent fetches")
+	source, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var urls []string
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		urls, err = app.WarmupService.EnumerateUrl(ctx, source)
+	} else {
+		var f *os.File
+		if f, err = os.Open(source); err != nil {
+			return err
+		}
+		urls, err = app.WarmupService.Enumerate(ctx, f)
+		f.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu     sync.Mutex
+		done   int
+		failed int
+	)
+	app.WarmupService.Warm(ctx, urls, *concurrency, func(result model.WarmupResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if result.Error != nil {
+			failed++
+			fmt.Printf("[%d/%d] FAIL %s: %v\n", done, len(urls), result.Url, result.Error)
+		} else {
+			fmt.Printf("[%d/%d] OK   %s\n", done, len(urls), result.Url)
+		}
+	})
+
+	fmt.Printf("warmed %d urls, %d failed\n", done-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d urls failed", failed)
+	}
+	return ctx.Err()
+}
+
+func runPurge(ctx context.Context, app *wire.App, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	pageUrl, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	n, err := app.Repository.DeleteWebsiteCache(ctx, pageUrl)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("deleted %d cache keys\n", n)
+	return nil
+}
+
+func runInspectCache(ctx context.Context, app *wire.App, args []string) error {
+	fs := flag.NewFlagSet("inspect-cache", flag.ExitOnError)
+	pageUrl, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	entries, err := app.Repository.InspectCache(ctx, pageUrl)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tKEY\tEXISTS\tSIZE\tTTL")
+	for _, e := range entries {
+		ttl := "-"
+		if e.Exists {
+			ttl = e.TTL.String()
+			if e.TTL < 0 {
+				ttl = "never expires"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%d\t%s\n", e.Kind, e.Key, e.Exists, e.Size, ttl)
+	}
+	return w.Flush()
+}
+
+// checkPolicy 与服务端相同，被域名策略拒绝的 url 不会抓取，错误中包含命中的规则
+func checkPolicy(ctx context.Context, app *wire.App, pageUrl string) (policy.Decision, error) {
+	decision, err := app.Policy.Check(ctx, pageUrl)
+	if err != nil {
+		return decision, err
+	}
+	if !decision.Allowed {
+		return decision, decision.Err()
+	}
+	return decision, nil
+}
+
+// parseArgs 解析参数，flag 可以写在唯一的位置参数前后
+func parseArgs(fs *flag.FlagSet, args []string) (string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		return "", fmt.Errorf("%s expects exactly one argument", fs.Name())
+	}
+	return positional[0], nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ogimg/cmd/ogimg/wire"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+	"ogimg/pkg/policy"
+
+	"github.com/spf13/viper"
+)
+
+// 被策略拒绝时在抓取前返回，App 中没有 ImageService 也不会被调用
+func TestPolicyEnforced(t *testing.T) {
+	conf := config.New(viper.New())
+	conf.Set("log.log_level", "error")
+	conf.Set("log.log_file_name", filepath.Join(t.TempDir(), "cli.log"))
+	conf.Set("policy.deny", []string{"blocked.example.com"})
+	logger := log.NewLog(conf)
+	p, err := policy.NewPolicy(conf, logger, config.NewWatcher(conf, logger.Logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &wire.App{Conf: conf, Logger: logger, Policy: p}
+
+	tests := []struct {
+		name string
+		run  func(context.Context, *wire.App, []string) error
+		args []string
+	}{
+		{"fetch", runFetch, []string{"https://blocked.example.com/page"}},
+		{"image", runImage, []string{"-o", filepath.Join(t.TempDir(), "og.png"), "https://blocked.example.com/page"}},
+	}
+	for _, tt := range tests {
+		err := tt.run(context.Background(), app, tt.args)
+		if !errors.Is(err, apierr.Blocked) || !strings.Contains(err.Error(), "deny:blocked.example.com") {
+			t.Errorf("%s: expected blocked error with rule, got %v", tt.name, err)
+		}
+	}
+}
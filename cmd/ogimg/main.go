@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ogimg/cmd/ogimg/wire"
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+	"ogimg/pkg/telemetry"
+)
+
+const usage = `Usage: ogimg [-conf config/local.yml] [-v] <command> [arguments]
+
+Commands:
+  fetch <url>                 print extracted metadata and candidate images
+  image <url> -o <file>       save the og:image, optionally resized
+  warm <file|url>             populate the cache from a url list, sitemap, RSS or Atom feed
+  purge <url>                 delete the cached image and description
+  inspect-cache <url>         show cache keys, sizes and TTLs
+`
+
+type command func(ctx context.Context, app *wire.App, args []string) error
+
+var commands = map[string]command{
+	"fetch":         runFetch,
+	"image":         runImage,
+	"warm":          runWarm,
+	"purge":         runPurge,
+	"inspect-cache": runInspectCache,
+}
+
+func main() {
+	verbose := flag.Bool("v", false, "print debug logs")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+
+	conf := config.NewConfig()
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+	if !*verbose {
+		conf.Set("log.log_level", "error")
+	}
+	// webhook 只在这里排队，由服务端投递
+	conf.Set("webhooks.workers", 0)
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.NewLog(conf)
+	shutdown, err := telemetry.Setup(conf)
+	if err != nil {
+		panic(err)
+	}
+	// 命令行只运行一次，不监听配置文件
+	app, cleanup, err := wire.NewWire(conf, logger, config.NewWatcher(conf, logger.Logger))
+	if err != nil {
+		panic(err)
+	}
+	defer cleanup()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = cmd(ctx, app, args[1:])
+	// os.Exit 不会执行 defer，先把未导出的 span 写出
+	_ = shutdown(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,19 @@
+package wire
+
+import (
+	"ogimg/internal/repository"
+	"ogimg/internal/service"
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+	"ogimg/pkg/policy"
+)
+
+// App 命令行工具使用的依赖
+type App struct {
+	Conf          *config.Config
+	Logger        *log.Logger
+	Repository    *repository.Repository
+	ImageService  service.ImageService
+	WarmupService service.WarmupService
+	Policy        *policy.Policy
+}
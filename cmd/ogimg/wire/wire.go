@@ -0,0 +1,43 @@
+//go:build wireinject
+// +build wireinject
+
+package wire
+
+import (
+	"ogimg/internal/repository"
+	"ogimg/internal/service"
+	"ogimg/pkg/config"
+	"ogimg/pkg/helper/sid"
+	"ogimg/pkg/log"
+	"ogimg/pkg/policy"
+
+	"github.com/google/wire"
+)
+
+var RepositorySet = wire.NewSet(
+	repository.NewDb,
+	repository.NewRepository,
+	repository.NewWebhookRepository,
+	repository.NewLinkRepository,
+)
+
+var ServiceSet = wire.NewSet(
+	service.NewService,
+	service.NewImageService,
+	service.NewWebhookService,
+	service.NewWarmupService,
+)
+
+var PolicySet = wire.NewSet(policy.NewPolicy)
+
+var HelperSet = wire.NewSet(sid.NewSid)
+
+func NewWire(*config.Config, *log.Logger, *config.Watcher) (*App, func(), error) {
+	panic(wire.Build(
+		RepositorySet,
+		ServiceSet,
+		PolicySet,
+		HelperSet,
+		wire.Struct(new(App), "*"),
+	))
+}
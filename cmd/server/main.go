@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"ogimg/cmd/server/wire"
 	"ogimg/pkg/config"
 	"ogimg/pkg/http"
 	"ogimg/pkg/log"
+	"ogimg/pkg/telemetry"
 
 	"go.uber.org/zap"
 )
@@ -14,13 +16,34 @@ func main() {
 	conf := config.NewConfig()
 	logger := log.NewLog(conf)
 
+	shutdown, err := telemetry.Setup(conf)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := shutdown(context.Background()); err != nil {
+			logger.Error("telemetry shutdown error", zap.Error(err))
+		}
+	}()
+
 	logger.Info("server start", zap.String("host", "http://127.0.0.1:"+conf.GetString("http.port")))
 
-	app, cleanup, err := wire.NewWire(conf, logger)
+	watcher := config.NewWatcher(conf, logger.Logger)
+	levelChanges := watcher.Subscribe("log.log_level")
+	go func() {
+		for range levelChanges {
+			if err := logger.SetLevel(conf.GetString("log.log_level")); err != nil {
+				logger.Error("Reload log level error", zap.Error(err))
+			}
+		}
+	}()
+
+	app, cleanup, err := wire.NewWire(conf, logger, watcher)
 	if err != nil {
 		panic(err)
 	}
 	defer cleanup()
+	watcher.Watch()
 
 	http.Run(app, fmt.Sprintf(":%d", conf.GetInt("http.port")))
 }
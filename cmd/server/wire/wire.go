@@ -8,11 +8,13 @@ import (
 	"ogimg/internal/repository"
 	"ogimg/internal/server"
 	"ogimg/internal/service"
+	"ogimg/pkg/config"
+	"ogimg/pkg/helper/sid"
 	"ogimg/pkg/log"
+	"ogimg/pkg/policy"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
-	"github.com/spf13/viper"
 )
 
 var ServerSet = wire.NewSet(server.NewServerHTTP)
@@ -20,26 +22,52 @@ var ServerSet = wire.NewSet(server.NewServerHTTP)
 var RepositorySet = wire.NewSet(
 	repository.NewDb,
 	repository.NewRepository,
+	repository.NewWebhookRepository,
+	repository.NewLinkRepository,
 	repository.NewUserRepository,
+	repository.NewJobRepository,
 )
 
 var ServiceSet = wire.NewSet(
 	service.NewService,
 	service.NewUserService,
 	service.NewImageService,
+	service.NewWebhookService,
+	service.NewWarmupService,
+	service.NewJobService,
+	service.NewHealthService,
+	service.NewHistoryService,
+	service.NewMockupService,
+	service.NewCardService,
+	service.NewOEmbedService,
 )
 
 var HandlerSet = wire.NewSet(
 	handler.NewHandler,
 	handler.NewUserHandler,
 	handler.NewImageHandler,
+	handler.NewWarmupHandler,
+	handler.NewJobHandler,
+	handler.NewWebhookHandler,
+	handler.NewHealthHandler,
+	handler.NewAdminHandler,
+	handler.NewHistoryHandler,
+	handler.NewMockupHandler,
+	handler.NewCardHandler,
+	handler.NewOEmbedHandler,
 )
 
-func NewWire(*viper.Viper, *log.Logger) (*gin.Engine, func(), error) {
+var PolicySet = wire.NewSet(policy.NewPolicy)
+
+var HelperSet = wire.NewSet(sid.NewSid)
+
+func NewWire(*config.Config, *log.Logger, *config.Watcher) (*gin.Engine, func(), error) {
 	panic(wire.Build(
 		ServerSet,
 		RepositorySet,
 		ServiceSet,
 		HandlerSet,
+		PolicySet,
+		HelperSet,
 	))
 }
@@ -12,25 +12,54 @@ import (
 	"ogimg/internal/repository"
 	"ogimg/internal/server"
 	"ogimg/internal/service"
+	"ogimg/pkg/config"
+	"ogimg/pkg/helper/sid"
 	"ogimg/pkg/log"
+	"ogimg/pkg/policy"
 	"github.com/google/wire"
-	"github.com/spf13/viper"
 )
 
 // Injectors from wire.go:
 
-func NewWire(viperViper *viper.Viper, logger *log.Logger) (*gin.Engine, func(), error) {
-	handlerHandler := handler.NewHandler(logger)
-	serviceService := service.NewService(logger)
-	db := repository.NewDb()
-	repositoryRepository := repository.NewRepository(logger, db, viperViper)
+func NewWire(configConfig *config.Config, logger *log.Logger, watcher *config.Watcher) (*gin.Engine, func(), error) {
+	policyPolicy, err := policy.NewPolicy(configConfig, logger, watcher)
+	if err != nil {
+		return nil, nil, err
+	}
+	handlerHandler := handler.NewHandler(logger, configConfig)
+	serviceService := service.NewService(logger, configConfig, policyPolicy)
+	db := repository.NewDb(configConfig, logger)
+	repositoryRepository := repository.NewRepository(logger, db, configConfig, policyPolicy)
 	userRepository := repository.NewUserRepository(repositoryRepository)
 	userService := service.NewUserService(serviceService, userRepository)
-	imageService := service.NewImageService(serviceService, repositoryRepository)
-	imageHandler := handler.NewImageHandler(handlerHandler, imageService)
+	webhookRepository := repository.NewWebhookRepository(repositoryRepository)
+	sidSid := sid.NewSid()
+	webhookService, cleanup := service.NewWebhookService(serviceService, webhookRepository, sidSid)
+	linkRepository := repository.NewLinkRepository(repositoryRepository)
+	imageService := service.NewImageService(serviceService, repositoryRepository, linkRepository, webhookService)
+	imageHandler := handler.NewImageHandler(handlerHandler, imageService, policyPolicy)
 	userHandler := handler.NewUserHandler(handlerHandler, userService)
-	engine := server.NewServerHTTP(logger, userHandler, imageHandler)
+	warmupService := service.NewWarmupService(serviceService, imageService, policyPolicy, sidSid)
+	warmupHandler := handler.NewWarmupHandler(handlerHandler, warmupService, policyPolicy)
+	jobRepository := repository.NewJobRepository(repositoryRepository)
+	jobService, cleanup2 := service.NewJobService(serviceService, imageService, webhookService, jobRepository, sidSid)
+	jobHandler := handler.NewJobHandler(handlerHandler, jobService, policyPolicy)
+	webhookHandler := handler.NewWebhookHandler(handlerHandler, webhookService, policyPolicy)
+	healthService := service.NewHealthService(serviceService, repositoryRepository)
+	healthHandler := handler.NewHealthHandler(handlerHandler, healthService)
+	adminHandler := handler.NewAdminHandler(handlerHandler, imageService)
+	historyService := service.NewHistoryService(serviceService, linkRepository)
+	historyHandler := handler.NewHistoryHandler(handlerHandler, historyService)
+	mockupService := service.NewMockupService(serviceService, imageService)
+	mockupHandler := handler.NewMockupHandler(handlerHandler, mockupService, policyPolicy)
+	cardService := service.NewCardService(serviceService, imageService)
+	cardHandler := handler.NewCardHandler(handlerHandler, cardService, policyPolicy)
+	oEmbedService := service.NewOEmbedService(serviceService, imageService)
+	oEmbedHandler := handler.NewOEmbedHandler(handlerHandler, oEmbedService, policyPolicy)
+	engine := server.NewServerHTTP(logger, configConfig, userHandler, imageHandler, warmupHandler, jobHandler, webhookHandler, healthHandler, adminHandler, historyHandler, mockupHandler, cardHandler, oEmbedHandler)
 	return engine, func() {
+		cleanup2()
+		cleanup()
 	}, nil
 }
 
@@ -38,8 +67,12 @@ func NewWire(viperViper *viper.Viper, logger *log.Logger) (*gin.Engine, func(),
 
 var ServerSet = wire.NewSet(server.NewServerHTTP)
 
-var RepositorySet = wire.NewSet(repository.NewDb, repository.NewRepository, repository.NewUserRepository)
+var RepositorySet = wire.NewSet(repository.NewDb, repository.NewRepository, repository.NewUserRepository, repository.NewWebhookRepository, repository.NewLinkRepository, repository.NewJobRepository)
 
-var ServiceSet = wire.NewSet(service.NewService, service.NewUserService)
+var ServiceSet = wire.NewSet(service.NewService, service.NewUserService, service.NewImageService, service.NewWebhookService, service.NewWarmupService, service.NewJobService, service.NewHealthService, service.NewHistoryService, service.NewMockupService, service.NewCardService, service.NewOEmbedService)
 
-var HandlerSet = wire.NewSet(handler.NewHandler, handler.NewUserHandler)
+var HandlerSet = wire.NewSet(handler.NewHandler, handler.NewUserHandler, handler.NewImageHandler, handler.NewWarmupHandler, handler.NewJobHandler, handler.NewWebhookHandler, handler.NewHealthHandler, handler.NewAdminHandler, handler.NewHistoryHandler, handler.NewMockupHandler, handler.NewCardHandler, handler.NewOEmbedHandler)
+
+var PolicySet = wire.NewSet(policy.NewPolicy)
+
+var HelperSet = wire.NewSet(sid.NewSid)
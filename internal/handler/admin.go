@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/helper/resp"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type AdminHandler struct {
+	*Handler
+	imageService service.ImageService
+}
+
+func NewAdminHandler(handler *Handler, imageService service.ImageService) *AdminHandler {
+	return &AdminHandler{
+		Handler:      handler,
+		imageService: imageService,
+	}
+}
+
+type logLevelParams struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// GetLogLevel 返回当前日志级别
+func (h *AdminHandler) GetLogLevel(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"level": h.logger.Level()})
+}
+
+// SetLogLevel 修改日志级别，配置文件中的 log.log_level 变更后会再次覆盖
+func (h *AdminHandler) SetLogLevel(ctx *gin.Context) {
+	var params logLevelParams
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("invalid JSON body").Wrap(err), nil)
+		return
+	}
+	prev := h.logger.Level()
+	if err := h.logger.SetLevel(params.Level); err != nil {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage(err.Error()), nil)
+		return
+	}
+	h.logger.WithContext(ctx).Warn("Log level changed", zap.String("from", prev), zap.String("to", params.Level))
+	ctx.JSON(http.StatusOK, gin.H{"level": h.logger.Level()})
+}
+
+// PurgeCache 删除 url 的图片、图标和描述缓存，返回删除的缓存项数量
+func (h *AdminHandler) PurgeCache(ctx *gin.Context) {
+	userUrl := ctx.Query("url")
+	if userUrl == "" {
+		resp.HandleAPIError(ctx, apierr.InvalidUrl.WithMessage("url is required"), nil)
+		return
+	}
+	deleted, err := h.imageService.PurgeByUrl(ctx.Request.Context(), userUrl)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	h.logger.WithContext(ctx).Info("Cache purged", zap.String("url", userUrl), zap.Int64("deleted", deleted))
+	ctx.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/card"
+	"ogimg/pkg/helper/resp"
+	"ogimg/pkg/policy"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cardPolicy 卡片只包含内联样式和图片，嵌入 iframe 时禁止脚本等其它内容
+const cardPolicy = "default-src 'none'; img-src http: https:; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'"
+
+type CardHandler struct {
+	*Handler
+	cardService service.CardService
+	policy      *policy.Policy
+}
+
+func NewCardHandler(handler *Handler, cardService service.CardService, policy *policy.Policy) *CardHandler {
+	return &CardHandler{
+		Handler:     handler,
+		cardService: cardService,
+		policy:      policy,
+	}
+}
+
+// GetCard 返回可嵌入的 HTML 卡片，format=json 时返回清理后的字段和渲染好的 HTML
+func (h *CardHandler) GetCard(ctx *gin.Context) {
+	userUrl := ctx.Query("url")
+	if data, err := checkUrl(ctx, h.policy, userUrl); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+	theme := ctx.DefaultQuery("theme", card.ThemeLight)
+	if !card.ValidTheme(theme) {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("theme must be one of "+strings.Join(card.Themes, ", ")), nil)
+		return
+	}
+	format := ctx.DefaultQuery("format", "html")
+	if format != "html" && format != "json" {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("format must be html or json"), nil)
+		return
+	}
+
+	c, err := h.cardService.GetCard(ctx.Request.Context(), userUrl, h.baseUrl(), theme)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	// oEmbed 发现链接，见 https://oembed.com/#section4
+	endpoint := h.baseUrl() + "/v1/oembed?"
+	ctx.Header("Link", fmt.Sprintf(`<%s>; rel="alternate"; type="application/json+oembed", <%s>; rel="alternate"; type="text/xml+oembed"`,
+		endpoint+url.Values{"url": {userUrl}, "format": {"json"}}.Encode(),
+		endpoint+url.Values{"url": {userUrl}, "format": {"xml"}}.Encode()))
+	if format == "json" {
+		ctx.JSON(http.StatusOK, c)
+		return
+	}
+	ctx.Header("Content-Security-Policy", cardPolicy)
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(c.Html))
+}
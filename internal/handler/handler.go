@@ -1,15 +1,24 @@
 package handler
 
 import (
+	"ogimg/pkg/config"
 	"ogimg/pkg/log"
+	"strings"
 )
 
 type Handler struct {
 	logger *log.Logger
+	conf   *config.Config
 }
 
-func NewHandler(logger *log.Logger) *Handler {
+func NewHandler(logger *log.Logger, conf *config.Config) *Handler {
 	return &Handler{
 		logger: logger,
+		conf:   conf,
 	}
 }
+
+// baseUrl 服务对外的地址，用于生成返回给调用方的绝对地址，由 config.Validate 保证已配置
+func (h *Handler) baseUrl() string {
+	return strings.TrimRight(h.conf.GetString("http.public_url"), "/")
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/config"
+	"ogimg/pkg/extract"
+	"ogimg/pkg/lint"
+	"ogimg/pkg/log"
+	"ogimg/pkg/policy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	v := viper.New()
+	v.SetConfigFile("../../config/local.yml")
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.New(v)
+	conf.Set("log.log_file_name", filepath.Join(t.TempDir(), "server.log"))
+	conf.Set("log.log_level", "error")
+	conf.Set("log.encoding", "json")
+	return conf
+}
+
+func newTestPolicy(t *testing.T, conf *config.Config, logger *log.Logger) *policy.Policy {
+	t.Helper()
+	p, err := policy.NewPolicy(conf, logger, config.NewWatcher(conf, logger.Logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// fakeImageService 按 url 返回固定结果，记录收到的 url
+type fakeImageService struct {
+	descs  map[string]model.WebsiteDescType
+	image  *model.OgImage
+	called []string
+}
+
+func (f *fakeImageService) GetOgImageByUrl(ctx context.Context, userUrl string) (*model.OgImage, error) {
+	f.called = append(f.called, userUrl)
+	if f.image == nil {
+		return nil, apierr.NoImage
+	}
+	return f.image, nil
+}
+
+func (f *fakeImageService) GetOgDescByUrl(ctx context.Context, userUrl string) (*model.OgDesc, error) {
+	f.called = append(f.called, userUrl)
+	desc, ok := f.descs[userUrl]
+	if !ok {
+		return nil, apierr.UpstreamStatus
+	}
+	return &model.OgDesc{Desc: desc, Cache: model.CacheMiss}, nil
+}
+
+func (f *fakeImageService) GetOgDescBatch(ctx context.Context, urls []string) []model.BatchDescResult {
+	results := make([]model.BatchDescResult, len(urls))
+	for i, u := range urls {
+		results[i].Url = u
+		desc, err := f.GetOgDescByUrl(ctx, u)
+		if err != nil {
+			results[i].Error = apierr.From(err)
+			continue
+		}
+		results[i].Desc = &desc.Desc
+	}
+	return results
+}
+
+func (f *fakeImageService) GetIconByUrl(ctx context.Context, userUrl string) (*model.OgImage, error) {
+	return f.GetOgImageByUrl(ctx, userUrl)
+}
+
+func (f *fakeImageService) WarmByUrl(ctx context.Context, userUrl string) (string, error) {
+	if _, err := f.GetOgDescByUrl(ctx, userUrl); err != nil {
+		return "desc", err
+	}
+	if _, err := f.GetOgImageByUrl(ctx, userUrl); err != nil {
+		return "image", err
+	}
+	return "", nil
+}
+
+func (f *fakeImageService) PurgeByUrl(ctx context.Context, userUrl string) (int64, error) {
+	f.called = append(f.called, userUrl)
+	return 3, nil
+}
+
+func (f *fakeImageService) ExtractByUrl(ctx context.Context, userUrl string) (*extract.Metadata, error) {
+	return nil, apierr.NotImplemented
+}
+
+func (f *fakeImageService) ValidateByUrl(ctx context.Context, userUrl string) (*lint.Report, error) {
+	return nil, apierr.NotImplemented
+}
+
+func serve(r *gin.Engine, method, target string, body io.Reader) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, body)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// errorBody 错误响应的结构
+type errorBody struct {
+	Code    int                    `json:"code"`
+	Reason  string                 `json:"reason"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+	var body errorBody
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+func jsonBody(v interface{}) io.Reader {
+	data, _ := json.Marshal(v)
+	return strings.NewReader(string(data))
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"ogimg/internal/model"
+	"ogimg/internal/service"
+	"ogimg/pkg/version"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	*Handler
+	healthService service.HealthService
+}
+
+func NewHealthHandler(handler *Handler, healthService service.HealthService) *HealthHandler {
+	return &HealthHandler{
+		Handler:       handler,
+		healthService: healthService,
+	}
+}
+
+// Healthz 存活探针，只要进程能处理请求就返回 200
+func (h *HealthHandler) Healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": model.HealthOk})
+}
+
+// Readyz 就绪探针，依赖不可用时返回 503
+func (h *HealthHandler) Readyz(ctx *gin.Context) {
+	readiness := h.healthService.Ready(ctx.Request.Context())
+	status := http.StatusOK
+	if readiness.Status != model.ReadyOk {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, readiness)
+}
+
+func (h *HealthHandler) Version(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, version.Get())
+}
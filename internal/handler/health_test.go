@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeHealthService struct {
+	readiness *model.Readiness
+}
+
+func (f *fakeHealthService) Ready(ctx context.Context) *model.Readiness {
+	return f.readiness
+}
+
+func TestHealth(t *testing.T) {
+	conf := newTestConfig(t)
+	health := &fakeHealthService{readiness: &model.Readiness{
+		Status: model.ReadyOk,
+		Checks: map[string]model.HealthCheck{"redis": {Status: model.HealthOk}},
+	}}
+	h := NewHealthHandler(NewHandler(log.NewLog(conf), conf), health)
+	r := gin.New()
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+	r.GET("/version", h.Version)
+
+	if w := serve(r, http.MethodGet, "/healthz", nil); w.Code != http.StatusOK {
+		t.Errorf("healthz = %d", w.Code)
+	}
+	if w := serve(r, http.MethodGet, "/readyz", nil); w.Code != http.StatusOK {
+		t.Errorf("readyz = %d %s", w.Code, w.Body)
+	}
+
+	// 任意一项失败时返回 503，并带上失败的检查
+	health.readiness = &model.Readiness{
+		Status: model.ReadyUnavailable,
+		Checks: map[string]model.HealthCheck{"redis": {Status: model.HealthFailed, Error: "connection refused"}},
+	}
+	w := serve(r, http.MethodGet, "/readyz", nil)
+	var body model.Readiness
+	if w.Code != http.StatusServiceUnavailable || json.Unmarshal(w.Body.Bytes(), &body) != nil || body.Checks["redis"].Status != model.HealthFailed {
+		t.Errorf("readyz = %d %s", w.Code, w.Body)
+	}
+
+	w = serve(r, http.MethodGet, "/version", nil)
+	var version map[string]interface{}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &version) != nil || len(version) == 0 {
+		t.Errorf("version = %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/helper/resp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HistoryHandler struct {
+	*Handler
+	historyService service.HistoryService
+}
+
+func NewHistoryHandler(handler *Handler, historyService service.HistoryService) *HistoryHandler {
+	return &HistoryHandler{
+		Handler:        handler,
+		historyService: historyService,
+	}
+}
+
+// GetHistory 返回 url 的预览版本时间线，只读取数据库，不会抓取页面
+func (h *HistoryHandler) GetHistory(ctx *gin.Context) {
+	userUrl := ctx.Query("url")
+	if userUrl == "" {
+		resp.HandleAPIError(ctx, apierr.InvalidUrl.WithMessage("url is required"), nil)
+		return
+	}
+	history, err := h.historyService.GetHistory(ctx.Request.Context(), userUrl)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.JSON(http.StatusOK, history)
+}
+
+// ListChanges 返回 since（RFC 3339）之后预览发生变化的 url，limit 不超过 history.max_changes
+func (h *HistoryHandler) ListChanges(ctx *gin.Context) {
+	since, err := time.Parse(time.RFC3339, ctx.Query("since"))
+	if err != nil {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("since must be an RFC 3339 time, e.g. 2024-01-02T15:04:05Z"), nil)
+		return
+	}
+	max := h.conf.GetInt("history.max_changes")
+	limit := max
+	if raw := ctx.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("limit must be a positive integer"), nil)
+			return
+		}
+		if max > 0 && limit > max {
+			limit = max
+		}
+	}
+
+	changes, err := h.historyService.ListChanges(ctx.Request.Context(), since, limit)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.JSON(http.StatusOK, changes)
+}
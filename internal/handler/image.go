@@ -1,9 +1,18 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"ogimg/internal/middleware"
+	"ogimg/internal/model"
 	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
 	"ogimg/pkg/helper/resp"
+	"ogimg/pkg/imaging"
+	"ogimg/pkg/policy"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,37 +20,180 @@ import (
 type ImageHandler struct {
 	Handler      *Handler
 	imageService service.ImageService
+	policy       *policy.Policy
 }
 
-func NewImageHandler(handler *Handler, imageService service.ImageService) *ImageHandler {
+func NewImageHandler(handler *Handler, imageService service.ImageService, policy *policy.Policy) *ImageHandler {
 	return &ImageHandler{
 		Handler:      handler,
 		imageService: imageService,
+		policy:       policy,
 	}
 }
 
+// GetOgImageByUrl 返回 og:image，传入 width、height 时按 fit 缩放，缓存中保存的是原图
 func (h *ImageHandler) GetOgImageByUrl(ctx *gin.Context) {
 	userUrl := ctx.Query("url")
-	if userUrl == "" {
-		resp.HandleError(ctx, http.StatusBadRequest, 1, "Url is required", nil)
+	if data, err := checkUrl(ctx, h.policy, userUrl); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+	opts, err := resizeOptions(ctx)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
 		return
 	}
 
-	if err := h.imageService.GetOgImageByUrl(ctx, userUrl); err != nil {
-		resp.HandleError(ctx, http.StatusInternalServerError, 1, err.Error(), nil)
+	img, err := h.imageService.GetOgImageByUrl(ctx.Request.Context(), userUrl)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
 		return
 	}
+	data, contentType := img.Data, img.ContentType
+	if opts.Width > 0 || opts.Height > 0 {
+		if data, contentType, err = imaging.Resize(img.Data, opts); err != nil {
+			if errors.Is(err, imaging.ErrTooManyPixels) {
+				resp.HandleAPIError(ctx, apierr.TooLarge.WithMessage(fmt.Sprintf("image is over %d pixels and cannot be resized", imaging.MaxPixels)).Wrap(err), nil)
+				return
+			}
+			resp.HandleAPIError(ctx, apierr.UnsupportedType.WithMessage("image cannot be resized").Wrap(err), nil)
+			return
+		}
+	}
+	ctx.Header("X-Cache", string(img.Cache))
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// resizeOptions 解析 width、height、fit 参数
+func resizeOptions(ctx *gin.Context) (imaging.ResizeOptions, error) {
+	opts := imaging.ResizeOptions{Fit: ctx.DefaultQuery("fit", imaging.FitContain)}
+	if opts.Fit != imaging.FitContain && opts.Fit != imaging.FitCover {
+		return opts, apierr.InvalidRequest.WithMessage("fit must be contain or cover")
+	}
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"width", &opts.Width}, {"height", &opts.Height}} {
+		raw := ctx.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > imaging.MaxDimension {
+			return opts, apierr.InvalidRequest.WithMessage(fmt.Sprintf("%s must be between 1 and %d", p.name, imaging.MaxDimension))
+		}
+		*p.value = n
+	}
+	return opts, nil
+}
+
+// GetIconByUrl 返回网站图标，页面没有声明时使用站点的 /favicon.ico
+func (h *ImageHandler) GetIconByUrl(ctx *gin.Context) {
+	userUrl := ctx.Query("url")
+	if data, err := checkUrl(ctx, h.policy, userUrl); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+
+	img, err := h.imageService.GetIconByUrl(ctx.Request.Context(), userUrl)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.Header("X-Cache", string(img.Cache))
+	ctx.Data(http.StatusOK, img.ContentType, img.Data)
 }
 
 func (h *ImageHandler) GetOgDescByUrl(ctx *gin.Context) {
 	userUrl := ctx.Query("url")
-	if userUrl == "" {
-		resp.HandleError(ctx, http.StatusBadRequest, 1, "Url is required", nil)
+	if data, err := checkUrl(ctx, h.policy, userUrl); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+
+	desc, err := h.imageService.GetOgDescByUrl(ctx.Request.Context(), userUrl)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.Header("X-Cache", string(desc.Cache))
+	ctx.JSON(http.StatusOK, desc.Desc)
+}
+
+// ValidateByUrl 检查页面的 Open Graph 和 Twitter Card 标签，问题在报告中返回，不作为请求错误
+func (h *ImageHandler) ValidateByUrl(ctx *gin.Context) {
+	userUrl := ctx.Query("url")
+	if data, err := checkUrl(ctx, h.policy, userUrl); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+
+	report, err := h.imageService.ValidateByUrl(ctx.Request.Context(), userUrl)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
 		return
 	}
+	ctx.JSON(http.StatusOK, report)
+}
 
-	if err := h.imageService.GetOgDescByUrl(ctx, userUrl); err != nil {
-		resp.HandleError(ctx, http.StatusInternalServerError, 1, err.Error(), nil)
+// GetOgDescBatch 批量获取网站描述，请求体为 url 数组，单个 url 的错误在结果中返回
+func (h *ImageHandler) GetOgDescBatch(ctx *gin.Context) {
+	var urls []string
+	if err := ctx.ShouldBindJSON(&urls); err != nil {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("request body must be a JSON array of urls").Wrap(err), nil)
 		return
 	}
+	if len(urls) == 0 {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("urls are required"), nil)
+		return
+	}
+	if max := h.Handler.conf.GetInt("batch.max_urls"); len(urls) > max {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage(fmt.Sprintf("at most %d urls are allowed", max)), nil)
+		return
+	}
+
+	results := make([]model.BatchDescResult, len(urls))
+	allowed := make([]string, 0, len(urls))
+	indexes := make([]int, 0, len(urls))
+	for i, userUrl := range urls {
+		results[i].Url = userUrl
+		if data, err := checkUrl(ctx, h.policy, userUrl); err != nil {
+			if decision, ok := data.(policy.Decision); ok {
+				err = decision.Err()
+			}
+			results[i].Error = apierr.From(err)
+			continue
+		}
+		allowed = append(allowed, userUrl)
+		indexes = append(indexes, i)
+	}
+
+	for i, result := range h.imageService.GetOgDescBatch(ctx.Request.Context(), allowed) {
+		results[indexes[i]] = result
+	}
+	ctx.JSON(http.StatusOK, results)
+}
+
+// checkUrl 校验 url 格式和域名策略，被策略拒绝时同时返回策略结果（包含命中的规则）
+func checkUrl(ctx *gin.Context, policy *policy.Policy, userUrl string) (interface{}, error) {
+	if userUrl == "" {
+		return nil, apierr.InvalidUrl.WithMessage("url is required")
+	}
+	u, err := url.Parse(userUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, apierr.InvalidUrl.WithMessage("url must be an absolute http(s) url")
+	}
+	// 批量请求时访问日志记录第一个站点
+	if _, ok := ctx.Get(middleware.TargetHost); !ok {
+		ctx.Set(middleware.TargetHost, u.Host)
+	}
+
+	decision, err := policy.Check(ctx.Request.Context(), userUrl)
+	if err != nil {
+		return nil, apierr.InvalidUrl.Wrap(err)
+	}
+	if !decision.Allowed {
+		return decision, apierr.Blocked
+	}
+	return nil, nil
 }
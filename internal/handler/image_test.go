@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newImageRouter(t *testing.T, images *fakeImageService, setup func(conf *config.Config)) *gin.Engine {
+	t.Helper()
+	conf := newTestConfig(t)
+	if setup != nil {
+		setup(conf)
+	}
+	logger := log.NewLog(conf)
+	h := NewImageHandler(NewHandler(logger, conf), images, newTestPolicy(t, conf, logger))
+	r := gin.New()
+	r.GET("/v1/desc", h.GetOgDescByUrl)
+	r.POST("/v1/desc/batch", h.GetOgDescBatch)
+	r.GET("/v1/image", h.GetOgImageByUrl)
+	return r
+}
+
+func TestDescBatch(t *testing.T) {
+	images := &fakeImageService{descs: map[string]model.WebsiteDescType{
+		"https://a.example.com/": {Title: "a"},
+		"https://b.example.com/": {Title: "b"},
+	}}
+	r := newImageRouter(t, images, func(conf *config.Config) {
+		conf.Set("policy.deny", []string{"blocked.example.com"})
+	})
+
+	urls := []string{"https://a.example.com/", "https://blocked.example.com/", "not a url", "https://b.example.com/", "https://c.example.com/"}
+	w := serve(r, http.MethodPost, "/v1/desc/batch", jsonBody(urls))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var results []model.BatchDescResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(urls) {
+		t.Fatalf("got %d results", len(results))
+	}
+	for i, u := range urls {
+		if results[i].Url != u {
+			t.Errorf("result %d url = %q, want %q", i, results[i].Url, u)
+		}
+	}
+	if results[0].Desc == nil || results[0].Desc.Title != "a" || results[3].Desc == nil || results[3].Desc.Title != "b" {
+		t.Errorf("unexpected descs: %+v", results)
+	}
+	if e := results[1].Error; e == nil || e.Reason != "blocked" || !strings.Contains(e.Message, "deny:blocked.example.com") {
+		t.Errorf("blocked result = %+v", results[1].Error)
+	}
+	if e := results[2].Error; e == nil || e.Reason != "invalid_url" {
+		t.Errorf("invalid result = %+v", results[2].Error)
+	}
+	if e := results[4].Error; e == nil || e.Reason != "upstream_status" {
+		t.Errorf("failed result = %+v", results[4].Error)
+	}
+	// 被拒绝和无效的 url 不交给 service
+	if len(images.called) != 3 {
+		t.Errorf("service called with %v", images.called)
+	}
+}
+
+func TestDescBatchLimits(t *testing.T) {
+	r := newImageRouter(t, &fakeImageService{}, func(conf *config.Config) {
+		conf.Set("batch.max_urls", 2)
+	})
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`[]`, "urls are required"},
+		{`{"url": "x"}`, ""},
+		{`["https://a.com", "https://b.com", "https://c.com"]`, "at most 2 urls"},
+	}
+	for _, tt := range tests {
+		w := serve(r, http.MethodPost, "/v1/desc/batch", strings.NewReader(tt.body))
+		body := decodeError(t, w)
+		if w.Code != http.StatusBadRequest || body.Reason != "invalid_request" || !strings.Contains(body.Message, tt.want) {
+			t.Errorf("%s: status = %d, body = %+v", tt.body, w.Code, body)
+		}
+	}
+}
+
+func TestDescBlocked(t *testing.T) {
+	r := newImageRouter(t, &fakeImageService{}, func(conf *config.Config) {
+		conf.Set("policy.allow", []string{"*.example.com"})
+	})
+	w := serve(r, http.MethodGet, "/v1/desc?url="+"https%3A%2F%2Fother.com%2F", nil)
+	body := decodeError(t, w)
+	if w.Code != http.StatusForbidden || body.Reason != "blocked" || body.Data["rule"] != "allow:<none>" {
+		t.Errorf("status = %d, body = %+v", w.Code, body)
+	}
+
+	for _, u := range []string{"", "ftp%3A%2F%2Fa.example.com", "%2Frelative"} {
+		w := serve(r, http.MethodGet, fmt.Sprintf("/v1/desc?url=%s", u), nil)
+		if body := decodeError(t, w); w.Code != http.StatusBadRequest || body.Reason != "invalid_url" {
+			t.Errorf("url %q: status = %d, body = %+v", u, w.Code, body)
+		}
+	}
+}
+
+// 处理函数只负责把服务返回的结果写成响应
+func TestImageRendering(t *testing.T) {
+	images := &fakeImageService{
+		image: &model.OgImage{Data: []byte("\x89PNG\r\n\x1a\n"), ContentType: "image/png", Cache: model.CacheHit},
+		descs: map[string]model.WebsiteDescType{"https://a.example.com/": {Title: "a", Logo: "https://a.example.com/icon.png"}},
+	}
+	r := newImageRouter(t, images, nil)
+
+	w := serve(r, http.MethodGet, "/v1/image?url=https%3A%2F%2Fa.example.com%2F", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "\x89PNG\r\n\x1a\n" {
+		t.Errorf("image response = %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	w = serve(r, http.MethodGet, "/v1/desc?url=https%3A%2F%2Fa.example.com%2F", nil)
+	var desc model.WebsiteDescType
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &desc) != nil || desc.Title != "a" || w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("desc response = %d %s", w.Code, w.Body)
+	}
+
+	images.image = nil
+	w = serve(r, http.MethodGet, "/v1/image?url=https%3A%2F%2Fa.example.com%2F", nil)
+	if body := decodeError(t, w); w.Code != http.StatusNotFound || body.Reason != "no_image" {
+		t.Errorf("no image response = %d %+v", w.Code, body)
+	}
+}
+
+func TestImageResize(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatal(err)
+	}
+	images := &fakeImageService{image: &model.OgImage{Data: buf.Bytes(), ContentType: "image/png", Cache: model.CacheHit}}
+	r := newImageRouter(t, images, nil)
+
+	w := serve(r, http.MethodGet, "/v1/image?url=https%3A%2F%2Fa.example.com%2F&width=100&height=100&fit=cover", nil)
+	config, _, err := image.DecodeConfig(w.Body)
+	if w.Code != http.StatusOK || err != nil || config.Width != 100 || config.Height != 100 {
+		t.Errorf("resized response = %d %v %+v", w.Code, err, config)
+	}
+
+	for _, query := range []string{"width=0", "height=5000", "width=abc", "fit=fill"} {
+		w = serve(r, http.MethodGet, "/v1/image?url=https%3A%2F%2Fa.example.com%2F&"+query, nil)
+		if body := decodeError(t, w); w.Code != http.StatusBadRequest || body.Reason != "invalid_request" {
+			t.Errorf("%s response = %d %+v", query, w.Code, body)
+		}
+	}
+
+	// 只有几十字节、但声明了 65535x65535 画布的 gif 不解码
+	buf.Reset()
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), []color.Color{color.Black}), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint16(data[6:], 65535)
+	binary.LittleEndian.PutUint16(data[8:], 65535)
+	images.image = &model.OgImage{Data: data, ContentType: "image/gif", Cache: model.CacheHit}
+	w = serve(r, http.MethodGet, "/v1/image?url=https%3A%2F%2Fa.example.com%2F&width=100", nil)
+	if body := decodeError(t, w); w.Code != http.StatusRequestEntityTooLarge || body.Reason != "too_large" {
+		t.Errorf("pixel limit response = %d %+v", w.Code, body)
+	}
+	// 不缩放时原样返回
+	if w = serve(r, http.MethodGet, "/v1/image?url=https%3A%2F%2Fa.example.com%2F", nil); w.Code != http.StatusOK {
+		t.Errorf("original response = %d", w.Code)
+	}
+}
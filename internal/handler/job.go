@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"ogimg/internal/middleware"
+	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/helper/resp"
+	"ogimg/pkg/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	*Handler
+	jobService service.JobService
+	policy     *policy.Policy
+}
+
+func NewJobHandler(handler *Handler, jobService service.JobService, policy *policy.Policy) *JobHandler {
+	return &JobHandler{
+		Handler:    handler,
+		jobService: jobService,
+		policy:     policy,
+	}
+}
+
+// CreateJob 异步抓取，适合响应很慢的站点，kind 为 desc（默认）或 image；
+// 携带 api key 时任务完成后通知该 key 注册的 webhook
+func (h *JobHandler) CreateJob(ctx *gin.Context) {
+	var params struct {
+		Url  string `json:"url" binding:"required"`
+		Kind string `json:"kind"`
+	}
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("invalid JSON body").Wrap(err), nil)
+		return
+	}
+	if data, err := checkUrl(ctx, h.policy, params.Url); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+
+	job, err := h.jobService.CreateJob(ctx.Request.Context(), ctx.GetString(middleware.ApiKeyOwner), params.Kind, params.Url)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// GetJob 查询任务，携带 api key 创建的任务需要使用同一个 key 查询
+func (h *JobHandler) GetJob(ctx *gin.Context) {
+	job, err := h.jobService.GetJob(ctx.Request.Context(), ctx.GetString(middleware.ApiKeyOwner), ctx.Param("id"))
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
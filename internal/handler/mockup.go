@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/helper/resp"
+	"ogimg/pkg/mockup"
+	"ogimg/pkg/policy"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MockupHandler struct {
+	*Handler
+	mockupService service.MockupService
+	policy        *policy.Policy
+}
+
+func NewMockupHandler(handler *Handler, mockupService service.MockupService, policy *policy.Policy) *MockupHandler {
+	return &MockupHandler{
+		Handler:       handler,
+		mockupService: mockupService,
+		policy:        policy,
+	}
+}
+
+// GetMockup 渲染链接在指定平台中的卡片效果
+func (h *MockupHandler) GetMockup(ctx *gin.Context) {
+	userUrl := ctx.Query("url")
+	if data, err := checkUrl(ctx, h.policy, userUrl); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+	platform := strings.ToLower(ctx.Query("platform"))
+	if _, ok := mockup.Layouts[platform]; !ok {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("platform must be one of "+strings.Join(mockup.Platforms(), ", ")), nil)
+		return
+	}
+
+	data, err := h.mockupService.Render(ctx.Request.Context(), userUrl, platform)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.Data(http.StatusOK, "image/png", data)
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"ogimg/internal/model"
+	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/card"
+	"ogimg/pkg/helper/resp"
+	"ogimg/pkg/policy"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OEmbedHandler struct {
+	*Handler
+	oembedService service.OEmbedService
+	policy        *policy.Policy
+}
+
+func NewOEmbedHandler(handler *Handler, oembedService service.OEmbedService, policy *policy.Policy) *OEmbedHandler {
+	return &OEmbedHandler{
+		Handler:       handler,
+		oembedService: oembedService,
+		policy:        policy,
+	}
+}
+
+// GetOEmbed oEmbed 提供方接口，任意 url 都返回基于预览信息的 rich 或 photo 响应
+func (h *OEmbedHandler) GetOEmbed(ctx *gin.Context) {
+	userUrl := ctx.Query("url")
+	if data, err := checkUrl(ctx, h.policy, userUrl); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+	// 按规范，不支持的格式返回 501
+	format := ctx.DefaultQuery("format", "json")
+	if format != "json" && format != "xml" {
+		resp.HandleAPIError(ctx, apierr.NotImplemented.WithMessage("format must be json or xml"), nil)
+		return
+	}
+	opts := model.OEmbedOptions{BaseUrl: h.baseUrl(), Theme: ctx.DefaultQuery("theme", card.ThemeLight)}
+	if !card.ValidTheme(opts.Theme) {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("theme must be one of "+strings.Join(card.Themes, ", ")), nil)
+		return
+	}
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"maxwidth", &opts.MaxWidth}, {"maxheight", &opts.MaxHeight}} {
+		raw := ctx.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage(p.name+" must be a positive integer"), nil)
+			return
+		}
+		*p.value = n
+	}
+
+	res, err := h.oembedService.GetOEmbed(ctx.Request.Context(), userUrl, opts)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	if format == "xml" {
+		ctx.XML(http.StatusOK, res)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -1,8 +1,8 @@
 package handler
 
 import (
-	"net/http"
 	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
 	"ogimg/pkg/helper/resp"
 
 	"github.com/gin-gonic/gin"
@@ -28,14 +28,14 @@ func (h *UserHandler) GetUserById(ctx *gin.Context) {
 		Id int64 `form:"id" binding:"required"`
 	}
 	if err := ctx.ShouldBind(&params); err != nil {
-		resp.HandleError(ctx, http.StatusBadRequest, 1, err.Error(), nil)
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("id must be an integer").Wrap(err), nil)
 		return
 	}
 
 	user, err := h.userService.GetUserById(params.Id)
 	h.logger.Info("GetUserByID", zap.Any("user", user))
 	if err != nil {
-		resp.HandleError(ctx, http.StatusInternalServerError, 1, err.Error(), nil)
+		resp.HandleAPIError(ctx, err, nil)
 		return
 	}
 	resp.HandleSuccess(ctx, user)
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeUserService struct{}
+
+func (fakeUserService) GetUserById(id int64) (*model.User, error) {
+	return &model.User{}, nil
+}
+
+func TestGetUser(t *testing.T) {
+	conf := newTestConfig(t)
+	h := NewUserHandler(NewHandler(log.NewLog(conf), conf), fakeUserService{})
+	r := gin.New()
+	r.GET("/user", h.GetUserById)
+
+	if w := serve(r, http.MethodGet, "/user?id=1", nil); w.Code != http.StatusOK {
+		t.Errorf("user response = %d %s", w.Code, w.Body)
+	}
+	// 参数错误按错误码目录返回，不再使用旧的数字错误码
+	for _, target := range []string{"/user", "/user?id=abc"} {
+		w := serve(r, http.MethodGet, target, nil)
+		if body := decodeError(t, w); w.Code != http.StatusBadRequest || body.Reason != "invalid_request" || body.Code != 1002 {
+			t.Errorf("%s response = %d %+v", target, w.Code, body)
+		}
+	}
+}
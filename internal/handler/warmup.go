@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/helper/resp"
+	"ogimg/pkg/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WarmupHandler struct {
+	*Handler
+	warmupService service.WarmupService
+	policy        *policy.Policy
+}
+
+func NewWarmupHandler(handler *Handler, warmupService service.WarmupService, policy *policy.Policy) *WarmupHandler {
+	return &WarmupHandler{
+		Handler:       handler,
+		warmupService: warmupService,
+		policy:        policy,
+	}
+}
+
+// StartWarmup 创建预热任务，source 为 sitemap、sitemap index、RSS 或 Atom 的地址
+func (h *WarmupHandler) StartWarmup(ctx *gin.Context) {
+	var params struct {
+		Source      string `json:"source" binding:"required"`
+		Concurrency int    `json:"concurrency"`
+	}
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("invalid JSON body").Wrap(err), nil)
+		return
+	}
+	if data, err := checkUrl(ctx, h.policy, params.Source); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+	if max := h.conf.GetInt("warmup.max_concurrency"); params.Concurrency > max {
+		params.Concurrency = max
+	}
+
+	job, err := h.warmupService.StartWarmup(params.Source, params.Concurrency)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// GetWarmup 查询预热任务的进度和失败的 url
+func (h *WarmupHandler) GetWarmup(ctx *gin.Context) {
+	job, ok := h.warmupService.GetWarmup(ctx.Param("id"))
+	if !ok {
+		resp.HandleAPIError(ctx, apierr.NotFound.WithMessage("warmup job not found"), nil)
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"ogimg/internal/middleware"
+	"ogimg/internal/service"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/helper/resp"
+	"ogimg/pkg/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	*Handler
+	webhookService service.WebhookService
+	policy         *policy.Policy
+}
+
+func NewWebhookHandler(handler *Handler, webhookService service.WebhookService, policy *policy.Policy) *WebhookHandler {
+	return &WebhookHandler{
+		Handler:        handler,
+		webhookService: webhookService,
+		policy:         policy,
+	}
+}
+
+// CreateWebhook 为当前 api key 注册回调地址，签名密钥只在这里返回一次
+func (h *WebhookHandler) CreateWebhook(ctx *gin.Context) {
+	var params struct {
+		Url    string   `json:"url" binding:"required"`
+		Events []string `json:"events"`
+		Urls   []string `json:"urls"`
+	}
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("invalid JSON body").Wrap(err), nil)
+		return
+	}
+	if data, err := checkUrl(ctx, h.policy, params.Url); err != nil {
+		resp.HandleAPIError(ctx, err, data)
+		return
+	}
+
+	if max := h.conf.GetInt("webhooks.max_urls"); len(params.Urls) > max {
+		resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage(fmt.Sprintf("at most %d urls are allowed", max)), nil)
+		return
+	}
+	for _, u := range params.Urls {
+		if data, err := checkUrl(ctx, h.policy, u); err != nil {
+			resp.HandleAPIError(ctx, err, data)
+			return
+		}
+	}
+
+	hook, err := h.webhookService.CreateWebhook(ctx.Request.Context(), ctx.GetString(middleware.ApiKeyOwner), params.Url, params.Events, params.Urls)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.JSON(http.StatusCreated, hook)
+}
+
+func (h *WebhookHandler) ListWebhooks(ctx *gin.Context) {
+	hooks, err := h.webhookService.ListWebhooks(ctx.Request.Context(), ctx.GetString(middleware.ApiKeyOwner))
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.JSON(http.StatusOK, hooks)
+}
+
+func (h *WebhookHandler) DeleteWebhook(ctx *gin.Context) {
+	if err := h.webhookService.DeleteWebhook(ctx.Request.Context(), ctx.GetString(middleware.ApiKeyOwner), ctx.Param("id")); err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// ListDeliveries 投递日志，?dead=true 时只返回重试耗尽的死信
+func (h *WebhookHandler) ListDeliveries(ctx *gin.Context) {
+	dead := ctx.Query("dead") == "true"
+	deliveries, err := h.webhookService.ListDeliveries(ctx.Request.Context(), ctx.GetString(middleware.ApiKeyOwner), ctx.Param("id"), dead)
+	if err != nil {
+		resp.HandleAPIError(ctx, err, nil)
+		return
+	}
+	ctx.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/config"
+	"ogimg/pkg/helper/resp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApiKeyOwner gin.Context 中保存 api key 标识的键
+const ApiKeyOwner = "api_key_owner"
+
+// APIKey 校验 Authorization: Bearer <key> 或 X-API-Key 中的 key 是否在 auth.api_keys 中，
+// required 为 false 时未携带 key 也放行，但携带了错误的 key 仍然拒绝
+func APIKey(conf *config.Config, required bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := requestKey(ctx)
+		if key == "" && !required {
+			ctx.Next()
+			return
+		}
+		if key == "" || !validKey(conf.GetStringSlice("auth.api_keys"), key) {
+			resp.HandleAPIError(ctx, apierr.Unauthorized, nil)
+			ctx.Abort()
+			return
+		}
+		ctx.Set(ApiKeyOwner, KeyOwner(key))
+		ctx.Next()
+	}
+}
+
+// AdminKey 校验请求中的 key 是否在 auth.admin_keys 中，未配置 admin_keys 时管理接口全部拒绝
+func AdminKey(conf *config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := requestKey(ctx)
+		if key == "" || !validKey(conf.GetStringSlice("auth.admin_keys"), key) {
+			resp.HandleAPIError(ctx, apierr.Unauthorized, nil)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// MetricsKey 配置了 metrics.keys 时要求请求携带其中之一，为空时不校验
+func MetricsKey(conf *config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		keys := conf.GetStringSlice("metrics.keys")
+		if len(keys) > 0 && !validKey(keys, requestKey(ctx)) {
+			resp.HandleAPIError(ctx, apierr.Unauthorized, nil)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// requestKey 读取 X-API-Key 或 Authorization: Bearer <key>
+func requestKey(ctx *gin.Context) string {
+	key := ctx.GetHeader("X-API-Key")
+	if auth := ctx.GetHeader("Authorization"); key == "" && strings.HasPrefix(auth, "Bearer ") {
+		key = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return key
+}
+
+// KeyOwner 返回 api key 的标识，存储时只使用标识而不保存 key 本身
+func KeyOwner(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func validKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
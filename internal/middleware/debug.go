@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+	"ogimg/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DebugHeader 单个请求开启 debug 日志的请求头，格式同 webhook 签名：
+// t=<unix>,v1=hex(HMAC-SHA256(log.debug_secret, "<t>.<path>"))
+const DebugHeader = "X-Ogimg-Debug"
+
+// DebugLog 签名有效时当前请求输出 debug 日志，未配置 log.debug_secret 时忽略该请求头，需放在 RequestId 之后
+func DebugLog(logger *log.Logger, conf *config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.GetHeader(DebugHeader)
+		secret := conf.GetString("log.debug_secret")
+		if header == "" || secret == "" {
+			ctx.Next()
+			return
+		}
+		if err := webhook.Verify(secret, header, []byte(ctx.Request.URL.Path), conf.GetDuration("log.debug_tolerance")); err != nil {
+			logger.WithContext(ctx).Warn("Invalid debug header", zap.Error(err))
+			ctx.Next()
+			return
+		}
+		logger.EnableDebug(ctx)
+		logger.WithContext(ctx).Debug("Debug logging enabled for request")
+		ctx.Next()
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"ogimg/pkg/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 按路由模板统计请求数、耗时和响应字节数，未匹配的路由统一记为 unmatched
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(ctx.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(route, ctx.Request.Method, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, ctx.Request.Method, status).Observe(time.Since(start).Seconds())
+		if size := ctx.Writer.Size(); size > 0 {
+			metrics.HTTPResponseBytes.WithLabelValues(route).Add(float64(size))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"ogimg/pkg/helper/uuid"
+	"ogimg/pkg/log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	RequestIdHeader = "X-Request-ID"
+	// TargetHost 请求抓取的站点，由 handler 写入，用于访问日志
+	TargetHost = "target_host"
+)
+
+// RequestId 沿用请求中合法的 X-Request-ID，否则生成新的，并写入响应头和请求级 logger
+func RequestId(logger *log.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIdHeader)
+		if !validRequestId(id) {
+			id = uuid.GenUUID()
+		}
+		ctx.Header(RequestIdHeader, id)
+		logger.NewContext(ctx, zap.String("request_id", id))
+		ctx.Next()
+	}
+}
+
+// AccessLog 每个请求输出一行访问日志，需放在 RequestId 之后
+func AccessLog(logger *log.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		fields := []zap.Field{
+			zap.String("method", ctx.Request.Method),
+			zap.String("route", route),
+			zap.String("path", ctx.Request.URL.Path),
+			zap.Int("status", ctx.Writer.Status()),
+			zap.Duration("latency", time.Since(start)),
+			zap.Int("bytes", ctx.Writer.Size()),
+			zap.String("client_ip", ctx.ClientIP()),
+		}
+		if cache := ctx.Writer.Header().Get("X-Cache"); cache != "" {
+			fields = append(fields, zap.String("cache", cache))
+		}
+		if host := ctx.GetString(TargetHost); host != "" {
+			fields = append(fields, zap.String("target_host", host))
+		}
+		if len(ctx.Errors) > 0 {
+			fields = append(fields, zap.String("errors", ctx.Errors.String()))
+		}
+		logger.WithContext(ctx).Info("access", fields...)
+	}
+}
+
+// validRequestId 只接受长度合理的可见 ASCII 字符，避免日志注入
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// newLogRouter 日志写入临时文件，返回读取日志行的函数
+func newLogRouter(t *testing.T) (*gin.Engine, func() []map[string]interface{}) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	file := filepath.Join(t.TempDir(), "server.log")
+	conf := config.New(viper.New())
+	conf.Set("log.log_file_name", file)
+	conf.Set("log.log_level", "info")
+	conf.Set("log.encoding", "json")
+	logger := log.NewLog(conf)
+
+	r := gin.New()
+	r.Use(RequestId(logger), AccessLog(logger))
+	r.GET("/items/:id", func(ctx *gin.Context) {
+		ctx.Set(TargetHost, "example.com")
+		ctx.Header("X-Cache", "HIT")
+		// service 层只拿到 request context
+		logger.WithContext(ctx.Request.Context()).Info("handled")
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	lines := func() []map[string]interface{} {
+		t.Helper()
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var entries []map[string]interface{}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("log line %q: %v", scanner.Text(), err)
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	return r, lines
+}
+
+func TestRequestIdAndAccessLog(t *testing.T) {
+	r, lines := newLogRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+	req.Header.Set(RequestIdHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(RequestIdHeader); got != "req-1" {
+		t.Errorf("response request id = %q", got)
+	}
+
+	entries := lines()
+	if len(entries) != 2 {
+		t.Fatalf("got %d log lines: %v", len(entries), entries)
+	}
+	if handled := entries[0]; handled["msg"] != "handled" || handled["request_id"] != "req-1" {
+		t.Errorf("handler log = %v", handled)
+	}
+	access := entries[1]
+	want := map[string]interface{}{
+		"msg":         "access",
+		"request_id":  "req-1",
+		"method":      "GET",
+		"route":       "/items/:id",
+		"path":        "/items/42",
+		"status":      float64(http.StatusOK),
+		"bytes":       float64(2),
+		"cache":       "HIT",
+		"target_host": "example.com",
+	}
+	for key, value := range want {
+		if access[key] != value {
+			t.Errorf("access %s = %v, want %v", key, access[key], value)
+		}
+	}
+	if _, ok := access["latency"]; !ok {
+		t.Error("access log has no latency")
+	}
+}
+
+func TestRequestIdGenerated(t *testing.T) {
+	r, lines := newLogRouter(t)
+
+	for _, id := range []string{"", "has space", "line\nbreak", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		if id != "" {
+			req.Header[RequestIdHeader] = []string{id}
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		got := w.Header().Get(RequestIdHeader)
+		if got == "" || got == id || !validRequestId(got) {
+			t.Errorf("incoming %q: response request id = %q", id, got)
+		}
+	}
+
+	entries := lines()
+	if len(entries) != 4 {
+		t.Fatalf("got %d log lines", len(entries))
+	}
+	for _, entry := range entries {
+		if entry["route"] != "unmatched" || entry["status"] != float64(http.StatusNotFound) || entry["request_id"] == "" {
+			t.Errorf("access log = %v", entry)
+		}
+	}
+}
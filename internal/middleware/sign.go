@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"io"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/config"
+	"ogimg/pkg/helper/resp"
+	"ogimg/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// AppKeyHeader 请求签名使用的 app key
+	AppKeyHeader = "X-Ogimg-App-Key"
+	// SignatureHeader 请求签名，格式同 webhook 签名：
+	// t=<unix>,v1=hex(HMAC-SHA256(security.api_sign.app_security, "<t>.<method> <path?query>\n<body>"))
+	SignatureHeader = webhook.SignatureHeader
+)
+
+// Sign security.api_sign.enabled 为 true 时校验请求签名，未开启时直接放行
+func Sign(conf *config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !conf.GetBool("security.api_sign.enabled") {
+			ctx.Next()
+			return
+		}
+		appKey := conf.GetString("security.api_sign.app_key")
+		if subtle.ConstantTimeCompare([]byte(ctx.GetHeader(AppKeyHeader)), []byte(appKey)) != 1 {
+			resp.HandleAPIError(ctx, apierr.Unauthorized.WithMessage("missing or invalid app key"), nil)
+			ctx.Abort()
+			return
+		}
+
+		var body []byte
+		if ctx.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(ctx.Request.Body); err != nil {
+				resp.HandleAPIError(ctx, apierr.InvalidRequest.WithMessage("cannot read request body").Wrap(err), nil)
+				ctx.Abort()
+				return
+			}
+			// 后续的处理函数需要再次读取请求体
+			ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		payload := webhook.RequestPayload(ctx.Request.Method, ctx.Request.RequestURI, body)
+		err := webhook.Verify(conf.GetString("security.api_sign.app_security"), ctx.GetHeader(SignatureHeader), payload,
+			conf.GetDuration("security.api_sign.tolerance"))
+		if err != nil {
+			resp.HandleAPIError(ctx, apierr.Unauthorized.WithMessage("invalid request signature").Wrap(err), nil)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ogimg/pkg/config"
+	"ogimg/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+func newSignRouter(enabled bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	conf := config.New(viper.New())
+	conf.Set("security.api_sign.enabled", enabled)
+	conf.Set("security.api_sign.app_key", "app")
+	conf.Set("security.api_sign.app_security", "secret")
+	conf.Set("security.api_sign.tolerance", "300s")
+	r := gin.New()
+	r.Use(Sign(conf))
+	r.POST("/echo", func(ctx *gin.Context) {
+		body, _ := io.ReadAll(ctx.Request.Body)
+		ctx.String(http.StatusOK, string(body))
+	})
+	return r
+}
+
+func signedRequest(appKey, secret string, t time.Time, target, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set(AppKeyHeader, appKey)
+	req.Header.Set(SignatureHeader, webhook.Sign(secret, t, webhook.RequestPayload(http.MethodPost, target, []byte(body))))
+	return req
+}
+
+func TestSign(t *testing.T) {
+	r := newSignRouter(true)
+	tampered := signedRequest("app", "secret", time.Now(), "/echo?url=a", `["a"]`)
+	tampered.URL.RawQuery = "url=b"
+	tampered.RequestURI = "/echo?url=b"
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"valid", signedRequest("app", "secret", time.Now(), "/echo?url=a", `["a"]`), http.StatusOK},
+		{"wrong app key", signedRequest("other", "secret", time.Now(), "/echo?url=a", `["a"]`), http.StatusUnauthorized},
+		{"wrong secret", signedRequest("app", "other", time.Now(), "/echo?url=a", `["a"]`), http.StatusUnauthorized},
+		{"expired", signedRequest("app", "secret", time.Now().Add(-time.Hour), "/echo?url=a", `["a"]`), http.StatusUnauthorized},
+		{"tampered query", tampered, http.StatusUnauthorized},
+		{"unsigned", httptest.NewRequest(http.MethodPost, "/echo", nil), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, tt.req)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, w.Code, tt.status, w.Body.String())
+		}
+		// 校验签名后处理函数仍能读取请求体
+		if tt.status == http.StatusOK && w.Body.String() != `["a"]` {
+			t.Errorf("%s: body = %q", tt.name, w.Body.String())
+		}
+	}
+}
+
+func TestSignDisabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	newSignRouter(false).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("x")))
+	if w.Code != http.StatusOK || w.Body.String() != "x" {
+		t.Errorf("disabled signing should pass through, got %d %q", w.Code, w.Body.String())
+	}
+}
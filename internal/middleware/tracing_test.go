@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer tp.Shutdown(context.Background())
+
+	gin.SetMode(gin.TestMode)
+	conf := config.New(viper.New())
+	conf.Set("log.log_level", "error")
+	conf.Set("log.log_file_name", t.TempDir()+"/server.log")
+	logger := log.NewLog(conf)
+	r := gin.New()
+	r.Use(RequestId(logger), Tracing(logger))
+	var handlerSpan trace.SpanContext
+	r.GET("/items/:id", func(ctx *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(ctx.Request.Context())
+		ctx.Set(TargetHost, "example.com")
+		ctx.Status(http.StatusBadGateway)
+	})
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	req.Header.Set(RequestIdHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /items/:id" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("span = %s %v", span.Name(), span.SpanKind())
+	}
+	// 沿用请求中的 traceparent，handler 拿到的是 server span
+	if span.SpanContext().TraceID().String() != traceId || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("span trace = %s parent = %s", span.SpanContext().TraceID(), span.Parent().SpanID())
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("handler span = %s, want %s", handlerSpan.SpanID(), span.SpanContext().SpanID())
+	}
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	for key, want := range map[string]string{
+		"http.route":                "/items/:id",
+		"http.response.status_code": "502",
+		"request_id":                "req-1",
+		"target.host":               "example.com",
+	} {
+		if attrs[key] != want {
+			t.Errorf("%s = %q, want %q", key, attrs[key], want)
+		}
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("status = %v", span.Status())
+	}
+}
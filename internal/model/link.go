@@ -0,0 +1,83 @@
+package model
+
+import "time"
+
+// LinkFetchOk 抓取成功时 LinkFetch.Status 的取值，失败时为错误的 reason
+const LinkFetchOk = "ok"
+
+// LinkRecord 解析过的 url 及最近一次成功提取的预览信息，保存在数据库中，不受 redis 清空影响
+type LinkRecord struct {
+	Id           int64         `gorm:"primaryKey" json:"id"`
+	UrlHash      string        `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Url          string        `gorm:"type:text;not null" json:"url"`
+	CanonicalUrl string        `gorm:"type:text" json:"canonical_url"`
+	Title        string        `gorm:"size:1024" json:"title"`
+	Description  string        `gorm:"type:text" json:"description"`
+	Logo         string        `gorm:"type:text" json:"logo"`
+	Image        string        `gorm:"type:text" json:"image"`
+	ImageHash    string        `gorm:"size:64" json:"image_hash,omitempty"`
+	ImagePHash   string        `gorm:"column:image_phash;size:16" json:"image_phash,omitempty"`
+	Status       string        `gorm:"size:64" json:"status"`
+	FirstSeenAt  time.Time     `json:"first_seen_at"`
+	LastSeenAt   time.Time     `gorm:"index" json:"last_seen_at"`
+	Fetches      []LinkFetch   `gorm:"foreignKey:LinkId;constraint:OnDelete:CASCADE" json:"fetches,omitempty"`
+	Versions     []LinkVersion `gorm:"foreignKey:LinkId;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (l *LinkRecord) TableName() string {
+	return "link_records"
+}
+
+// LinkFetch 一次抓取的结果
+type LinkFetch struct {
+	Id        int64     `gorm:"primaryKey" json:"-"`
+	LinkId    int64     `gorm:"not null;index" json:"-"`
+	Status    string    `gorm:"size:64;not null" json:"status"`
+	Error     string    `gorm:"size:1024" json:"error,omitempty"`
+	FetchedAt time.Time `gorm:"index" json:"fetched_at"`
+}
+
+func (f *LinkFetch) TableName() string {
+	return "link_fetches"
+}
+
+// LinkVersion 预览信息的一个版本，抓取结果与上一个版本不同时新增
+type LinkVersion struct {
+	Id           int64     `gorm:"primaryKey" json:"id"`
+	LinkId       int64     `gorm:"not null;index" json:"-"`
+	CanonicalUrl string    `gorm:"type:text" json:"canonical_url"`
+	Title        string    `gorm:"size:1024" json:"title"`
+	Description  string    `gorm:"type:text" json:"description"`
+	Logo         string    `gorm:"type:text" json:"logo"`
+	Image        string    `gorm:"type:text" json:"image"`
+	ImageHash    string    `gorm:"size:64" json:"image_hash,omitempty"`
+	ImagePHash   string    `gorm:"column:image_phash;size:16" json:"image_phash,omitempty"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+func (v *LinkVersion) TableName() string {
+	return "link_versions"
+}
+
+// LinkHistory 一个 url 的版本时间线，Versions 按时间倒序
+type LinkHistory struct {
+	Url         string            `json:"url"`
+	FirstSeenAt time.Time         `json:"first_seen_at"`
+	LastSeenAt  time.Time         `json:"last_seen_at"`
+	Versions    []LinkVersionDiff `json:"versions"`
+}
+
+// LinkVersionDiff 版本及其相对上一个版本的变化，ImageDistance 为两张图片感知哈希的汉明距离
+type LinkVersionDiff struct {
+	LinkVersion
+	Changes       []FieldChange `json:"changes"`
+	ImageDistance *int          `json:"image_distance,omitempty"`
+}
+
+// LinkChange since 之后预览发生变化的 url，Changes 为 since 时的版本与最新版本的差异
+type LinkChange struct {
+	Url           string        `json:"url"`
+	ChangedAt     time.Time     `json:"changed_at"`
+	Changes       []FieldChange `json:"changes"`
+	ImageDistance *int          `json:"image_distance,omitempty"`
+}
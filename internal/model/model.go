@@ -1,7 +1,244 @@
 package model
 
+import (
+	"encoding/json"
+	"encoding/xml"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+	"time"
+)
+
+// CacheStatus 结果是否来自缓存，通过 X-Cache 响应头返回
+type CacheStatus string
+
+const (
+	CacheHit  CacheStatus = "HIT"
+	CacheMiss CacheStatus = "MISS"
+	// CacheStale 缓存已过期且重新获取失败，返回旧的结果
+	CacheStale CacheStatus = "STALE"
+)
+
 type WebsiteDescType struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Logo        string `json:"logo"`
 }
+
+type BatchDescResult struct {
+	Url   string           `json:"url"`
+	Desc  *WebsiteDescType `json:"desc,omitempty"`
+	Error *apierr.Error    `json:"error,omitempty"`
+}
+
+// OgImage og:image 图片，Meta 只在未命中缓存时有值
+type OgImage struct {
+	Data        []byte
+	ContentType string
+	Meta        *extract.Metadata
+	Cache       CacheStatus
+}
+
+// OgDesc 网站描述，Meta 只在未命中缓存时有值
+type OgDesc struct {
+	Desc  WebsiteDescType
+	Meta  *extract.Metadata
+	Cache CacheStatus
+}
+
+// CacheEntry 缓存项信息，TTL 为 -1 表示永不过期
+type CacheEntry struct {
+	Kind   string        `json:"kind"`
+	Key    string        `json:"key"`
+	Exists bool          `json:"exists"`
+	Size   int64         `json:"size"`
+	TTL    time.Duration `json:"ttl"`
+}
+
+const (
+	WarmupRunning = "running"
+	WarmupDone    = "done"
+	WarmupFailed  = "failed"
+)
+
+// WarmupJob 缓存预热任务，Failures 记录失败的 url
+type WarmupJob struct {
+	Id         string         `json:"id"`
+	Source     string         `json:"source"`
+	Status     string         `json:"status"`
+	Total      int            `json:"total"`
+	Done       int            `json:"done"`
+	Failed     int            `json:"failed"`
+	Failures   []WarmupResult `json:"failures"`
+	Error      *apierr.Error  `json:"error,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+// WarmupResult 单个 url 的预热结果，Kind 为失败的缓存类型（desc 或 image）
+type WarmupResult struct {
+	Url   string        `json:"url"`
+	Kind  string        `json:"kind,omitempty"`
+	Error *apierr.Error `json:"error,omitempty"`
+}
+
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+
+	JobKindDesc  = "desc"
+	JobKindImage = "image"
+)
+
+// Job 异步抓取任务，完成后 Desc 或 Image 中有结果
+type Job struct {
+	Id         string           `json:"id"`
+	Kind       string           `json:"kind"`
+	Url        string           `json:"url"`
+	Owner      string           `json:"owner,omitempty"`
+	Status     string           `json:"status"`
+	Desc       *WebsiteDescType `json:"desc,omitempty"`
+	Image      *JobImage        `json:"image,omitempty"`
+	Error      *apierr.Error    `json:"error,omitempty"`
+	Attempts   int              `json:"attempts,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// JobImage 图片已写入缓存，通过 Url 获取时命中缓存
+type JobImage struct {
+	Url         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+}
+
+const (
+	EventJobCompleted   = "job.completed"
+	EventPreviewChanged = "preview.changed"
+
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryRetrying  = "retrying"
+	DeliveryDead      = "dead"
+)
+
+// Webhook 按 api key 注册的回调地址，Secret 只在创建时返回
+type Webhook struct {
+	Id     string   `json:"id"`
+	Owner  string   `json:"-"`
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+	// Urls preview.changed 只通知这些页面的变化
+	Urls      []string  `json:"urls,omitempty"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery 一次事件投递，Payload 为签名发送的请求体
+type WebhookDelivery struct {
+	Id             string          `json:"id"`
+	WebhookId      string          `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookEvent 投递给接收方的请求体
+type WebhookEvent struct {
+	Id        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// PreviewChange 刷新时检测到的预览变化
+type PreviewChange struct {
+	Url     string          `json:"url"`
+	Changes []FieldChange   `json:"changes"`
+	Preview PreviewSnapshot `json:"preview"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// PreviewSnapshot 用于比较的预览信息
+type PreviewSnapshot struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+}
+
+const (
+	HealthOk      = "ok"
+	HealthSkipped = "skipped"
+	HealthFailed  = "failed"
+
+	ReadyOk          = "ok"
+	ReadyUnavailable = "unavailable"
+)
+
+// Readiness 就绪检查结果，任意一项 failed 时 Status 为 unavailable
+type Readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+type HealthCheck struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Card 可嵌入页面的预览卡片，Html 为渲染好的片段，其余字段已清理
+type Card struct {
+	Url         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Logo        string `json:"logo,omitempty"`
+	Image       string `json:"image,omitempty"`
+	OEmbed      string `json:"oembed"`
+	Theme       string `json:"theme"`
+	Html        string `json:"html"`
+}
+
+const (
+	OEmbedRich  = "rich"
+	OEmbedPhoto = "photo"
+)
+
+// OEmbed oEmbed 1.0 响应，rich 类型的 html 为 /v1/card 的 iframe，photo 类型的 url 为缩放后的图片
+type OEmbed struct {
+	XMLName         xml.Name `json:"-" xml:"oembed"`
+	Type            string   `json:"type" xml:"type"`
+	Version         string   `json:"version" xml:"version"`
+	Title           string   `json:"title,omitempty" xml:"title,omitempty"`
+	ProviderName    string   `json:"provider_name" xml:"provider_name"`
+	ProviderUrl     string   `json:"provider_url" xml:"provider_url"`
+	CacheAge        int      `json:"cache_age,omitempty" xml:"cache_age,omitempty"`
+	Url             string   `json:"url,omitempty" xml:"url,omitempty"`
+	Html            string   `json:"html,omitempty" xml:"html,omitempty"`
+	Width           int      `json:"width" xml:"width"`
+	Height          int      `json:"height" xml:"height"`
+	ThumbnailUrl    string   `json:"thumbnail_url,omitempty" xml:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int      `json:"thumbnail_width,omitempty" xml:"thumbnail_width,omitempty"`
+	ThumbnailHeight int      `json:"thumbnail_height,omitempty" xml:"thumbnail_height,omitempty"`
+}
+
+// OEmbedOptions BaseUrl 为服务对外的地址，MaxWidth、MaxHeight 为 0 时不限制
+type OEmbedOptions struct {
+	BaseUrl   string
+	MaxWidth  int
+	MaxHeight int
+	Theme     string
+}
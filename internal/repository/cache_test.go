@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/config"
+)
+
+// 每次请求都会读写缓存，这些日志只在 debug 级别输出
+func TestCacheLogLevel(t *testing.T) {
+	var logFile string
+	repo, _ := newTestRepository(t, func(conf *config.Config) {
+		conf.Set("log.log_level", "info")
+		logFile = conf.GetString("log.log_file_name")
+	})
+	ctx := context.Background()
+	access := func() {
+		repo.SetWebsiteOgImgToCache(ctx, "https://example.com", []byte("img"))
+		repo.GetWebsiteOgImgFromCache(ctx, "https://example.com")
+		repo.SetWebsiteIconToCache(ctx, "https://example.com", []byte("icon"))
+		repo.GetWebsiteIconFromCache(ctx, "https://example.com")
+		repo.SetWebSiteDescToCache(ctx, "https://example.com", model.WebsiteDescType{Title: "t"})
+		repo.GetWebSiteDescToCache(ctx, "https://example.com")
+	}
+	logged := func() string {
+		data, err := os.ReadFile(logFile)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	access()
+	if out := logged(); strings.Contains(out, "from cache") || strings.Contains(out, "to cache") {
+		t.Errorf("cache access logged at info level:\n%s", out)
+	}
+
+	if err := repo.logger.SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	access()
+	out := logged()
+	if n := strings.Count(out, "Get from cache"); n != 3 {
+		t.Errorf("got %d Get from cache lines at debug level, want 3", n)
+	}
+	if n := strings.Count(out, "Set to cache"); n != 3 {
+		t.Errorf("got %d Set to cache lines at debug level, want 3", n)
+	}
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	repo, mr := newTestRepository(t, nil)
+	ctx := context.Background()
+	const url = "https://example.com/page"
+
+	if val, stale, err := repo.GetWebsiteOgImgFromCache(ctx, url); val != nil || stale || err != nil {
+		t.Errorf("empty cache = %q, %v, %v", val, stale, err)
+	}
+	repo.SetWebsiteOgImgToCache(ctx, url, []byte("img"))
+	repo.SetWebsiteIconToCache(ctx, url, []byte("icon"))
+	repo.SetWebSiteDescToCache(ctx, url, model.WebsiteDescType{Title: "t"})
+
+	if val, _, err := repo.GetWebsiteOgImgFromCache(ctx, url); string(val) != "img" || err != nil {
+		t.Errorf("image = %q, %v", val, err)
+	}
+	if val, _, err := repo.GetWebsiteIconFromCache(ctx, url); string(val) != "icon" || err != nil {
+		t.Errorf("icon = %q, %v", val, err)
+	}
+	if desc, _, err := repo.GetWebSiteDescToCache(ctx, url); desc.Title != "t" || err != nil {
+		t.Errorf("desc = %+v, %v", desc, err)
+	}
+
+	entries, err := repo.InspectCache(ctx, url)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("entries = %+v, %v", entries, err)
+	}
+	for _, entry := range entries {
+		if !entry.Exists || entry.Size == 0 || entry.TTL <= 0 {
+			t.Errorf("entry = %+v", entry)
+		}
+	}
+
+	if n, err := repo.DeleteWebsiteCache(ctx, url); n != 3 || err != nil {
+		t.Errorf("deleted %d, %v", n, err)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("keys left after delete: %v", keys)
+	}
+}
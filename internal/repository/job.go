@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	JobQueueRedis  = "redis"
+	JobQueueMemory = "memory"
+
+	jobQueueKey      = "jobs:queue"
+	jobProcessingKey = "jobs:processing"
+	jobLeasePrefix   = "jobs:lease:"
+
+	// jobLeaseGrace 租约在 jobs.timeout 之外额外保留的时间，用于写入结果
+	jobLeaseGrace = 30 * time.Second
+)
+
+// requeueScript 从处理中列表移回队列，多个节点同时回收时只有一个节点成功
+var requeueScript = redis.NewScript(`
+if redis.call("LREM", KEYS[1], 1, ARGV[1]) > 0 then
+	redis.call("RPUSH", KEYS[2], ARGV[1])
+	return 1
+end
+return 0`)
+
+type JobRepository interface {
+	// Enqueue 保存任务并放入队列
+	Enqueue(ctx context.Context, job *model.Job) error
+	// Dequeue 阻塞直到取出一个任务或 ctx 结束，任务在 Ack 之前保留在处理中列表
+	Dequeue(ctx context.Context) (*model.Job, error)
+	// Ack 任务执行结束后从处理中列表删除
+	Ack(ctx context.Context, id string) error
+	// Reap 把租约已过期（例如 worker 崩溃）的任务放回队列，返回放回的数量
+	Reap(ctx context.Context) (int, error)
+	Save(ctx context.Context, job *model.Job) error
+	// Get 任务不存在或已过期时返回 nil
+	Get(ctx context.Context, id string) (*model.Job, error)
+}
+
+// NewJobRepository 按 jobs.queue 选择队列，redis 可供多个节点共享，memory 仅适合单机部署
+func NewJobRepository(repository *Repository) JobRepository {
+	if repository.conf.GetString("jobs.queue") == JobQueueMemory {
+		return &memoryJobRepository{
+			Repository: repository,
+			queue:      make(chan string, repository.conf.GetInt("jobs.queue_size")),
+			jobs:       map[string]*model.Job{},
+		}
+	}
+	return &redisJobRepository{Repository: repository, suspects: map[string]bool{}}
+}
+
+type redisJobRepository struct {
+	*Repository
+
+	mu sync.Mutex
+	// suspects 上次回收时没有租约的任务，连续两次没有租约才放回队列，
+	// 避免把刚移入处理中列表、还未写入租约的任务放回
+	suspects map[string]bool
+}
+
+func (r *redisJobRepository) Enqueue(ctx context.Context, job *model.Job) error {
+	if err := r.Save(ctx, job); err != nil {
+		return err
+	}
+	return r.rdb.LPush(ctx, jobQueueKey, job.Id).Err()
+}
+
+func (r *redisJobRepository) Dequeue(ctx context.Context) (*model.Job, error) {
+	for {
+		// 定时返回以便检查 ctx
+		id, err := r.rdb.BLMove(ctx, jobQueueKey, jobProcessingKey, "RIGHT", "LEFT", 5*time.Second).Result()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		lease := r.conf.GetDuration("jobs.timeout") + jobLeaseGrace
+		if err := r.rdb.Set(ctx, jobLeasePrefix+id, 1, lease).Err(); err != nil {
+			return nil, err
+		}
+		job, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job == nil {
+			r.logger.WithContext(ctx).Warn("Dequeue expired job", zap.String("id", id))
+			if err := r.Ack(ctx, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return job, nil
+	}
+}
+
+func (r *redisJobRepository) Ack(ctx context.Context, id string) error {
+	pipe := r.rdb.TxPipeline()
+	pipe.LRem(ctx, jobProcessingKey, 1, id)
+	pipe.Del(ctx, jobLeasePrefix+id)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisJobRepository) Reap(ctx context.Context) (int, error) {
+	ids, err := r.rdb.LRange(ctx, jobProcessingKey, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	suspects := map[string]bool{}
+	requeued := 0
+	for _, id := range ids {
+		n, err := r.rdb.Exists(ctx, jobLeasePrefix+id).Result()
+		if err != nil {
+			return requeued, err
+		}
+		if n > 0 {
+			continue
+		}
+		if !r.suspects[id] {
+			suspects[id] = true
+			continue
+		}
+		moved, err := requeueScript.Run(ctx, r.rdb, []string{jobProcessingKey, jobQueueKey}, id).Int()
+		if err != nil {
+			return requeued, err
+		}
+		if moved > 0 {
+			r.logger.WithContext(ctx).Warn("Requeue abandoned job", zap.String("id", id))
+			requeued++
+		}
+	}
+	r.suspects = suspects
+	return requeued, nil
+}
+
+func (r *redisJobRepository) Save(ctx context.Context, job *model.Job) error {
+	val, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, "job:"+job.Id, val, r.conf.GetDuration("jobs.ttl")).Err()
+}
+
+func (r *redisJobRepository) Get(ctx context.Context, id string) (*model.Job, error) {
+	val, err := r.rdb.Get(ctx, "job:"+id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var job model.Job
+	if err := json.Unmarshal(val, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+type memoryJobRepository struct {
+	*Repository
+	queue chan string
+
+	mu   sync.Mutex
+	jobs map[string]*model.Job
+}
+
+func (r *memoryJobRepository) Enqueue(ctx context.Context, job *model.Job) error {
+	if err := r.Save(ctx, job); err != nil {
+		return err
+	}
+	select {
+	case r.queue <- job.Id:
+		return nil
+	default:
+		r.mu.Lock()
+		delete(r.jobs, job.Id)
+		r.mu.Unlock()
+		return apierr.RateLimited.WithMessage("job queue is full")
+	}
+}
+
+func (r *memoryJobRepository) Dequeue(ctx context.Context) (*model.Job, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case id := <-r.queue:
+			job, err := r.Get(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if job != nil {
+				return job, nil
+			}
+		}
+	}
+}
+
+// Ack 内存队列随进程一起退出，不需要处理中列表
+func (r *memoryJobRepository) Ack(ctx context.Context, id string) error {
+	return nil
+}
+
+func (r *memoryJobRepository) Reap(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (r *memoryJobRepository) Save(ctx context.Context, job *model.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneJobs()
+	saved := *job
+	r.jobs[job.Id] = &saved
+	return nil
+}
+
+func (r *memoryJobRepository) Get(ctx context.Context, id string) (*model.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// pruneJobs 删除超过 jobs.ttl 的已完成任务，调用方需持有锁
+func (r *memoryJobRepository) pruneJobs() {
+	ttl := r.conf.GetDuration("jobs.ttl")
+	for id, job := range r.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > ttl {
+			delete(r.jobs, id)
+		}
+	}
+}
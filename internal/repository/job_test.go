@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ogimg/internal/model"
+)
+
+func TestRedisJobAck(t *testing.T) {
+	repo, mr := newTestRepository(t, nil)
+	jobs := NewJobRepository(repo)
+	ctx := context.Background()
+
+	if err := jobs.Enqueue(ctx, &model.Job{Id: "a", Status: model.JobQueued}); err != nil {
+		t.Fatal(err)
+	}
+	job, err := jobs.Dequeue(ctx)
+	if err != nil || job.Id != "a" {
+		t.Fatalf("Dequeue = %+v, %v", job, err)
+	}
+	// 执行中的任务保留在处理中列表并持有租约
+	if ids, _ := mr.List(jobProcessingKey); len(ids) != 1 || ids[0] != "a" {
+		t.Errorf("processing = %v", ids)
+	}
+	if ttl := mr.TTL(jobLeasePrefix + "a"); ttl != 60*time.Second+jobLeaseGrace {
+		t.Errorf("lease ttl = %s", ttl)
+	}
+
+	if err := jobs.Ack(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(jobProcessingKey) || mr.Exists(jobLeasePrefix+"a") {
+		t.Error("Ack should remove the job from processing and drop its lease")
+	}
+}
+
+func TestRedisJobReap(t *testing.T) {
+	repo, mr := newTestRepository(t, nil)
+	jobs := NewJobRepository(repo)
+	ctx := context.Background()
+
+	for _, id := range []string{"crashed", "alive"} {
+		if err := jobs.Enqueue(ctx, &model.Job{Id: id, Status: model.JobQueued}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := jobs.Dequeue(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 模拟 worker 崩溃，租约过期
+	mr.Del(jobLeasePrefix + "crashed")
+
+	// 第一次只记录，第二次仍然没有租约才放回队列
+	if n, err := jobs.Reap(ctx); err != nil || n != 0 {
+		t.Fatalf("first Reap = %d, %v", n, err)
+	}
+	if n, err := jobs.Reap(ctx); err != nil || n != 1 {
+		t.Fatalf("second Reap = %d, %v", n, err)
+	}
+	if ids, _ := mr.List(jobProcessingKey); len(ids) != 1 || ids[0] != "alive" {
+		t.Errorf("processing = %v, want only the job with a lease", ids)
+	}
+	job, err := jobs.Dequeue(ctx)
+	if err != nil || job.Id != "crashed" {
+		t.Fatalf("requeued job = %+v, %v", job, err)
+	}
+
+	// 另一个节点已经放回的任务不会重复放回
+	other := NewJobRepository(repo)
+	mr.Del(jobLeasePrefix + "crashed")
+	jobs.Reap(ctx)
+	other.Reap(ctx)
+	n1, _ := jobs.Reap(ctx)
+	n2, _ := other.Reap(ctx)
+	if n1+n2 != 1 {
+		t.Errorf("requeued %d times by two reapers, want 1", n1+n2)
+	}
+	if ids, _ := mr.List(jobQueueKey); len(ids) != 1 {
+		t.Errorf("queue = %v", ids)
+	}
+}
+
+func TestDequeueExpiredJob(t *testing.T) {
+	repo, mr := newTestRepository(t, nil)
+	jobs := NewJobRepository(repo)
+	ctx := context.Background()
+
+	jobs.Enqueue(ctx, &model.Job{Id: "expired"})
+	jobs.Enqueue(ctx, &model.Job{Id: "next"})
+	mr.Del("job:expired")
+	job, err := jobs.Dequeue(ctx)
+	if err != nil || job.Id != "next" {
+		t.Fatalf("Dequeue = %+v, %v", job, err)
+	}
+	if ids, _ := mr.List(jobProcessingKey); len(ids) != 1 || ids[0] != "next" {
+		t.Errorf("processing = %v, expired job should be acked", ids)
+	}
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"ogimg/internal/model"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LinkRepository interface {
+	// SaveLink 按 url 新增或更新记录并追加一条抓取记录，抓取失败时只更新状态，保留上次成功的预览信息
+	SaveLink(ctx context.Context, link *model.LinkRecord, fetch *model.LinkFetch) error
+	// GetLink 返回记录及最近 fetches 条抓取记录，不存在时返回 nil
+	GetLink(ctx context.Context, url string, fetches int) (*model.LinkRecord, error)
+	// ListVersions 按时间倒序返回最近 limit 个版本，limit 不大于 0 时返回全部
+	ListVersions(ctx context.Context, linkId int64, limit int) ([]model.LinkVersion, error)
+	// BaselineVersion 返回 since 时生效的版本，since 之前没有版本时返回最早的版本，没有版本时返回 nil
+	BaselineVersion(ctx context.Context, linkId int64, since time.Time) (*model.LinkVersion, error)
+	// ListChanged 返回 since 之后出现新版本（不含第一个版本）的记录，最近变化的在前
+	ListChanged(ctx context.Context, since time.Time, limit int) ([]model.LinkRecord, error)
+}
+
+// 与 model 中 size 标签的长度一致
+const (
+	maxTitleLength = 1024
+	maxErrorLength = 1024
+)
+
+type linkRepository struct {
+	*Repository
+}
+
+func NewLinkRepository(repository *Repository) LinkRepository {
+	return &linkRepository{
+		Repository: repository,
+	}
+}
+
+func (r *linkRepository) SaveLink(ctx context.Context, link *model.LinkRecord, fetch *model.LinkFetch) error {
+	if !r.dbEnabled() {
+		return nil
+	}
+	if fetch.FetchedAt.IsZero() {
+		fetch.FetchedAt = time.Now().UTC()
+	}
+	link.UrlHash = urlHash(link.Url)
+	link.Status = fetch.Status
+	link.LastSeenAt = fetch.FetchedAt
+
+	// 列的长度按字符计算，超出时 mysql 严格模式会拒绝写入
+	link.Title = truncate(link.Title, maxTitleLength)
+	fetch.Error = truncate(fetch.Error, maxErrorLength)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 并发保存同一个 url 时先插入或更新状态，由唯一索引保证只有一条记录，mysql 会锁住该行直到事务结束
+		upsert := model.LinkRecord{
+			UrlHash:     link.UrlHash,
+			Url:         link.Url,
+			Status:      link.Status,
+			FirstSeenAt: fetch.FetchedAt,
+			LastSeenAt:  link.LastSeenAt,
+		}
+		err := tx.Omit("Fetches", "Versions").Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "url_hash"}},
+			DoUpdates: clause.AssignmentColumns([]string{"status", "last_seen_at"}),
+		}).Create(&upsert).Error
+		if err != nil {
+			return err
+		}
+		var existing model.LinkRecord
+		if err := tx.Where("url_hash = ?", link.UrlHash).Take(&existing).Error; err != nil {
+			return err
+		}
+		link.Id = existing.Id
+		link.FirstSeenAt = existing.FirstSeenAt
+		if fetch.Status == model.LinkFetchOk {
+			// 只获取描述时没有图片内容，og:image 未变化时沿用原来的图片哈希
+			if link.ImageHash == "" && link.Image == existing.Image {
+				link.ImageHash = existing.ImageHash
+				link.ImagePHash = existing.ImagePHash
+			}
+			columns := []string{"canonical_url", "title", "description", "logo", "image", "image_hash", "image_phash"}
+			if err := tx.Model(&existing).Select(columns).Updates(link).Error; err != nil {
+				return err
+			}
+		}
+		fetch.LinkId = link.Id
+		if err := tx.Create(fetch).Error; err != nil {
+			return err
+		}
+		if err := pruneFetches(tx, link.Id, r.conf.GetInt("history.max_fetches")); err != nil {
+			return err
+		}
+		if fetch.Status != model.LinkFetchOk {
+			return nil
+		}
+		return saveVersion(tx, link, fetch.FetchedAt)
+	})
+}
+
+// pruneFetches 每个 url 只保留最近 keep 条抓取记录，keep 不大于 0 时不删除
+func pruneFetches(tx *gorm.DB, linkId int64, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	// mysql 不支持在 IN 子查询中使用 LIMIT，先查出保留的最早一条
+	var oldest []int64
+	err := tx.Model(&model.LinkFetch{}).Where("link_id = ?", linkId).Order("id desc").Offset(keep-1).Limit(1).Pluck("id", &oldest).Error
+	if err != nil || len(oldest) == 0 {
+		return err
+	}
+	return tx.Where("link_id = ? AND id < ?", linkId, oldest[0]).Delete(&model.LinkFetch{}).Error
+}
+
+// saveVersion 预览信息与最新版本不同时新增版本，只是补充了图片哈希时更新最新版本
+func saveVersion(tx *gorm.DB, link *model.LinkRecord, at time.Time) error {
+	version := model.LinkVersion{
+		LinkId:       link.Id,
+		CanonicalUrl: link.CanonicalUrl,
+		Title:        link.Title,
+		Description:  link.Description,
+		Logo:         link.Logo,
+		Image:        link.Image,
+		ImageHash:    link.ImageHash,
+		ImagePHash:   link.ImagePHash,
+		CreatedAt:    at,
+	}
+	var latest model.LinkVersion
+	err := tx.Where("link_id = ?", link.Id).Order("id desc").Take(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Create(&version).Error
+	}
+	if err != nil {
+		return err
+	}
+	if sameVersion(latest, version) {
+		return nil
+	}
+	if latest.ImageHash == "" && version.ImageHash != "" {
+		filled := latest
+		filled.ImageHash = version.ImageHash
+		if sameVersion(filled, version) {
+			return tx.Model(&latest).Updates(map[string]interface{}{
+				"image_hash":  version.ImageHash,
+				"image_phash": version.ImagePHash,
+			}).Error
+		}
+	}
+	return tx.Create(&version).Error
+}
+
+func sameVersion(a, b model.LinkVersion) bool {
+	return a.CanonicalUrl == b.CanonicalUrl &&
+		a.Title == b.Title &&
+		a.Description == b.Description &&
+		a.Logo == b.Logo &&
+		a.Image == b.Image &&
+		a.ImageHash == b.ImageHash
+}
+
+func (r *linkRepository) GetLink(ctx context.Context, url string, fetches int) (*model.LinkRecord, error) {
+	if !r.dbEnabled() {
+		return nil, ErrDbNotConfigured
+	}
+	var link model.LinkRecord
+	err := r.db.WithContext(ctx).
+		Preload("Fetches", func(db *gorm.DB) *gorm.DB {
+			return db.Order("fetched_at desc, id desc").Limit(fetches)
+		}).
+		Where("url_hash = ?", urlHash(url)).
+		Take(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *linkRepository) ListVersions(ctx context.Context, linkId int64, limit int) ([]model.LinkVersion, error) {
+	if !r.dbEnabled() {
+		return nil, ErrDbNotConfigured
+	}
+	query := r.db.WithContext(ctx).Where("link_id = ?", linkId).Order("id desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var versions []model.LinkVersion
+	err := query.Find(&versions).Error
+	return versions, err
+}
+
+func (r *linkRepository) BaselineVersion(ctx context.Context, linkId int64, since time.Time) (*model.LinkVersion, error) {
+	if !r.dbEnabled() {
+		return nil, ErrDbNotConfigured
+	}
+	var version model.LinkVersion
+	err := r.db.WithContext(ctx).Where("link_id = ? AND created_at <= ?", linkId, since.UTC()).Order("id desc").Take(&version).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = r.db.WithContext(ctx).Where("link_id = ?", linkId).Order("id").Take(&version).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (r *linkRepository) ListChanged(ctx context.Context, since time.Time, limit int) ([]model.LinkRecord, error) {
+	if !r.dbEnabled() {
+		return nil, ErrDbNotConfigured
+	}
+	query := r.db.WithContext(ctx).
+		Model(&model.LinkVersion{}).
+		Where("created_at > ?", since.UTC()).
+		Where("EXISTS (SELECT 1 FROM link_versions p WHERE p.link_id = link_versions.link_id AND p.id < link_versions.id)").
+		Group("link_id").
+		Order("MAX(id) DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var ids []int64
+	err := query.Pluck("link_id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var links []model.LinkRecord
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	byId := make(map[int64]model.LinkRecord, len(links))
+	for _, link := range links {
+		byId[link.Id] = link
+	}
+	ordered := make([]model.LinkRecord, 0, len(ids))
+	for _, id := range ids {
+		if link, ok := byId[id]; ok {
+			ordered = append(ordered, link)
+		}
+	}
+	return ordered, nil
+}
+
+// truncate 按字符截断，不截断多字节字符
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// urlHash url 可能超过 mysql 索引长度，按 sha256 建唯一索引
+func urlHash(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/config"
+)
+
+// 并发保存不同 url 时不应返回 database is locked
+func TestSaveLinkConcurrent(t *testing.T) {
+	repo, _ := newTestRepository(t, nil)
+	links := NewLinkRepository(repo)
+	ctx := context.Background()
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				link := &model.LinkRecord{Url: fmt.Sprintf("https://example.com/%d", i), Title: fmt.Sprint(j)}
+				if err := links.SaveLink(ctx, link, &model.LinkFetch{Status: model.LinkFetchOk}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		link, err := links.GetLink(ctx, fmt.Sprintf("https://example.com/%d", i), 10)
+		if err != nil || link == nil || len(link.Fetches) != 5 {
+			t.Errorf("link %d = %+v, %v", i, link, err)
+		}
+	}
+}
+
+// 并发保存同一个 url 时只有一条记录，不会因唯一索引冲突失败
+func TestSaveLinkSameUrl(t *testing.T) {
+	repo, _ := newTestRepository(t, nil)
+	links := NewLinkRepository(repo)
+	ctx := context.Background()
+	const url = "https://example.com/same"
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- links.SaveLink(ctx, &model.LinkRecord{Url: url, Title: "title"}, &model.LinkFetch{Status: model.LinkFetchOk})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var count int64
+	repo.db.Model(&model.LinkRecord{}).Where("url = ?", url).Count(&count)
+	if count != 1 {
+		t.Errorf("%d records for one url", count)
+	}
+	link, err := links.GetLink(ctx, url, 100)
+	if err != nil || link == nil || len(link.Fetches) != n {
+		t.Errorf("link = %+v, %v", link, err)
+	}
+}
+
+// 超过列长度的标题和错误信息按字符截断，抓取记录只保留 history.max_fetches 条
+func TestSaveLinkLimits(t *testing.T) {
+	repo, _ := newTestRepository(t, func(conf *config.Config) {
+		conf.Set("history.max_fetches", 3)
+	})
+	links := NewLinkRepository(repo)
+	ctx := context.Background()
+	const url = "https://example.com/long"
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	title := strings.Repeat("标", maxTitleLength+10)
+	if err := links.SaveLink(ctx, &model.LinkRecord{Url: url, Title: title}, &model.LinkFetch{Status: model.LinkFetchOk, FetchedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 5; i++ {
+		fetch := &model.LinkFetch{Status: "upstream_error", Error: strings.Repeat("e", maxErrorLength+10), FetchedAt: at.Add(time.Duration(i) * time.Minute)}
+		if err := links.SaveLink(ctx, &model.LinkRecord{Url: url}, fetch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	link, err := links.GetLink(ctx, url, 10)
+	if err != nil || link == nil {
+		t.Fatalf("link = %+v, %v", link, err)
+	}
+	if link.Title != strings.Repeat("标", maxTitleLength) {
+		t.Errorf("title is %d characters", len([]rune(link.Title)))
+	}
+	if len(link.Fetches) != 3 || !link.Fetches[2].FetchedAt.Equal(at.Add(2*time.Minute)) {
+		t.Fatalf("%d fetches are kept", len(link.Fetches))
+	}
+	if len(link.Fetches[0].Error) != maxErrorLength {
+		t.Errorf("error is %d characters", len(link.Fetches[0].Error))
+	}
+}
+
+func TestSaveLink(t *testing.T) {
+	repo, _ := newTestRepository(t, nil)
+	links := NewLinkRepository(repo)
+	ctx := context.Background()
+	const url = "https://example.com/page"
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	save := func(link model.LinkRecord, status string, offset time.Duration) {
+		t.Helper()
+		link.Url = url
+		if err := links.SaveLink(ctx, &link, &model.LinkFetch{Status: status, FetchedAt: at.Add(offset)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if link, err := links.GetLink(ctx, url, 10); link != nil || err != nil {
+		t.Fatalf("missing link = %+v, %v", link, err)
+	}
+
+	save(model.LinkRecord{Title: "v1", Image: "https://example.com/a.png"}, model.LinkFetchOk, 0)
+	// 只获取描述时没有图片哈希，之后获取图片时补充到同一个版本
+	save(model.LinkRecord{Title: "v1", Image: "https://example.com/a.png", ImageHash: "h1", ImagePHash: "00000000000000ff"}, model.LinkFetchOk, time.Minute)
+	// 只获取描述，沿用原来的图片哈希
+	save(model.LinkRecord{Title: "v1", Image: "https://example.com/a.png"}, model.LinkFetchOk, 2*time.Minute)
+	// 抓取失败只更新状态
+	save(model.LinkRecord{}, "upstream_timeout", 3*time.Minute)
+
+	link, err := links.GetLink(ctx, url, 2)
+	if err != nil || link == nil {
+		t.Fatalf("link = %+v, %v", link, err)
+	}
+	if link.Title != "v1" || link.ImageHash != "h1" || link.ImagePHash != "00000000000000ff" || link.Status != "upstream_timeout" {
+		t.Errorf("link = %+v", link)
+	}
+	if !link.FirstSeenAt.Equal(at) || !link.LastSeenAt.Equal(at.Add(3*time.Minute)) {
+		t.Errorf("first seen %s, last seen %s", link.FirstSeenAt, link.LastSeenAt)
+	}
+	if len(link.Fetches) != 2 || link.Fetches[0].Status != "upstream_timeout" || link.Fetches[1].Status != model.LinkFetchOk {
+		t.Errorf("fetches = %+v", link.Fetches)
+	}
+
+	versions, err := links.ListVersions(ctx, link.Id, 0)
+	if err != nil || len(versions) != 1 || versions[0].ImageHash != "h1" {
+		t.Fatalf("versions = %+v, %v", versions, err)
+	}
+
+	save(model.LinkRecord{Title: "v2", Image: "https://example.com/a.png", ImageHash: "h1"}, model.LinkFetchOk, 4*time.Minute)
+	versions, err = links.ListVersions(ctx, link.Id, 0)
+	if err != nil || len(versions) != 2 || versions[0].Title != "v2" || versions[1].Title != "v1" {
+		t.Errorf("versions = %+v, %v", versions, err)
+	}
+	if versions, _ := links.ListVersions(ctx, link.Id, 1); len(versions) != 1 || versions[0].Title != "v2" {
+		t.Errorf("limited versions = %+v", versions)
+	}
+}
+
+func TestListChanged(t *testing.T) {
+	repo, _ := newTestRepository(t, nil)
+	links := NewLinkRepository(repo)
+	ctx := context.Background()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	save := func(url, title string, offset time.Duration) {
+		t.Helper()
+		link := &model.LinkRecord{Url: url, Title: title}
+		if err := links.SaveLink(ctx, link, &model.LinkFetch{Status: model.LinkFetchOk, FetchedAt: at.Add(offset)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	save("https://a.example.com", "a1", 0)
+	save("https://b.example.com", "b1", 0)
+	save("https://c.example.com", "c1", 2*time.Hour)
+	save("https://a.example.com", "a2", 3*time.Hour)
+	save("https://b.example.com", "b2", 4*time.Hour)
+	// 变化早于 since
+	save("https://d.example.com", "d1", 0)
+	save("https://d.example.com", "d2", 30*time.Minute)
+
+	changed, err := links.ListChanged(ctx, at.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, link := range changed {
+		urls = append(urls, link.Url)
+	}
+	// c 只有第一个版本，不算变化；最近变化的在前
+	if strings.Join(urls, " ") != "https://b.example.com https://a.example.com" {
+		t.Errorf("changed = %v", urls)
+	}
+	if changed, _ := links.ListChanged(ctx, at.Add(time.Hour), 1); len(changed) != 1 || changed[0].Url != "https://b.example.com" {
+		t.Errorf("limited changed = %+v", changed)
+	}
+}
+
+func TestLinkWithoutDb(t *testing.T) {
+	repo, _ := newTestRepository(t, func(conf *config.Config) {
+		conf.Set("data.db.driver", DbDriverNone)
+	})
+	links := NewLinkRepository(repo)
+	ctx := context.Background()
+	if err := links.SaveLink(ctx, &model.LinkRecord{Url: "https://example.com"}, &model.LinkFetch{Status: model.LinkFetchOk}); err != nil {
+		t.Errorf("save without db = %v", err)
+	}
+	if _, err := links.GetLink(ctx, "https://example.com", 1); !errors.Is(err, ErrDbNotConfigured) {
+		t.Errorf("get without db = %v", err)
+	}
+	if _, err := links.ListChanged(ctx, time.Time{}, 1); !errors.Is(err, ErrDbNotConfigured) {
+		t.Errorf("list without db = %v", err)
+	}
+}
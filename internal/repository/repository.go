@@ -3,80 +3,339 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"ogimg/internal/model"
+	"ogimg/pkg/config"
 	"ogimg/pkg/log"
+	"ogimg/pkg/metrics"
+	"ogimg/pkg/policy"
+	"ogimg/pkg/robots"
+	"ogimg/pkg/telemetry"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/go-redis/redis/v8"
-	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 type Repository struct {
 	db     *gorm.DB
 	rdb    *redis.Client
+	conf   *config.Config
+	client *http.Client
 	logger *log.Logger
 }
 
-func NewRepository(logger *log.Logger, db *gorm.DB, conf *viper.Viper) *Repository {
+func NewRepository(logger *log.Logger, db *gorm.DB, conf *config.Config, policy *policy.Policy) *Repository {
 	rdb := redis.NewClient(&redis.Options{
 		Addr: conf.GetString("data.redis.addr"),
 	})
+	rdb.AddHook(metrics.RedisHook{})
+	client := &http.Client{
+		Timeout:       conf.GetDuration("crawler.timeout"),
+		Transport:     telemetry.Transport(metrics.Transport(metrics.UpstreamRobots, policy.Transport()), conf.GetBool("telemetry.propagate_upstream")),
+		CheckRedirect: policy.CheckRedirect,
+	}
 	return &Repository{
 		db:     db,
 		rdb:    rdb,
+		conf:   conf,
+		client: client,
 		logger: logger,
 	}
 }
 
-func (r *Repository) SetWebsiteOgImgToCache(ctx context.Context, url string, val []byte) error {
-	r.logger.Info("Set to cache", zap.String("ogimg:url", url), zap.Int("val_size", len(val)))
+func (r *Repository) SetWebsiteOgImgToCache(ctx context.Context, url string, val []byte) (err error) {
+	ctx, span := startSpan(ctx, "cache.set", attribute.String("cache.kind", "image"), attribute.Int("cache.size", len(val)))
+	defer func() { endSpan(span, err) }()
+
+	r.logger.WithContext(ctx).Debug("Set to cache", zap.String("ogimg:url", url), zap.Int("val_size", len(val)))
 	ogImgKey := "ogimg:" + url
-	err := r.rdb.Set(ctx, ogImgKey, val, viper.GetDuration("data.redis.expire_time")).Err()
+	err = r.rdb.Set(ctx, ogImgKey, val, r.cacheTTL()).Err()
 	return err
 }
 
-func (r *Repository) GetWebsiteOgImgFromCache(ctx context.Context, url string) ([]byte, error) {
-	r.logger.Info("Get from cache", zap.String("ogimg:url", url))
+// GetWebsiteOgImgFromCache 读取图片缓存，未命中时返回 nil，stale 表示已超过 data.redis.expire_time
+func (r *Repository) GetWebsiteOgImgFromCache(ctx context.Context, url string) (val []byte, stale bool, err error) {
+	ctx, span := startSpan(ctx, "cache.get", attribute.String("cache.kind", "image"))
+	defer func() {
+		span.SetAttributes(attribute.Bool("cache.hit", val != nil))
+		endSpan(span, err)
+	}()
+
+	r.logger.WithContext(ctx).Debug("Get from cache", zap.String("ogimg:url", url))
 	ogImgKey := "ogimg:" + url
-	val, err := r.rdb.Get(ctx, ogImgKey).Bytes()
-	return val, err
+	return r.getCache(ctx, ogImgKey)
+}
+
+// SetWebsiteIconToCache 缓存网站图标，过期时间与 og:image 相同
+func (r *Repository) SetWebsiteIconToCache(ctx context.Context, url string, val []byte) (err error) {
+	ctx, span := startSpan(ctx, "cache.set", attribute.String("cache.kind", "icon"), attribute.Int("cache.size", len(val)))
+	defer func() { endSpan(span, err) }()
+
+	r.logger.WithContext(ctx).Debug("Set to cache", zap.String("icon:url", url), zap.Int("val_size", len(val)))
+	return r.rdb.Set(ctx, "icon:"+url, val, r.cacheTTL()).Err()
 }
 
-func (r *Repository) SetWebSiteDescToCache(ctx context.Context, url string, val model.WebsiteDescType) error {
-	r.logger.Info("Get from cache", zap.String("url", url))
+// GetWebsiteIconFromCache 读取网站图标缓存，未命中时返回 nil，stale 的含义与图片缓存相同
+func (r *Repository) GetWebsiteIconFromCache(ctx context.Context, url string) (val []byte, stale bool, err error) {
+	ctx, span := startSpan(ctx, "cache.get", attribute.String("cache.kind", "icon"))
+	defer func() {
+		span.SetAttributes(attribute.Bool("cache.hit", val != nil))
+		endSpan(span, err)
+	}()
+
+	r.logger.WithContext(ctx).Debug("Get from cache", zap.String("icon:url", url))
+	return r.getCache(ctx, "icon:"+url)
+}
+
+func (r *Repository) SetWebSiteDescToCache(ctx context.Context, url string, val model.WebsiteDescType) (err error) {
+	ctx, span := startSpan(ctx, "cache.set", attribute.String("cache.kind", "desc"))
+	defer func() { endSpan(span, err) }()
+
+	r.logger.WithContext(ctx).Debug("Set to cache", zap.String("desc:url", url))
 	descKey := "desc:" + url
 	jsonVal, err := json.Marshal(val)
 	if err != nil {
 		return err
 	}
-	err = r.rdb.Set(ctx, descKey, jsonVal, viper.GetDuration("data.redis.expire_time")).Err()
+	err = r.rdb.Set(ctx, descKey, jsonVal, r.cacheTTL()).Err()
 	return err
 }
 
-func (r *Repository) GetWebSiteDescToCache(ctx context.Context, url string) (model.WebsiteDescType, error) {
-	r.logger.Info("Get from cache", zap.String("desc:url", url))
+// GetWebSiteDescToCache 读取描述缓存，未命中时返回空值，stale 的含义与图片缓存相同
+func (r *Repository) GetWebSiteDescToCache(ctx context.Context, url string) (desc model.WebsiteDescType, stale bool, err error) {
+	ctx, span := startSpan(ctx, "cache.get", attribute.String("cache.kind", "desc"))
+	defer func() {
+		span.SetAttributes(attribute.Bool("cache.hit", desc != model.WebsiteDescType{}))
+		endSpan(span, err)
+	}()
+
+	r.logger.WithContext(ctx).Debug("Get from cache", zap.String("desc:url", url))
 	desKey := "desc:" + url
-	val, err := r.rdb.Get(ctx, desKey).Result()
-	if err == redis.Nil {
-		return model.WebsiteDescType{}, nil
+	val, stale, err := r.getCache(ctx, desKey)
+	if err != nil || val == nil {
+		return model.WebsiteDescType{}, false, err
+	}
+	err = json.Unmarshal(val, &desc)
+	if err != nil {
+		return model.WebsiteDescType{}, false, err
+	}
+	return desc, stale, nil
+}
+
+// cacheTTL 缓存项在 data.redis.expire_time 之后再保留 data.redis.stale_time，重新获取失败时使用
+func (r *Repository) cacheTTL() time.Duration {
+	return r.conf.GetDuration("data.redis.expire_time") + r.conf.GetDuration("data.redis.stale_time")
+}
+
+// getCache 读取缓存项，剩余时间不超过 data.redis.stale_time 时为已过期
+func (r *Repository) getCache(ctx context.Context, key string) ([]byte, bool, error) {
+	pipe := r.rdb.Pipeline()
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err == redis.Nil {
+		return nil, false, nil
 	} else if err != nil {
-		return model.WebsiteDescType{}, err
+		return nil, false, err
+	}
+	val, err := get.Bytes()
+	if err != nil {
+		return nil, false, err
+	}
+	staleTime := r.conf.GetDuration("data.redis.stale_time")
+	return val, staleTime > 0 && ttl.Val() >= 0 && ttl.Val() <= staleTime, nil
+}
+
+// DeleteWebsiteCache 删除 url 的图片、图标和描述缓存，返回删除的 key 数量
+func (r *Repository) DeleteWebsiteCache(ctx context.Context, url string) (n int64, err error) {
+	ctx, span := startSpan(ctx, "cache.delete")
+	defer func() { endSpan(span, err) }()
+
+	r.logger.WithContext(ctx).Info("Delete cache", zap.String("url", url))
+	return r.rdb.Del(ctx, "ogimg:"+url, "icon:"+url, "desc:"+url).Result()
+}
+
+// InspectCache 查看 url 的各个缓存项
+func (r *Repository) InspectCache(ctx context.Context, url string) ([]model.CacheEntry, error) {
+	var entries []model.CacheEntry
+	for _, kind := range []string{"ogimg", "icon", "desc"} {
+		key := kind + ":" + url
+		entry := model.CacheEntry{Kind: kind, Key: key}
+		size, err := r.rdb.StrLen(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if size > 0 {
+			ttl, err := r.rdb.TTL(ctx, key).Result()
+			if err != nil {
+				return nil, err
+			}
+			entry.Exists = true
+			entry.Size = size
+			entry.TTL = ttl
+		}
+		entries = append(entries, entry)
 	}
-	var desc model.WebsiteDescType
-	err = json.Unmarshal([]byte(val), &desc)
+	return entries, nil
+}
+
+// GetRobots 获取站点的 robots.txt，优先读取缓存
+func (r *Repository) GetRobots(ctx context.Context, siteUrl string) (*robots.Robots, error) {
+	ctx, span := startSpan(ctx, "robots.get")
+	defer span.End()
+
+	u, err := url.Parse(siteUrl)
 	if err != nil {
-		return model.WebsiteDescType{}, err
+		return nil, err
 	}
-	return desc, nil
+	origin := u.Scheme + "://" + u.Host
+	robotsKey := "robots:" + origin
+
+	val, err := r.rdb.Get(ctx, robotsKey).Result()
+	if err == nil {
+		return robots.Parse(strings.NewReader(val)), nil
+	} else if err != redis.Nil {
+		r.logger.WithContext(ctx).Error("Get robots from cache error", zap.Error(err))
+	}
+
+	body, err := r.fetchRobots(ctx, origin+"/robots.txt")
+	if err != nil {
+		// robots.txt 无法访问时视为全部禁止，且不缓存
+		r.logger.WithContext(ctx).Warn("Fetch robots.txt error", zap.String("origin", origin), zap.Error(err))
+		return robots.DisallowAll(), nil
+	}
+
+	r.logger.WithContext(ctx).Debug("Set robots to cache", zap.String("origin", origin), zap.Int("val_size", len(body)))
+	if err := r.rdb.Set(ctx, robotsKey, body, r.conf.GetDuration("crawler.robots.cache_ttl")).Err(); err != nil {
+		r.logger.WithContext(ctx).Error("Set robots to cache error", zap.Error(err))
+	}
+	return robots.Parse(strings.NewReader(body)), nil
+}
+
+// fetchRobots 下载 robots.txt，4xx 视为不存在（返回空内容即全部允许），5xx 视为错误
+func (r *Repository) fetchRobots(ctx context.Context, robotsUrl string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", r.conf.GetString("crawler.user_agent"))
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 500 {
+		return "", fmt.Errorf("robots.txt status %d", res.StatusCode)
+	}
+	if res.StatusCode >= 400 {
+		return "", nil
+	}
+	// robots.txt 最多解析 500 KiB
+	body, err := io.ReadAll(io.LimitReader(res.Body, 500<<10))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// startSpan 为缓存等 redis 操作创建 span
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.system", "redis"))
+	return telemetry.Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ErrDbNotConfigured 未配置数据库
+var ErrDbNotConfigured = errors.New("database not configured")
+
+// PingRedis 检查 redis 连接
+func (r *Repository) PingRedis(ctx context.Context) error {
+	return r.rdb.Ping(ctx).Err()
+}
+
+// PingDB 检查数据库连接，未配置数据库时返回 ErrDbNotConfigured
+func (r *Repository) PingDB(ctx context.Context) error {
+	if !r.dbEnabled() {
+		return ErrDbNotConfigured
+	}
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+const (
+	DbDriverSqlite = "sqlite"
+	DbDriverMysql  = "mysql"
+	DbDriverNone   = "none"
+)
+
+// NewDb 按 data.db.driver 连接数据库：sqlite（默认，纯 Go 实现）使用 data.sqlite.path，mysql 使用 data.mysql.user 中的 DSN，
+// none 时不使用数据库
+func NewDb(conf *config.Config, logger *log.Logger) *gorm.DB {
+	var dialector gorm.Dialector
+	switch driver := conf.GetString("data.db.driver"); driver {
+	case DbDriverNone:
+		return &gorm.DB{}
+	case DbDriverMysql:
+		dialector = mysql.Open(conf.GetString("data.mysql.user"))
+	case DbDriverSqlite, "":
+		path := conf.GetString("data.sqlite.path")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			panic(err)
+		}
+		// WAL 允许读写并发，busy_timeout 避免并发写入时立即返回 database is locked，
+		// 事务先读后写，immediate 在开始时获取写锁，避免升级写锁时直接返回 SQLITE_BUSY
+		dialector = sqlite.Open(path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate")
+	default:
+		panic(fmt.Sprintf("unknown data.db.driver %q", driver))
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Warn),
+	})
+	if err != nil {
+		panic(err)
+	}
+	if conf.GetBool("data.db.auto_migrate") {
+		if err := Migrate(db); err != nil {
+			panic(err)
+		}
+	}
+	logger.Info("Database connected", zap.String("driver", db.Dialector.Name()))
+	return db
+}
+
+// Migrate 创建或更新数据表，只会新增表和字段，不会删除
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&model.LinkRecord{}, &model.LinkFetch{}, &model.LinkVersion{})
 }
 
-func NewDb() *gorm.DB {
-	// TODO: init db
-	//db, err := gorm.Open(mysql.Open(conf.GetString("data.mysql.user")), &gorm.Config{})
-	//if err != nil {
-	//	panic(err)
-	//}
-	//return db
-	return &gorm.DB{}
+// dbEnabled 是否配置了数据库
+func (r *Repository) dbEnabled() bool {
+	return r.db.Config != nil && r.db.ConnPool != nil
 }
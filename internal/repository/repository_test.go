@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+	"ogimg/pkg/policy"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/spf13/viper"
+)
+
+// newTestRepository 使用 miniredis 和临时 sqlite，setup 可以在创建前修改配置
+func newTestRepository(t *testing.T, setup func(conf *config.Config)) (*Repository, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	dir := t.TempDir()
+	conf := config.New(viper.New())
+	conf.Set("data.redis.addr", mr.Addr())
+	conf.Set("data.redis.expire_time", "1h")
+	conf.Set("data.db.driver", DbDriverSqlite)
+	conf.Set("data.db.auto_migrate", true)
+	conf.Set("data.sqlite.path", filepath.Join(dir, "ogimg.db"))
+	conf.Set("log.log_file_name", filepath.Join(dir, "server.log"))
+	conf.Set("log.log_level", "error")
+	conf.Set("jobs.timeout", "60s")
+	conf.Set("jobs.ttl", "1h")
+	conf.Set("jobs.queue_size", 10)
+	if setup != nil {
+		setup(conf)
+	}
+	logger := log.NewLog(conf)
+	p, err := policy.NewPolicy(conf, logger, config.NewWatcher(conf, logger.Logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRepository(logger, NewDb(conf, logger), conf, p), mr
+}
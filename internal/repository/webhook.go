@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"ogimg/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	webhookQueueKey = "webhook:queue"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, hook *model.Webhook) error
+	// GetWebhook webhook 不存在时返回 nil
+	GetWebhook(ctx context.Context, id string) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context, owner string) ([]*model.Webhook, error)
+	// ListUrlWebhooks 关注了 url 的 webhook，不区分 owner
+	ListUrlWebhooks(ctx context.Context, url string) ([]*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, hook *model.Webhook) error
+
+	// AddDelivery 保存投递记录，写入投递日志并立即排队
+	AddDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	SaveDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	ScheduleDelivery(ctx context.Context, id string, at time.Time) error
+	// PopDueDeliveries 取出已到投递时间的记录，多个节点同时调用时每条记录只会被一个节点取到
+	PopDueDeliveries(ctx context.Context, now time.Time, limit int64) ([]*model.WebhookDelivery, error)
+	DeadLetter(ctx context.Context, delivery *model.WebhookDelivery) error
+	// ListDeliveries 按时间倒序返回投递日志，dead 为 true 时只返回死信
+	ListDeliveries(ctx context.Context, webhookId string, dead bool) ([]*model.WebhookDelivery, error)
+
+	GetPreview(ctx context.Context, url string) (*model.PreviewSnapshot, error)
+	SetPreview(ctx context.Context, url string, snapshot model.PreviewSnapshot) error
+}
+
+type webhookRepository struct {
+	*Repository
+}
+
+func NewWebhookRepository(repository *Repository) WebhookRepository {
+	return &webhookRepository{
+		Repository: repository,
+	}
+}
+
+// storedWebhook 存储时保留 Owner
+type storedWebhook struct {
+	model.Webhook
+	Owner string `json:"owner"`
+}
+
+func (r *webhookRepository) CreateWebhook(ctx context.Context, hook *model.Webhook) error {
+	val, err := json.Marshal(storedWebhook{Webhook: *hook, Owner: hook.Owner})
+	if err != nil {
+		return err
+	}
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "webhook:"+hook.Id, val, 0)
+		pipe.SAdd(ctx, "webhooks:owner:"+hook.Owner, hook.Id)
+		for _, u := range hook.Urls {
+			pipe.SAdd(ctx, "webhooks:url:"+u, hook.Id)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *webhookRepository) GetWebhook(ctx context.Context, id string) (*model.Webhook, error) {
+	val, err := r.rdb.Get(ctx, "webhook:"+id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var stored storedWebhook
+	if err := json.Unmarshal(val, &stored); err != nil {
+		return nil, err
+	}
+	hook := stored.Webhook
+	hook.Owner = stored.Owner
+	return &hook, nil
+}
+
+func (r *webhookRepository) ListWebhooks(ctx context.Context, owner string) ([]*model.Webhook, error) {
+	return r.listWebhooks(ctx, "webhooks:owner:"+owner)
+}
+
+func (r *webhookRepository) ListUrlWebhooks(ctx context.Context, url string) ([]*model.Webhook, error) {
+	return r.listWebhooks(ctx, "webhooks:url:"+url)
+}
+
+func (r *webhookRepository) listWebhooks(ctx context.Context, setKey string) ([]*model.Webhook, error) {
+	ids, err := r.rdb.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	hooks := []*model.Webhook{}
+	for _, id := range ids {
+		hook, err := r.GetWebhook(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if hook != nil {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+func (r *webhookRepository) DeleteWebhook(ctx context.Context, hook *model.Webhook) error {
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, "webhook:"+hook.Id, "webhook:"+hook.Id+":deliveries", "webhook:"+hook.Id+":dead")
+		pipe.SRem(ctx, "webhooks:owner:"+hook.Owner, hook.Id)
+		for _, u := range hook.Urls {
+			pipe.SRem(ctx, "webhooks:url:"+u, hook.Id)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *webhookRepository) AddDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	if err := r.SaveDelivery(ctx, delivery); err != nil {
+		return err
+	}
+	logKey := "webhook:" + delivery.WebhookId + ":deliveries"
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, logKey, delivery.Id)
+		pipe.LTrim(ctx, logKey, 0, r.conf.GetInt64("webhooks.log_size")-1)
+		pipe.ZAdd(ctx, webhookQueueKey, &redis.Z{Score: float64(delivery.CreatedAt.UnixMilli()), Member: delivery.Id})
+		return nil
+	})
+	return err
+}
+
+func (r *webhookRepository) SaveDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	val, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, "webhook:delivery:"+delivery.Id, val, r.conf.GetDuration("webhooks.log_ttl")).Err()
+}
+
+func (r *webhookRepository) ScheduleDelivery(ctx context.Context, id string, at time.Time) error {
+	return r.rdb.ZAdd(ctx, webhookQueueKey, &redis.Z{Score: float64(at.UnixMilli()), Member: id}).Err()
+}
+
+func (r *webhookRepository) PopDueDeliveries(ctx context.Context, now time.Time, limit int64) ([]*model.WebhookDelivery, error) {
+	ids, err := r.rdb.ZRangeByScore(ctx, webhookQueueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []*model.WebhookDelivery
+	for _, id := range ids {
+		// ZRem 成功的节点负责投递
+		removed, err := r.rdb.ZRem(ctx, webhookQueueKey, id).Result()
+		if err != nil {
+			return deliveries, err
+		}
+		if removed == 0 {
+			continue
+		}
+		delivery, err := r.getDelivery(ctx, id)
+		if err != nil {
+			return deliveries, err
+		}
+		if delivery != nil {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) DeadLetter(ctx context.Context, delivery *model.WebhookDelivery) error {
+	if err := r.SaveDelivery(ctx, delivery); err != nil {
+		return err
+	}
+	deadKey := "webhook:" + delivery.WebhookId + ":dead"
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, deadKey, delivery.Id)
+		pipe.LTrim(ctx, deadKey, 0, r.conf.GetInt64("webhooks.log_size")-1)
+		return nil
+	})
+	return err
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, webhookId string, dead bool) ([]*model.WebhookDelivery, error) {
+	listKey := "webhook:" + webhookId + ":deliveries"
+	if dead {
+		listKey = "webhook:" + webhookId + ":dead"
+	}
+	ids, err := r.rdb.LRange(ctx, listKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []*model.WebhookDelivery{}
+	for _, id := range ids {
+		delivery, err := r.getDelivery(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		// 超过 webhooks.log_ttl 的记录已过期
+		if delivery != nil {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) getDelivery(ctx context.Context, id string) (*model.WebhookDelivery, error) {
+	val, err := r.rdb.Get(ctx, "webhook:delivery:"+id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var delivery model.WebhookDelivery
+	if err := json.Unmarshal(val, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *webhookRepository) GetPreview(ctx context.Context, url string) (*model.PreviewSnapshot, error) {
+	val, err := r.rdb.Get(ctx, "preview:"+url).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var snapshot model.PreviewSnapshot
+	if err := json.Unmarshal(val, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (r *webhookRepository) SetPreview(ctx context.Context, url string, snapshot model.PreviewSnapshot) error {
+	val, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, "preview:"+url, val, r.conf.GetDuration("webhooks.snapshot_ttl")).Err()
+}
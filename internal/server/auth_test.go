@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 管理接口未携带 admin key 时在调用 handler 前拒绝
+func TestAdminRoutesRequireKey(t *testing.T) {
+	r := newTestEngine(t)
+	routes := []struct {
+		method, target string
+	}{
+		{http.MethodPost, "/v1/warmup"},
+		{http.MethodGet, "/v1/warmup/abc"},
+		{http.MethodGet, "/admin/log/level"},
+		{http.MethodDelete, "/admin/cache?url=https%3A%2F%2Fexample.com"},
+	}
+	for _, route := range routes {
+		for _, key := range []string{"", "wrong"} {
+			req := httptest.NewRequest(route.method, route.target, strings.NewReader(`{}`))
+			if key != "" {
+				req.Header.Set("X-API-Key", key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with key %q: status %d, want 401", route.method, route.target, key, w.Code)
+			}
+		}
+	}
+}
@@ -3,24 +3,98 @@ package server
 import (
 	"ogimg/internal/handler"
 	"ogimg/internal/middleware"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/config"
+	"ogimg/pkg/helper/resp"
 	"ogimg/pkg/log"
+	"ogimg/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
 )
 
 func NewServerHTTP(
 	logger *log.Logger,
+	conf *config.Config,
 	userHandler *handler.UserHandler,
 	imageHandler *handler.ImageHandler,
+	warmupHandler *handler.WarmupHandler,
+	jobHandler *handler.JobHandler,
+	webhookHandler *handler.WebhookHandler,
+	healthHandler *handler.HealthHandler,
+	adminHandler *handler.AdminHandler,
+	historyHandler *handler.HistoryHandler,
+	mockupHandler *handler.MockupHandler,
+	cardHandler *handler.CardHandler,
+	oembedHandler *handler.OEmbedHandler,
 ) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
 	r.Use(
+		gin.Recovery(),
+		middleware.RequestId(logger),
+		middleware.Tracing(logger),
+		middleware.DebugLog(logger, conf),
+		middleware.AccessLog(logger),
+		middleware.Metrics(),
 		middleware.CORSMiddleware(),
 	)
-	r.GET("/", imageHandler.GetOgImageByUrl)
-	r.GET("/desc", imageHandler.GetOgDescByUrl)
+	r.NoRoute(func(ctx *gin.Context) {
+		resp.HandleAPIError(ctx, apierr.NotFound.WithMessage("route not found"), nil)
+	})
+
+	v1 := r.Group("/v1", middleware.Sign(conf))
+	{
+		v1.GET("/image", imageHandler.GetOgImageByUrl)
+		v1.GET("/icon", imageHandler.GetIconByUrl)
+		v1.GET("/desc", imageHandler.GetOgDescByUrl)
+		v1.POST("/desc/batch", imageHandler.GetOgDescBatch)
+		v1.GET("/validate", imageHandler.ValidateByUrl)
+		v1.GET("/mockup", mockupHandler.GetMockup)
+		v1.GET("/card", cardHandler.GetCard)
+		v1.GET("/oembed", oembedHandler.GetOEmbed)
+		v1.POST("/warmup", middleware.AdminKey(conf), warmupHandler.StartWarmup)
+		v1.GET("/warmup/:id", middleware.AdminKey(conf), warmupHandler.GetWarmup)
+		v1.POST("/jobs", middleware.APIKey(conf, false), jobHandler.CreateJob)
+		v1.GET("/jobs/:id", middleware.APIKey(conf, false), jobHandler.GetJob)
+		v1.GET("/history", historyHandler.GetHistory)
+		v1.GET("/changes", historyHandler.ListChanges)
+	}
+
+	webhooks := v1.Group("/webhooks", middleware.APIKey(conf, true))
+	{
+		webhooks.POST("", webhookHandler.CreateWebhook)
+		webhooks.GET("", webhookHandler.ListWebhooks)
+		webhooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+		webhooks.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+	}
+
+	admin := r.Group("/admin", middleware.AdminKey(conf))
+	{
+		admin.GET("/log/level", adminHandler.GetLogLevel)
+		admin.PUT("/log/level", adminHandler.SetLogLevel)
+		admin.DELETE("/cache", adminHandler.PurgeCache)
+	}
+
+	// 兼容旧版本的路由
+	r.GET("/", middleware.Sign(conf), imageHandler.GetOgImageByUrl)
+	r.GET("/desc", middleware.Sign(conf), imageHandler.GetOgDescByUrl)
+	r.POST("/desc/batch", middleware.Sign(conf), imageHandler.GetOgDescBatch)
+	r.GET("/validate", middleware.Sign(conf), imageHandler.ValidateByUrl)
+	r.GET("/mockup", middleware.Sign(conf), mockupHandler.GetMockup)
+	r.GET("/card", middleware.Sign(conf), cardHandler.GetCard)
+	r.GET("/oembed", middleware.Sign(conf), oembedHandler.GetOEmbed)
+	r.POST("/jobs", middleware.Sign(conf), middleware.APIKey(conf, false), jobHandler.CreateJob)
+	r.GET("/jobs/:id", middleware.Sign(conf), middleware.APIKey(conf, false), jobHandler.GetJob)
+
 	r.GET("/user", userHandler.GetUserById)
+	if conf.GetBool("metrics.enabled") {
+		r.GET("/metrics", middleware.MetricsKey(conf), gin.WrapH(metrics.Handler()))
+	}
+	r.GET("/healthz", healthHandler.Healthz)
+	r.GET("/readyz", healthHandler.Readyz)
+	r.GET("/version", healthHandler.Version)
+
+	registerOpenAPI(r)
 
 	return r
 }
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ogimg/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func scrape(t *testing.T, r *gin.Engine, key string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// 请求按路由模板和状态码统计，未匹配的路由记为 unmatched
+func TestMetricsLabels(t *testing.T) {
+	r := newTestEngine(t)
+	for _, target := range []string{"/v1/jobs/abc?x=1", "/no/such/route"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-API-Key", "wrong")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := scrape(t, r, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	for _, want := range []string{
+		`ogimg_http_requests_total{method="GET",route="/v1/jobs/:id",status="401"}`,
+		`ogimg_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		`ogimg_http_request_duration_seconds_count{method="GET",route="/v1/jobs/:id",status="401"}`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics are missing %s", want)
+		}
+	}
+	if strings.Contains(w.Body.String(), "/v1/jobs/abc") {
+		t.Error("metrics use the request path instead of the route template")
+	}
+}
+
+func TestMetricsAccess(t *testing.T) {
+	r := newTestEngineWith(t, func(conf *config.Config) {
+		conf.Set("metrics.enabled", false)
+	})
+	if w := scrape(t, r, ""); w.Code != http.StatusNotFound {
+		t.Errorf("disabled metrics: status %d, want 404", w.Code)
+	}
+
+	r = newTestEngineWith(t, func(conf *config.Config) {
+		conf.Set("metrics.keys", []string{"scrape-key"})
+	})
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "scrape-key": http.StatusOK} {
+		if w := scrape(t, r, key); w.Code != want {
+			t.Errorf("key %q: status %d, want %d", key, w.Code, want)
+		}
+	}
+}
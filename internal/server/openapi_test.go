@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+var pathParamRe = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+type openAPIDoc struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadSpec(t *testing.T) openAPIDoc {
+	t.Helper()
+	var spec openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("invalid openapi.json: %v", err)
+	}
+	return spec
+}
+
+// newTestEngine 只注册路由，不调用 handler，handler 可以为 nil
+func newTestEngine(t *testing.T) *gin.Engine {
+	t.Helper()
+	return newTestEngineWith(t, nil)
+}
+
+// newTestEngineWith 默认开启所有可选的路由，setup 可以修改配置
+func newTestEngineWith(t *testing.T, setup func(conf *config.Config)) *gin.Engine {
+	t.Helper()
+	conf := config.New(viper.New())
+	conf.Set("log.log_file_name", filepath.Join(t.TempDir(), "server.log"))
+	conf.Set("log.log_level", "error")
+	conf.Set("metrics.enabled", true)
+	if setup != nil {
+		setup(conf)
+	}
+	return NewServerHTTP(log.NewLog(conf), conf, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+}
+
+// routeKey gin 的 :id 对应 OpenAPI 的 {id}
+func routeKey(method, path string) string {
+	return strings.ToLower(method) + " " + pathParamRe.ReplaceAllString(path, "{$1}")
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	spec := loadSpec(t)
+	var missing []string
+	for _, route := range newTestEngine(t).Routes() {
+		path := pathParamRe.ReplaceAllString(route.Path, "{$1}")
+		if _, ok := spec.Paths[path][strings.ToLower(route.Method)]; !ok {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("routes missing from openapi.json:\n  %s", strings.Join(missing, "\n  "))
+	}
+}
+
+func TestDocumentedRoutesExist(t *testing.T) {
+	registered := map[string]bool{}
+	for _, route := range newTestEngine(t).Routes() {
+		registered[routeKey(route.Method, route.Path)] = true
+	}
+	var stale []string
+	for path, ops := range loadSpec(t).Paths {
+		for method := range ops {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+			default:
+				// parameters 等公共字段
+				continue
+			}
+			if !registered[method+" "+path] {
+				stale = append(stale, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	sort.Strings(stale)
+	if len(stale) > 0 {
+		t.Errorf("openapi.json describes routes that are not registered:\n  %s", strings.Join(stale, "\n  "))
+	}
+}
+
+func TestServeSpec(t *testing.T) {
+	r := newTestEngine(t)
+	for path, contentType := range map[string]string{"/openapi.json": "application/json", "/docs": "text/html"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), contentType) {
+			t.Errorf("%s: status = %d, content type = %s", path, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+)
+
+func TestGetOgDescBatch(t *testing.T) {
+	env := newTestEnv(t, nil)
+	env.conf.Set("batch.concurrency", 3)
+	site := newTestSite(t)
+
+	var urls []string
+	for i := 0; i < 10; i++ {
+		urls = append(urls, site.html(fmt.Sprintf("/p%d", i), fmt.Sprintf(`<html><head><title>page %d</title><meta name="description" content="desc %d"></head></html>`, i, i)))
+	}
+	urls = append(urls, site.URL+"/missing")
+
+	results := env.images.GetOgDescBatch(context.Background(), urls)
+	if len(results) != len(urls) {
+		t.Fatalf("got %d results, want %d", len(results), len(urls))
+	}
+	for i, r := range results[:10] {
+		if r.Url != urls[i] {
+			t.Errorf("result %d url = %s, want %s", i, r.Url, urls[i])
+		}
+		if r.Error != nil || r.Desc == nil || r.Desc.Title != fmt.Sprintf("page %d", i) || r.Desc.Description != fmt.Sprintf("desc %d", i) {
+			t.Errorf("result %d = %+v", i, r)
+		}
+	}
+	// 单个 url 的错误不影响其它结果
+	last := results[10]
+	if last.Desc != nil || last.Error == nil || last.Error.Reason != apierr.UpstreamStatus.Reason {
+		t.Errorf("missing page result = %+v", last)
+	}
+
+	// 第二次全部命中缓存，不再请求站点
+	env.images.GetOgDescBatch(context.Background(), urls[:10])
+	for i := 0; i < 10; i++ {
+		if n := site.count(fmt.Sprintf("/p%d", i)); n != 1 {
+			t.Errorf("/p%d fetched %d times, want 1", i, n)
+		}
+	}
+	desc, err := env.images.GetOgDescByUrl(context.Background(), urls[0])
+	if err != nil || desc.Cache != model.CacheHit {
+		t.Errorf("expected cache hit, got %+v, %v", desc, err)
+	}
+}
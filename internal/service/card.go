@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/card"
+
+	"go.uber.org/zap"
+)
+
+type CardService interface {
+	// GetCard 渲染 url 的预览卡片，图片通过 baseUrl 下的 /v1/image 代理
+	GetCard(ctx context.Context, userUrl string, baseUrl string, theme string) (*model.Card, error)
+}
+
+type cardService struct {
+	service      *Service
+	imageService ImageService
+}
+
+func NewCardService(service *Service, imageService ImageService) CardService {
+	return &cardService{
+		service:      service,
+		imageService: imageService,
+	}
+}
+
+func (s *cardService) GetCard(ctx context.Context, userUrl string, baseUrl string, theme string) (*model.Card, error) {
+	desc, err := s.imageService.GetOgDescByUrl(ctx, userUrl)
+	if err != nil {
+		return nil, err
+	}
+	c := card.Card{
+		Url:         userUrl,
+		Title:       desc.Desc.Title,
+		Description: desc.Desc.Description,
+		Logo:        desc.Desc.Logo,
+		OEmbed:      endpointUrl(baseUrl, "/v1/oembed", url.Values{"url": {userUrl}, "format": {"json"}}),
+	}
+
+	// 先取一次图片，没有图片时不输出 img，同时预热图片缓存
+	_, err = s.imageService.GetOgImageByUrl(ctx, userUrl)
+	switch {
+	case err == nil:
+		c.Image = endpointUrl(baseUrl, "/v1/image", url.Values{"url": {userUrl}})
+	case !errors.Is(err, apierr.NoImage):
+		s.service.logger.WithContext(ctx).Warn("Card without image", zap.String("url", userUrl), zap.Error(err))
+	}
+
+	html, err := card.Render(c, theme)
+	if err != nil {
+		return nil, err
+	}
+	c = card.Sanitize(c)
+	return &model.Card{
+		Url:         c.Url,
+		Title:       c.Title,
+		Description: c.Description,
+		Logo:        c.Logo,
+		Image:       c.Image,
+		OEmbed:      c.OEmbed,
+		Theme:       theme,
+		Html:        html,
+	}, nil
+}
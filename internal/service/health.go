@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ogimg/internal/model"
+	"ogimg/internal/repository"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type HealthService interface {
+	// Ready 检查 redis、数据库，以及配置了 health.fetch_url 时的抓取自检
+	Ready(ctx context.Context) *model.Readiness
+}
+
+type healthService struct {
+	service    *Service
+	repository *repository.Repository
+	extractor  *extract.Extractor
+
+	mu        sync.Mutex
+	fetch     model.HealthCheck
+	fetchedAt time.Time
+}
+
+func NewHealthService(service *Service, repository *repository.Repository) HealthService {
+	return &healthService{
+		service:    service,
+		repository: repository,
+		extractor:  service.newExtractor(),
+	}
+}
+
+func (s *healthService) Ready(ctx context.Context) *model.Readiness {
+	ctx, cancel := context.WithTimeout(ctx, s.service.conf.GetDuration("health.timeout"))
+	defer cancel()
+
+	checks := map[string]model.HealthCheck{
+		"redis": s.runCheck(ctx, "redis", s.repository.PingRedis),
+		"db":    s.runCheck(ctx, "db", s.repository.PingDB),
+		"fetch": s.checkFetch(ctx),
+	}
+	readiness := &model.Readiness{Status: model.ReadyOk, Checks: checks}
+	for _, check := range checks {
+		if check.Status == model.HealthFailed {
+			readiness.Status = model.ReadyUnavailable
+		}
+	}
+	return readiness
+}
+
+// checkFetch 抓取自检的结果缓存 health.fetch_interval，避免探针频繁访问外部站点
+func (s *healthService) checkFetch(ctx context.Context) model.HealthCheck {
+	fetchUrl := s.service.conf.GetString("health.fetch_url")
+	if fetchUrl == "" {
+		return model.HealthCheck{Status: model.HealthSkipped}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fetchedAt.IsZero() && time.Since(s.fetchedAt) < s.service.conf.GetDuration("health.fetch_interval") {
+		return s.fetch
+	}
+	s.fetch = s.runCheck(ctx, "fetch", func(ctx context.Context) error {
+		res, err := s.extractor.Get(ctx, fetchUrl)
+		if err != nil {
+			return err
+		}
+		return res.Body.Close()
+	})
+	s.fetchedAt = time.Now()
+	return s.fetch
+}
+
+// runCheck 执行一项检查，错误详情（地址、驱动信息）只写入日志
+func (s *healthService) runCheck(ctx context.Context, name string, check func(ctx context.Context) error) model.HealthCheck {
+	start := time.Now()
+	err := check(ctx)
+	result := model.HealthCheck{Status: model.HealthOk, LatencyMs: time.Since(start).Milliseconds()}
+	if errors.Is(err, repository.ErrDbNotConfigured) {
+		result.Status = model.HealthSkipped
+	} else if err != nil {
+		result.Status = model.HealthFailed
+		result.Error = apierr.From(err).Message
+		s.service.logger.WithContext(ctx).Warn("Health check failed", zap.String("check", name), zap.Error(err))
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ogimg/internal/model"
+)
+
+func TestReady(t *testing.T) {
+	env := newTestEnv(t, nil)
+	health := NewHealthService(env.service, env.repository)
+
+	ready := health.Ready(context.Background())
+	if ready.Status != model.ReadyOk ||
+		ready.Checks["redis"].Status != model.HealthOk ||
+		ready.Checks["db"].Status != model.HealthOk ||
+		ready.Checks["fetch"].Status != model.HealthSkipped {
+		t.Errorf("readiness = %+v", ready)
+	}
+
+	// 错误信息中不包含 redis 地址
+	addr := env.redis.Addr()
+	env.redis.Close()
+	ready = health.Ready(context.Background())
+	redis := ready.Checks["redis"]
+	if ready.Status != model.ReadyUnavailable || redis.Status != model.HealthFailed || redis.Error == "" {
+		t.Errorf("readiness without redis = %+v", ready)
+	}
+	if strings.Contains(redis.Error, addr) {
+		t.Errorf("error leaks the redis address: %q", redis.Error)
+	}
+}
+
+func TestReadyWithoutDb(t *testing.T) {
+	conf := newTestConfig(t)
+	conf.Set("data.db.driver", "none")
+	env := newTestEnv(t, conf)
+
+	ready := NewHealthService(env.service, env.repository).Ready(context.Background())
+	if ready.Status != model.ReadyOk || ready.Checks["db"].Status != model.HealthSkipped {
+		t.Errorf("readiness = %+v", ready)
+	}
+}
+
+// 抓取自检的结果在 health.fetch_interval 内复用
+func TestReadyFetch(t *testing.T) {
+	site := newTestSite(t)
+	conf := newTestConfig(t)
+	conf.Set("health.fetch_url", site.html("/ping", "<html></html>"))
+	conf.Set("health.fetch_interval", "1h")
+	env := newTestEnv(t, conf)
+	health := NewHealthService(env.service, env.repository)
+
+	for i := 0; i < 3; i++ {
+		if ready := health.Ready(context.Background()); ready.Status != model.ReadyOk || ready.Checks["fetch"].Status != model.HealthOk {
+			t.Fatalf("readiness = %+v", ready)
+		}
+	}
+	if n := site.count("/ping"); n != 1 {
+		t.Errorf("fetch_url fetched %d times, want 1", n)
+	}
+
+	conf.Set("health.fetch_url", site.URL+"/missing")
+	conf.Set("health.fetch_interval", "1ns")
+	ready := health.Ready(context.Background())
+	if ready.Status != model.ReadyUnavailable || ready.Checks["fetch"].Status != model.HealthFailed {
+		t.Errorf("readiness with failing fetch = %+v", ready)
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ogimg/internal/model"
+	"ogimg/internal/repository"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/imaging"
+	"strconv"
+	"time"
+)
+
+type HistoryService interface {
+	// GetHistory 返回 url 的版本时间线，每个版本附带相对上一个版本的变化
+	GetHistory(ctx context.Context, userUrl string) (*model.LinkHistory, error)
+	// ListChanges 返回 since 之后预览发生变化的 url，变化后又改回原样的不返回
+	ListChanges(ctx context.Context, since time.Time, limit int) ([]model.LinkChange, error)
+}
+
+type historyService struct {
+	service        *Service
+	linkRepository repository.LinkRepository
+}
+
+func NewHistoryService(service *Service, linkRepository repository.LinkRepository) HistoryService {
+	return &historyService{
+		service:        service,
+		linkRepository: linkRepository,
+	}
+}
+
+func (s *historyService) GetHistory(ctx context.Context, userUrl string) (*model.LinkHistory, error) {
+	link, err := s.linkRepository.GetLink(ctx, userUrl, 0)
+	if err != nil {
+		return nil, historyError(err)
+	}
+	if link == nil {
+		return nil, apierr.NotFound.WithMessage("no history for url")
+	}
+	versions, err := s.linkRepository.ListVersions(ctx, link.Id, s.service.conf.GetInt("history.max_versions"))
+	if err != nil {
+		return nil, historyError(err)
+	}
+
+	history := &model.LinkHistory{
+		Url:         link.Url,
+		FirstSeenAt: link.FirstSeenAt,
+		LastSeenAt:  link.LastSeenAt,
+		Versions:    make([]model.LinkVersionDiff, len(versions)),
+	}
+	for i, version := range versions {
+		diff := model.LinkVersionDiff{LinkVersion: version, Changes: []model.FieldChange{}}
+		// versions 按时间倒序，i+1 为上一个版本
+		if i+1 < len(versions) {
+			diff.Changes = diffVersions(versions[i+1], version)
+			diff.ImageDistance = imageDistance(versions[i+1], version)
+		}
+		history.Versions[i] = diff
+	}
+	return history, nil
+}
+
+func (s *historyService) ListChanges(ctx context.Context, since time.Time, limit int) ([]model.LinkChange, error) {
+	links, err := s.linkRepository.ListChanged(ctx, since, limit)
+	if err != nil {
+		return nil, historyError(err)
+	}
+
+	changes := []model.LinkChange{}
+	for _, link := range links {
+		versions, err := s.linkRepository.ListVersions(ctx, link.Id, 1)
+		if err != nil {
+			return nil, historyError(err)
+		}
+		// 与 since 时生效的版本比较，直接查询，不受 history.max_versions 限制
+		baseline, err := s.linkRepository.BaselineVersion(ctx, link.Id, since)
+		if err != nil {
+			return nil, historyError(err)
+		}
+		if len(versions) == 0 || baseline == nil {
+			continue
+		}
+		latest := versions[0]
+		fields := diffVersions(*baseline, latest)
+		if len(fields) == 0 {
+			continue
+		}
+		changes = append(changes, model.LinkChange{
+			Url:           link.Url,
+			ChangedAt:     latest.CreatedAt,
+			Changes:       fields,
+			ImageDistance: imageDistance(*baseline, latest),
+		})
+	}
+	return changes, nil
+}
+
+// diffVersions 返回 prev 到 next 变化的字段，图片地址不变但内容变化时记为 image_hash
+func diffVersions(prev, next model.LinkVersion) []model.FieldChange {
+	changes := []model.FieldChange{}
+	for _, f := range []struct{ field, old, new string }{
+		{"title", prev.Title, next.Title},
+		{"description", prev.Description, next.Description},
+		{"image", prev.Image, next.Image},
+		{"image_hash", prev.ImageHash, next.ImageHash},
+		{"logo", prev.Logo, next.Logo},
+		{"canonical_url", prev.CanonicalUrl, next.CanonicalUrl},
+	} {
+		if f.old != f.new {
+			changes = append(changes, model.FieldChange{Field: f.field, Old: f.old, New: f.new})
+		}
+	}
+	return changes
+}
+
+// imageDistance 两个版本图片感知哈希的汉明距离，任一版本没有哈希时返回 nil
+func imageDistance(prev, next model.LinkVersion) *int {
+	a, err := strconv.ParseUint(prev.ImagePHash, 16, 64)
+	if err != nil {
+		return nil
+	}
+	b, err := strconv.ParseUint(next.ImagePHash, 16, 64)
+	if err != nil {
+		return nil
+	}
+	distance := imaging.Distance(a, b)
+	return &distance
+}
+
+func historyError(err error) error {
+	if errors.Is(err, repository.ErrDbNotConfigured) {
+		return apierr.NotFound.WithMessage("history requires a database, see data.db.driver")
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+)
+
+func TestGetHistory(t *testing.T) {
+	env := newTestEnv(t, nil)
+	history := NewHistoryService(env.service, env.links)
+	ctx := context.Background()
+	const url = "https://example.com/page"
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	save := func(link model.LinkRecord, offset time.Duration) {
+		t.Helper()
+		link.Url = url
+		if err := env.links.SaveLink(ctx, &link, &model.LinkFetch{Status: model.LinkFetchOk, FetchedAt: at.Add(offset)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := history.GetHistory(ctx, url); !errors.Is(err, apierr.NotFound) {
+		t.Errorf("unknown url = %v", err)
+	}
+
+	save(model.LinkRecord{Title: "v1", Image: "https://example.com/a.png", ImageHash: "h1", ImagePHash: "000000000000000f"}, 0)
+	// 图片地址不变但内容变化
+	save(model.LinkRecord{Title: "v1", Image: "https://example.com/a.png", ImageHash: "h2", ImagePHash: "00000000000000ff"}, time.Hour)
+	save(model.LinkRecord{Title: "v2", Image: "https://example.com/a.png", ImageHash: "h2", ImagePHash: "00000000000000ff"}, 2*time.Hour)
+
+	got, err := history.GetHistory(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Url != url || len(got.Versions) != 3 || !got.FirstSeenAt.Equal(at) || !got.LastSeenAt.Equal(at.Add(2*time.Hour)) {
+		t.Fatalf("history = %+v", got)
+	}
+	latest, middle, first := got.Versions[0], got.Versions[1], got.Versions[2]
+	if len(latest.Changes) != 1 || latest.Changes[0] != (model.FieldChange{Field: "title", Old: "v1", New: "v2"}) {
+		t.Errorf("latest changes = %+v", latest.Changes)
+	}
+	if latest.ImageDistance == nil || *latest.ImageDistance != 0 {
+		t.Errorf("latest image distance = %v", latest.ImageDistance)
+	}
+	if len(middle.Changes) != 1 || middle.Changes[0].Field != "image_hash" || middle.ImageDistance == nil || *middle.ImageDistance != 4 {
+		t.Errorf("middle = %+v, distance %v", middle.Changes, middle.ImageDistance)
+	}
+	if len(first.Changes) != 0 || first.ImageDistance != nil {
+		t.Errorf("first version = %+v", first)
+	}
+
+	// history.max_versions 限制返回的版本数
+	env.conf.Set("history.max_versions", 2)
+	if got, err := history.GetHistory(ctx, url); err != nil || len(got.Versions) != 2 || len(got.Versions[1].Changes) != 0 {
+		t.Errorf("limited history = %+v, %v", got, err)
+	}
+}
+
+func TestListChanges(t *testing.T) {
+	env := newTestEnv(t, nil)
+	history := NewHistoryService(env.service, env.links)
+	ctx := context.Background()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	save := func(url, title string, offset time.Duration) {
+		t.Helper()
+		link := &model.LinkRecord{Url: url, Title: title}
+		if err := env.links.SaveLink(ctx, link, &model.LinkFetch{Status: model.LinkFetchOk, FetchedAt: at.Add(offset)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	save("https://a.example.com", "a1", 0)
+	save("https://a.example.com", "a2", 2*time.Hour)
+	save("https://a.example.com", "a3", 3*time.Hour)
+	// 改回原样的不算变化
+	save("https://b.example.com", "b1", 0)
+	save("https://b.example.com", "b2", 2*time.Hour)
+	save("https://b.example.com", "b1", 3*time.Hour)
+
+	changes, err := history.ListChanges(ctx, at.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("changes = %+v", changes)
+	}
+	change := changes[0]
+	// 与 since 时生效的版本比较，而不是上一个版本
+	if change.Url != "https://a.example.com" || !change.ChangedAt.Equal(at.Add(3*time.Hour)) ||
+		len(change.Changes) != 1 || change.Changes[0] != (model.FieldChange{Field: "title", Old: "a1", New: "a3"}) {
+		t.Errorf("change = %+v", change)
+	}
+
+	// since 时生效的版本不在 history.max_versions 以内时仍然作为比较的基准
+	env.conf.Set("history.max_versions", 2)
+	save("https://a.example.com", "a4", 4*time.Hour)
+	changes, err = history.ListChanges(ctx, at.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || len(changes[0].Changes) != 1 || changes[0].Changes[0] != (model.FieldChange{Field: "title", Old: "a1", New: "a4"}) {
+		t.Errorf("changes = %+v", changes)
+	}
+}
+
+func TestHistoryWithoutDb(t *testing.T) {
+	conf := newTestConfig(t)
+	conf.Set("data.db.driver", "none")
+	env := newTestEnv(t, conf)
+	history := NewHistoryService(env.service, env.links)
+
+	_, err := history.GetHistory(context.Background(), "https://example.com")
+	var apiErr *apierr.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, apierr.NotFound) || apiErr.Message != "history requires a database, see data.db.driver" {
+		t.Errorf("get history = %v", err)
+	}
+	if _, err := history.ListChanges(context.Background(), time.Time{}, 10); !errors.Is(err, apierr.NotFound) {
+		t.Errorf("list changes = %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+)
+
+func TestGetIconByUrl(t *testing.T) {
+	env := newTestEnv(t, nil)
+	site := newTestSite(t)
+	icon := testPNG(t, 16, 16)
+	site.file("/static/icon.png", "image/png", icon)
+	declared := site.html("/declared", `<html><head><title>a</title><link rel="icon" href="/static/icon.png"></head></html>`)
+
+	img, err := env.images.GetIconByUrl(context.Background(), declared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img.Data, icon) || img.ContentType != "image/png" || img.Cache != model.CacheMiss {
+		t.Errorf("icon = %s %d bytes, cache %s", img.ContentType, len(img.Data), img.Cache)
+	}
+	img, err = env.images.GetIconByUrl(context.Background(), declared)
+	if err != nil || img.Cache != model.CacheHit || !bytes.Equal(img.Data, icon) {
+		t.Errorf("expected cache hit, got %+v, %v", img, err)
+	}
+	if n := site.count("/static/icon.png"); n != 1 {
+		t.Errorf("icon fetched %d times, want 1", n)
+	}
+
+	// 没有声明图标时使用 /favicon.ico，站点也没有时返回 not_found
+	plain := site.html("/plain", `<html><head><title>b</title></head></html>`)
+	if _, err := env.images.GetIconByUrl(context.Background(), plain); !errors.Is(err, apierr.NotFound) {
+		t.Fatalf("expected not_found, got %v", err)
+	}
+	if n := site.count("/favicon.ico"); n != 1 {
+		t.Errorf("favicon fetched %d times, want 1", n)
+	}
+	site.file("/favicon.ico", "image/png", icon)
+	if img, err := env.images.GetIconByUrl(context.Background(), plain); err != nil || !bytes.Equal(img.Data, icon) {
+		t.Errorf("favicon fallback = %+v, %v", img, err)
+	}
+
+	deleted, err := env.images.PurgeByUrl(context.Background(), declared)
+	if err != nil || deleted != 2 {
+		t.Errorf("purge deleted %d, %v; want icon and desc", deleted, err)
+	}
+	if img, _ := env.images.GetIconByUrl(context.Background(), declared); img.Cache != model.CacheMiss {
+		t.Errorf("icon should be fetched again after purge")
+	}
+}
@@ -1,222 +1,460 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"ogimg/internal/model"
 	"ogimg/internal/repository"
-	"ogimg/pkg/log"
-	"strings"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+	"ogimg/pkg/imaging"
+	"ogimg/pkg/lint"
+	"ogimg/pkg/metrics"
+	"ogimg/pkg/oembed"
+	"sync"
 
-	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-	"golang.org/x/net/html"
+)
+
+const (
+	RobotsModeOff      = "off"
+	RobotsModeAdvisory = "advisory"
+	RobotsModeEnforce  = "enforce"
 )
 
 type ImageService interface {
-	GetOgImageByUrl(ctx *gin.Context, userUrl string) error
-	GetOgDescByUrl(ctx *gin.Context, userUrl string) error
+	GetOgImageByUrl(ctx context.Context, userUrl string) (*model.OgImage, error)
+	GetOgDescByUrl(ctx context.Context, userUrl string) (*model.OgDesc, error)
+	GetOgDescBatch(ctx context.Context, urls []string) []model.BatchDescResult
+	// GetIconByUrl 返回网站图标，页面没有声明时使用站点的 /favicon.ico
+	GetIconByUrl(ctx context.Context, userUrl string) (*model.OgImage, error)
+	// WarmByUrl 只抓取一次页面，同时填充描述和图片缓存，已缓存的部分不再获取；
+	// 失败时返回失败的缓存类型 desc 或 image
+	WarmByUrl(ctx context.Context, userUrl string) (string, error)
+	// PurgeByUrl 删除 url 的所有缓存，返回删除的缓存项数量
+	PurgeByUrl(ctx context.Context, userUrl string) (int64, error)
+	ExtractByUrl(ctx context.Context, userUrl string) (*extract.Metadata, error)
+	ValidateByUrl(ctx context.Context, userUrl string) (*lint.Report, error)
 }
 
 type imageService struct {
-	service    *Service
-	repository *repository.Repository
+	service        *Service
+	repository     *repository.Repository
+	linkRepository repository.LinkRepository
+	webhookService WebhookService
+	extractor      *extract.Extractor
+	oembed         *oembed.Client
 }
 
-func NewImageService(service *Service, repository *repository.Repository) ImageService {
+func NewImageService(service *Service, repository *repository.Repository, linkRepository repository.LinkRepository, webhookService WebhookService) ImageService {
+	extractor := service.newExtractor()
 	return &imageService{
-		service:    service,
-		repository: repository,
+		service:        service,
+		repository:     repository,
+		linkRepository: linkRepository,
+		webhookService: webhookService,
+		extractor:      extractor,
+		oembed:         oembed.NewClient(extractor.Get),
 	}
 }
 
-func (s *imageService) GetOgImageByUrl(ctx *gin.Context, userUrl string) error {
+func (s *imageService) GetOgImageByUrl(ctx context.Context, userUrl string) (*model.OgImage, error) {
 	// 检查缓存
-	imageBytes, err := s.repository.GetWebsiteOgImgFromCache(ctx, userUrl)
-	if err == nil && imageBytes != nil {
-		// 把 bytes 转为图片返回
-		contentType := http.DetectContentType(imageBytes)
-		ctx.Data(http.StatusOK, contentType, imageBytes)
-		return nil
+	imageBytes, stale, err := s.repository.GetWebsiteOgImgFromCache(ctx, userUrl)
+	observeCache("image", imageBytes != nil, stale, err)
+	if err == nil && imageBytes != nil && !stale {
+		return &model.OgImage{
+			Data:        imageBytes,
+			ContentType: http.DetectContentType(imageBytes),
+			Cache:       model.CacheHit,
+		}, nil
+	}
+
+	img, err := s.fetchOgImage(ctx, userUrl)
+	if stale && serveStale(err) {
+		s.service.logger.WithContext(ctx).Warn("Serve stale image", zap.String("url", userUrl), zap.Error(err))
+		return &model.OgImage{
+			Data:        imageBytes,
+			ContentType: http.DetectContentType(imageBytes),
+			Cache:       model.CacheStale,
+		}, nil
+	}
+	return img, err
+}
+
+// fetchOgImage 抓取页面和 og:image 并写入缓存
+func (s *imageService) fetchOgImage(ctx context.Context, userUrl string) (*model.OgImage, error) {
+	if err := s.checkRobots(ctx, userUrl); err != nil {
+		return nil, err
 	}
 
 	// 获取 HTML 内容
-	urlResp, err := http.Get(userUrl)
+	meta, err := s.extract(ctx, userUrl)
 	if err != nil {
-		return err
+		s.recordLink(ctx, userUrl, nil, nil, err)
+		return nil, err
+	}
+	s.webhookService.NotifyPreview(ctx, userUrl, meta)
+	if meta.Image == "" {
+		s.recordLink(ctx, userUrl, meta, nil, nil)
+		return nil, apierr.NoImage
 	}
-	defer urlResp.Body.Close()
 
-	doc, err := html.Parse(urlResp.Body)
+	// 获取图像
+	img, err := s.extractor.FetchImage(ctx, meta.Image)
+	s.recordLink(ctx, userUrl, meta, img, err)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	ogImageUrl := findMetaContent(doc, "og:image")
-	if ogImageUrl == "" {
-		return fmt.Errorf("no og:image found")
+	// 缓存 bytes
+	err = s.repository.SetWebsiteOgImgToCache(ctx, userUrl, img.Data)
+	if err != nil {
+		s.service.logger.WithContext(ctx).Error("Set cache error", zap.Error(err))
 	}
 
-	// 获取图像
-	return fetchAndCacheImage(ctx, ogImageUrl, userUrl, s.repository, s.service.logger)
+	return &model.OgImage{
+		Data:        img.Data,
+		ContentType: img.ContentType,
+		Meta:        meta,
+		Cache:       model.CacheMiss,
+	}, nil
 }
 
-func (s *imageService) GetOgDescByUrl(ctx *gin.Context, userUrl string) error {
+func (s *imageService) GetOgDescByUrl(ctx context.Context, userUrl string) (*model.OgDesc, error) {
 	// 检查缓存
-	descFromCache, err := s.repository.GetWebSiteDescToCache(ctx, userUrl)
-	fmt.Println("descFromCache", descFromCache)
-	if err == nil && descFromCache != (model.WebsiteDescType{}) {
-		// 把 bytes 转为图片返回
-		ctx.JSON(http.StatusOK, descFromCache)
-		return nil
+	descFromCache, stale, err := s.repository.GetWebSiteDescToCache(ctx, userUrl)
+	cached := descFromCache != (model.WebsiteDescType{})
+	observeCache("desc", cached, stale, err)
+	if err == nil && cached && !stale {
+		return &model.OgDesc{Desc: descFromCache, Cache: model.CacheHit}, nil
+	}
+
+	desc, err := s.fetchOgDesc(ctx, userUrl)
+	if stale && serveStale(err) {
+		s.service.logger.WithContext(ctx).Warn("Serve stale desc", zap.String("url", userUrl), zap.Error(err))
+		return &model.OgDesc{Desc: descFromCache, Cache: model.CacheStale}, nil
+	}
+	return desc, err
+}
+
+// fetchOgDesc 抓取页面描述并写入缓存
+func (s *imageService) fetchOgDesc(ctx context.Context, userUrl string) (*model.OgDesc, error) {
+	if err := s.checkRobots(ctx, userUrl); err != nil {
+		return nil, err
 	}
 
-	urlResp, err := http.Get(userUrl)
+	meta, err := s.extract(ctx, userUrl)
+	s.recordLink(ctx, userUrl, meta, nil, err)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer urlResp.Body.Close()
+	s.webhookService.NotifyPreview(ctx, userUrl, meta)
+
+	desc := descOf(meta)
+	s.service.logger.WithContext(ctx).Debug("Extracted desc", zap.String("url", userUrl), zap.Any("desc", desc))
 
-	doc, err := html.Parse(urlResp.Body)
+	err = s.repository.SetWebSiteDescToCache(ctx, userUrl, desc)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	desc := model.WebsiteDescType{}
-	findWebSiteDesc(doc, &desc)
+	return &model.OgDesc{Desc: desc, Meta: meta, Cache: model.CacheMiss}, nil
+}
 
-	// 如果 logo 以 / 开头，则认为是相对路径，需要拼接上域名
-	if strings.HasPrefix(desc.Logo, "/") {
-		// 确保 userUrl 和 desc.Logo 之间只有一个 /
-		// 去除 userUrl 末尾的 /
-		userUrl = strings.TrimRight(userUrl, "/")
-		desc.Logo = fmt.Sprintf("%s%s", userUrl, desc.Logo)
+// descOf 页面的描述信息，logo 已按页面地址转换为绝对地址
+func descOf(meta *extract.Metadata) model.WebsiteDescType {
+	return model.WebsiteDescType{
+		Title:       meta.Title,
+		Description: meta.Description,
+		Logo:        meta.Logo,
 	}
+}
 
-	s.service.logger.Info("desc", zap.Any("desc", desc))
+// GetOgDescBatch 并发获取多个网站的描述，结果顺序与 urls 一致
+func (s *imageService) GetOgDescBatch(ctx context.Context, urls []string) []model.BatchDescResult {
+	results := make([]model.BatchDescResult, len(urls))
 
-	err = s.repository.SetWebSiteDescToCache(ctx, userUrl, desc)
-	if err != nil {
-		return err
+	concurrency := s.service.conf.GetInt("batch.concurrency")
+	if concurrency <= 0 {
+		concurrency = 1
 	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].Url = urls[i]
+				desc, err := s.GetOgDescByUrl(ctx, urls[i])
+				if err != nil {
+					results[i].Error = apierr.From(err)
+					continue
+				}
+				results[i].Desc = &desc.Desc
+			}
+		}()
+	}
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
 
-	ctx.JSON(http.StatusOK, desc)
+func (s *imageService) GetIconByUrl(ctx context.Context, userUrl string) (*model.OgImage, error) {
+	data, stale, err := s.repository.GetWebsiteIconFromCache(ctx, userUrl)
+	observeCache("icon", data != nil, stale, err)
+	if err == nil && data != nil && !stale {
+		return &model.OgImage{Data: data, ContentType: http.DetectContentType(data), Cache: model.CacheHit}, nil
+	}
 
-	return nil
+	icon, err := s.fetchIcon(ctx, userUrl)
+	if stale && serveStale(err) {
+		s.service.logger.WithContext(ctx).Warn("Serve stale icon", zap.String("url", userUrl), zap.Error(err))
+		return &model.OgImage{Data: data, ContentType: http.DetectContentType(data), Cache: model.CacheStale}, nil
+	}
+	return icon, err
 }
 
-// 获取图像并缓存
-func fetchAndCacheImage(ctx *gin.Context, ogImageUrl, userUrl string, repo *repository.Repository, logger *log.Logger) error {
-	imageResp, err := http.Get(ogImageUrl)
+// fetchIcon 按页面描述中的 logo 或 /favicon.ico 获取图标并写入缓存
+func (s *imageService) fetchIcon(ctx context.Context, userUrl string) (*model.OgImage, error) {
+	desc, err := s.GetOgDescByUrl(ctx, userUrl)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	iconUrl := desc.Desc.Logo
+	if iconUrl == "" {
+		u, err := url.Parse(userUrl)
+		if err != nil {
+			return nil, apierr.InvalidUrl.Wrap(err)
+		}
+		iconUrl = u.Scheme + "://" + u.Host + "/favicon.ico"
 	}
-	defer imageResp.Body.Close()
+	img, err := s.extractor.FetchImage(ctx, iconUrl)
+	if errors.Is(err, apierr.UpstreamStatus) {
+		return nil, apierr.NotFound.WithMessage("no icon found").Wrap(err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.SetWebsiteIconToCache(ctx, userUrl, img.Data); err != nil {
+		s.service.logger.WithContext(ctx).Error("Set cache error", zap.Error(err))
+	}
+	return &model.OgImage{Data: img.Data, ContentType: img.ContentType, Cache: model.CacheMiss}, nil
+}
 
-	body, err := io.ReadAll(imageResp.Body)
+func (s *imageService) WarmByUrl(ctx context.Context, userUrl string) (string, error) {
+	// 已过期的缓存重新获取，获取失败时保留
+	desc, stale, err := s.repository.GetWebSiteDescToCache(ctx, userUrl)
+	observeCache("desc", desc != (model.WebsiteDescType{}), stale, err)
+	descCached := err == nil && desc != (model.WebsiteDescType{}) && !stale
+	imageBytes, stale, err := s.repository.GetWebsiteOgImgFromCache(ctx, userUrl)
+	observeCache("image", imageBytes != nil, stale, err)
+	imageCached := err == nil && imageBytes != nil && !stale
+	if descCached && imageCached {
+		return "", nil
+	}
+
+	if err := s.checkRobots(ctx, userUrl); err != nil {
+		return "desc", err
+	}
+	meta, err := s.extract(ctx, userUrl)
 	if err != nil {
-		return err
+		s.recordLink(ctx, userUrl, nil, nil, err)
+		return "desc", err
 	}
+	s.webhookService.NotifyPreview(ctx, userUrl, meta)
 
-	// 缓存 bytes
-	err = repo.SetWebsiteOgImgToCache(ctx, userUrl, body)
+	if !descCached {
+		if err := s.repository.SetWebSiteDescToCache(ctx, userUrl, descOf(meta)); err != nil {
+			s.recordLink(ctx, userUrl, meta, nil, nil)
+			return "desc", err
+		}
+	}
+	if imageCached {
+		s.recordLink(ctx, userUrl, meta, nil, nil)
+		return "", nil
+	}
+	if meta.Image == "" {
+		s.recordLink(ctx, userUrl, meta, nil, nil)
+		return "image", apierr.NoImage
+	}
+	img, err := s.extractor.FetchImage(ctx, meta.Image)
+	s.recordLink(ctx, userUrl, meta, img, err)
 	if err != nil {
-		logger.Error("Set cache error", zap.Error(err))
+		return "image", err
 	}
+	if err := s.repository.SetWebsiteOgImgToCache(ctx, userUrl, img.Data); err != nil {
+		return "image", err
+	}
+	return "", nil
+}
 
-	ctx.Data(http.StatusOK, imageResp.Header.Get("Content-Type"), body)
-	return nil
+func (s *imageService) PurgeByUrl(ctx context.Context, userUrl string) (int64, error) {
+	return s.repository.DeleteWebsiteCache(ctx, userUrl)
 }
 
-func findMetaContent(n *html.Node, propertyValue string) string {
-	if n.Type == html.ElementNode && n.Data == "meta" {
-		var property, content string
-		for _, attr := range n.Attr {
-			if attr.Key == "property" {
-				property = attr.Val
-			}
-			if attr.Key == "content" {
-				content = attr.Val
-			}
-			if property == propertyValue && content != "" {
-				return content
-			}
-		}
+// ExtractByUrl 不经过缓存直接抓取页面，用于排查预览问题
+func (s *imageService) ExtractByUrl(ctx context.Context, userUrl string) (*extract.Metadata, error) {
+	if err := s.checkRobots(ctx, userUrl); err != nil {
+		return nil, err
 	}
+	return s.extract(ctx, userUrl)
+}
 
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		result := findMetaContent(c, propertyValue)
-		if result != "" {
-			return result
-		}
+// extract 抓取页面并合并 oEmbed 信息，oEmbed 请求失败时只使用页面的信息；
+// 内置提供方的页面抓取失败时（例如需要执行脚本或登录）只使用 oEmbed 的信息
+func (s *imageService) extract(ctx context.Context, userUrl string) (*extract.Metadata, error) {
+	meta, err := s.extractor.Extract(ctx, userUrl)
+	if !s.service.conf.GetBool("oembed.enabled") {
+		return meta, err
+	}
+	var discovered string
+	if err == nil && s.service.conf.GetBool("oembed.discovery") {
+		discovered = meta.OEmbed
+	}
+	endpoint := oembed.Endpoint(userUrl, discovered)
+	if endpoint == "" {
+		return meta, err
+	}
+	// 发现链接来自被抓取的页面，与页面地址一样受策略限制
+	if !s.allowed(ctx, endpoint) {
+		s.service.logger.WithContext(ctx).Warn("oEmbed endpoint blocked by policy", zap.String("url", userUrl), zap.String("endpoint", endpoint))
+		return meta, err
+	}
+	res, oerr := s.oembed.Fetch(ctx, endpoint)
+	if oerr != nil {
+		s.service.logger.WithContext(ctx).Warn("Fetch oEmbed error", zap.String("url", userUrl), zap.String("endpoint", endpoint), zap.Error(oerr))
+		return meta, err
+	}
+	// 合并的图片地址之后会被下载并返回给调用方
+	if res.ThumbnailUrl != "" && !s.allowed(ctx, res.ThumbnailUrl) {
+		s.service.logger.WithContext(ctx).Warn("oEmbed thumbnail blocked by policy", zap.String("url", userUrl), zap.String("thumbnail", res.ThumbnailUrl))
+		res.ThumbnailUrl = ""
 	}
-	return ""
+	if res.Url != "" && !s.allowed(ctx, res.Url) {
+		res.Url = ""
+	}
+	if err != nil {
+		s.service.logger.WithContext(ctx).Info("Use oEmbed only", zap.String("url", userUrl), zap.Error(err))
+		meta = &extract.Metadata{Url: userUrl}
+	}
+	oembed.Merge(meta, res)
+	return meta, nil
 }
 
-func findWebSiteDesc(n *html.Node, desc *model.WebsiteDescType) {
-	// 找到 head 标签并遍历里面的内容
-	var headNode *html.Node
-	var findHead func(*html.Node) *html.Node
-	findHead = func(n *html.Node) *html.Node {
-		if n.Type == html.ElementNode && n.Data == "head" {
-			return n
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			if result := findHead(c); result != nil {
-				return result
-			}
+// allowed 判断 rawUrl 是否为策略允许访问的 http(s) 地址
+func (s *imageService) allowed(ctx context.Context, rawUrl string) bool {
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	decision, err := s.service.policy.Check(ctx, rawUrl)
+	return err == nil && decision.Allowed
+}
+
+// ValidateByUrl 不经过缓存抓取页面和图片，检查各平台展示预览时可能遇到的问题
+func (s *imageService) ValidateByUrl(ctx context.Context, userUrl string) (*lint.Report, error) {
+	if err := s.checkRobots(ctx, userUrl); err != nil {
+		return nil, err
+	}
+	doc, err := s.extractor.FetchPage(ctx, userUrl)
+	if err != nil {
+		return nil, err
+	}
+	page := lint.Parse(doc, userUrl)
+	issues := lint.CheckPage(page)
+
+	var image *lint.ImageInfo
+	if imageUrl := page.ImageUrl(); imageUrl != "" {
+		img, err := s.extractor.FetchImage(ctx, imageUrl)
+		var imageIssues []lint.Issue
+		image, imageIssues = lint.CheckImage(page, imageUrl, img, err)
+		issues = append(issues, imageIssues...)
+	}
+	return lint.NewReport(userUrl, issues, image), nil
+}
+
+// recordLink 把抓取结果写入数据库，redis 清空后仍可查到，写入失败只记录日志
+func (s *imageService) recordLink(ctx context.Context, userUrl string, meta *extract.Metadata, img *extract.Image, fetchErr error) {
+	link := &model.LinkRecord{Url: userUrl}
+	fetch := &model.LinkFetch{Status: model.LinkFetchOk}
+	if fetchErr != nil {
+		apiErr := apierr.From(fetchErr)
+		fetch.Status = apiErr.Reason
+		fetch.Error = apiErr.Message
+	}
+	if meta != nil {
+		link.CanonicalUrl = meta.Canonical
+		link.Title = meta.Title
+		link.Description = meta.Description
+		link.Logo = meta.Logo
+		link.Image = meta.Image
+	}
+	if img != nil {
+		sum := sha256.Sum256(img.Data)
+		link.ImageHash = hex.EncodeToString(sum[:])
+		// svg 等无法解码的图片没有感知哈希
+		if phash, err := imaging.DHash(img.Data); err == nil {
+			link.ImagePHash = fmt.Sprintf("%016x", phash)
 		}
+	}
+	if err := s.linkRepository.SaveLink(ctx, link, fetch); err != nil {
+		s.service.logger.WithContext(ctx).Error("Save link record error", zap.String("url", userUrl), zap.Error(err))
+	}
+}
+
+// observeCache 缓存读取失败时记为 error 并按未命中处理，已过期的缓存项记为 stale
+func observeCache(kind string, hit, stale bool, err error) {
+	switch {
+	case err != nil:
+		metrics.ObserveCache(kind, metrics.CacheError)
+	case hit && stale:
+		metrics.ObserveCache(kind, metrics.CacheStale)
+	case hit:
+		metrics.ObserveCache(kind, metrics.CacheHit)
+	default:
+		metrics.ObserveCache(kind, metrics.CacheMiss)
+	}
+}
+
+// serveStale 重新获取因上游不可用失败时返回已过期的缓存，页面本身的变化（如删除了 og:image）不使用旧缓存
+func serveStale(err error) bool {
+	return errors.Is(err, apierr.UpstreamTimeout) || errors.Is(err, apierr.UpstreamStatus) || errors.Is(err, apierr.UpstreamUnreachable)
+}
+
+// checkRobots 根据 crawler.robots.mode 检查 robots.txt：off 不检查，advisory 只记录日志，enforce 拒绝抓取
+func (s *imageService) checkRobots(ctx context.Context, userUrl string) error {
+	mode := s.service.conf.GetString("crawler.robots.mode")
+	if mode != RobotsModeAdvisory && mode != RobotsModeEnforce {
 		return nil
 	}
-	headNode = findHead(n)
-	if headNode != nil {
-		for c := headNode.FirstChild; c != nil; c = c.NextSibling {
-			if c.Type == html.ElementNode {
-				switch c.Data {
-				case "meta":
-					var isDescription bool
-
-					for _, attr := range c.Attr {
-						if (attr.Key == "name" && attr.Val == "description") ||
-							(attr.Key == "property" && attr.Val == "og:description") {
-							isDescription = true
-							break
-						}
-					}
-
-					if isDescription {
-						for _, attr := range c.Attr {
-							if attr.Key == "content" {
-								desc.Description = attr.Val
-								break
-							}
-						}
-					}
-				case "link":
-					var isIcon bool
-
-					for _, attr := range c.Attr {
-						if attr.Key == "rel" && attr.Val == "icon" {
-							isIcon = true
-							break
-						}
-					}
-
-					if isIcon {
-						for _, attr := range c.Attr {
-							if attr.Key == "href" {
-								desc.Logo = attr.Val
-								break
-							}
-						}
-					}
-				case "title":
-					if c.FirstChild != nil {
-						desc.Title = c.FirstChild.Data
-					}
-				}
-			}
-		}
+
+	u, err := url.Parse(userUrl)
+	if err != nil {
+		return err
 	}
+	rules, err := s.repository.GetRobots(ctx, userUrl)
+	if err != nil {
+		return err
+	}
+	agent := s.service.conf.GetString("crawler.robots.user_agent")
+	if rules.Allowed(agent, u.RequestURI()) {
+		return nil
+	}
+
+	s.service.logger.WithContext(ctx).Warn("Disallowed by robots.txt", zap.String("url", userUrl), zap.String("mode", mode))
+	if mode == RobotsModeEnforce {
+		return apierr.RobotsDisallowed
+	}
+	return nil
 }
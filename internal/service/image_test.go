@@ -0,0 +1,72 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+)
+
+func TestGetOgImageByUrl(t *testing.T) {
+	env := newTestEnv(t, nil)
+	site := newTestSite(t)
+	img := testPNG(t, 40, 20)
+	site.file("/og.png", "image/png", img)
+	page := site.html("/page", `<html><head><title>page</title><meta property="og:image" content="/og.png"></head></html>`)
+
+	got, err := env.images.GetOgImageByUrl(context.Background(), page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data, img) || got.ContentType != "image/png" || got.Cache != model.CacheMiss {
+		t.Errorf("miss = %s %d bytes, cache %s", got.ContentType, len(got.Data), got.Cache)
+	}
+	if got.Meta == nil || got.Meta.Title != "page" || got.Meta.Image != site.URL+"/og.png" {
+		t.Errorf("meta = %+v", got.Meta)
+	}
+
+	got, err = env.images.GetOgImageByUrl(context.Background(), page)
+	if err != nil || got.Cache != model.CacheHit || !bytes.Equal(got.Data, img) || got.ContentType != "image/png" {
+		t.Errorf("expected cache hit, got %+v, %v", got, err)
+	}
+	if n := site.count("/page"); n != 1 {
+		t.Errorf("page fetched %d times, want 1", n)
+	}
+
+	noImage := site.html("/no-image", `<html><head><title>x</title></head></html>`)
+	if _, err := env.images.GetOgImageByUrl(context.Background(), noImage); !errors.Is(err, apierr.NoImage) {
+		t.Errorf("expected no_image, got %v", err)
+	}
+}
+
+// 调用方取消请求时上游请求同时被取消
+func TestGetOgImageCanceled(t *testing.T) {
+	env := newTestEnv(t, nil)
+	canceled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := env.images.GetOgImageByUrl(ctx, srv.URL+"/slow"); err == nil {
+		t.Fatal("expected error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("returned after %s, should stop with the context", d)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Error("upstream request was not canceled")
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"ogimg/internal/model"
+	"ogimg/internal/repository"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/helper/sid"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// jobReapInterval 检查租约过期任务的间隔
+	jobReapInterval = 30 * time.Second
+	// maxJobAttempts 任务被放回队列后最多执行的次数，超过后标记为失败
+	maxJobAttempts = 3
+)
+
+type JobService interface {
+	// CreateJob 将抓取放入队列，立即返回 queued 状态的任务；owner 不为空时完成后通知其 webhook
+	CreateJob(ctx context.Context, owner string, kind string, userUrl string) (*model.Job, error)
+	// GetJob 带 api key 创建的任务只有同一个 key 可以查询，其余调用方返回 not_found
+	GetJob(ctx context.Context, owner string, id string) (*model.Job, error)
+}
+
+type jobService struct {
+	service        *Service
+	imageService   ImageService
+	webhookService WebhookService
+	jobRepository  repository.JobRepository
+	sid            *sid.Sid
+}
+
+// NewJobService 启动 jobs.workers 个 worker 消费队列，并定期把崩溃的 worker 未完成的任务放回队列，
+// 返回的 cleanup 会等待正在执行的任务结束
+func NewJobService(service *Service, imageService ImageService, webhookService WebhookService, jobRepository repository.JobRepository, sid *sid.Sid) (JobService, func()) {
+	s := &jobService{
+		service:        service,
+		imageService:   imageService,
+		webhookService: webhookService,
+		jobRepository:  jobRepository,
+		sid:            sid,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	// 配置中的取值至少为 1，由 config.Validate 检查，未配置时启动 1 个
+	workers := 1
+	if service.conf.IsSet("jobs.workers") {
+		workers = service.conf.GetInt("jobs.workers")
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.reap(ctx, jobReapInterval)
+	}()
+	return s, func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (s *jobService) CreateJob(ctx context.Context, owner string, kind string, userUrl string) (*model.Job, error) {
+	if kind == "" {
+		kind = model.JobKindDesc
+	}
+	if kind != model.JobKindDesc && kind != model.JobKindImage {
+		return nil, apierr.InvalidRequest.WithMessage("kind must be desc or image")
+	}
+	id, err := s.sid.GenString()
+	if err != nil {
+		return nil, err
+	}
+	job := &model.Job{
+		Id:        id,
+		Kind:      kind,
+		Url:       userUrl,
+		Owner:     owner,
+		Status:    model.JobQueued,
+		CreatedAt: time.Now(),
+	}
+	if err := s.jobRepository.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *jobService) GetJob(ctx context.Context, owner string, id string) (*model.Job, error) {
+	job, err := s.jobRepository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// 不区分任务不存在和无权查看，避免泄露其它 key 的任务
+	if job == nil || (job.Owner != "" && job.Owner != owner) {
+		return nil, apierr.NotFound.WithMessage("job not found")
+	}
+	return job, nil
+}
+
+func (s *jobService) work(ctx context.Context) {
+	for {
+		job, err := s.jobRepository.Dequeue(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.service.logger.WithContext(ctx).Error("Dequeue job error", zap.Error(err))
+			time.Sleep(time.Second)
+			continue
+		}
+		s.run(ctx, job)
+	}
+}
+
+func (s *jobService) reap(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := s.jobRepository.Reap(ctx); err != nil && ctx.Err() == nil {
+			s.service.logger.WithContext(ctx).Error("Reap jobs error", zap.Error(err))
+		}
+	}
+}
+
+func (s *jobService) run(ctx context.Context, job *model.Job) {
+	ctx = s.service.logger.ContextWith(ctx, zap.String("job_id", job.Id))
+	job.Attempts++
+	if job.Attempts > maxJobAttempts {
+		job.Status = model.JobFailed
+		job.Error = apierr.Internal.WithMessage("job abandoned after repeated worker failures")
+		job.FinishedAt = now()
+		s.finish(ctx, job)
+		return
+	}
+	job.Status = model.JobRunning
+	job.StartedAt = now()
+	if err := s.jobRepository.Save(ctx, job); err != nil {
+		s.service.logger.WithContext(ctx).Error("Save job error", zap.Error(err))
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, s.service.conf.GetDuration("jobs.timeout"))
+	defer cancel()
+	var err error
+	switch job.Kind {
+	case model.JobKindImage:
+		var img *model.OgImage
+		if img, err = s.imageService.GetOgImageByUrl(runCtx, job.Url); err == nil {
+			job.Image = &model.JobImage{
+				Url:         "/v1/image?url=" + url.QueryEscape(job.Url),
+				ContentType: img.ContentType,
+				Size:        len(img.Data),
+			}
+		}
+	default:
+		var desc *model.OgDesc
+		if desc, err = s.imageService.GetOgDescByUrl(runCtx, job.Url); err == nil {
+			job.Desc = &desc.Desc
+		}
+	}
+
+	if ctx.Err() != nil {
+		// 服务正在退出，不确认任务，租约过期后由其它节点重新执行
+		s.service.logger.WithContext(ctx).Warn("Job interrupted by shutdown")
+		return
+	}
+
+	job.Status = model.JobDone
+	if err != nil {
+		job.Status = model.JobFailed
+		job.Error = apierr.From(err)
+	}
+	job.FinishedAt = now()
+	s.finish(ctx, job)
+}
+
+// finish 写入结果、确认任务并通知 webhook，即使服务正在退出也要写入结果
+func (s *jobService) finish(ctx context.Context, job *model.Job) {
+	if err := s.jobRepository.Save(context.Background(), job); err != nil {
+		s.service.logger.WithContext(ctx).Error("Save job error", zap.Error(err))
+	}
+	if err := s.jobRepository.Ack(context.Background(), job.Id); err != nil {
+		s.service.logger.WithContext(ctx).Error("Ack job error", zap.Error(err))
+	}
+	s.service.logger.WithContext(ctx).Info("Job finished", zap.String("status", job.Status), zap.Int("attempts", job.Attempts))
+
+	if job.Owner != "" {
+		s.webhookService.Notify(context.Background(), job.Owner, model.EventJobCompleted, job)
+	}
+}
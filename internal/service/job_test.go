@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ogimg/internal/model"
+	"ogimg/internal/repository"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/helper/sid"
+)
+
+func newTestJobService(t *testing.T, env *testEnv) (JobService, repository.JobRepository) {
+	t.Helper()
+	jobs := repository.NewJobRepository(env.repository)
+	s, cleanup := NewJobService(env.service, env.images, env.webhooks, jobs, sid.NewSid())
+	t.Cleanup(cleanup)
+	return s, jobs
+}
+
+// waitJob 等待任务结束
+func waitJob(t *testing.T, jobs repository.JobRepository, id string) *model.Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := jobs.Get(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job != nil && job.FinishedAt != nil {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestGetJobOwner(t *testing.T) {
+	env := newTestEnv(t, nil)
+	site := newTestSite(t)
+	page := site.html("/page", `<html><head><title>page</title></head></html>`)
+	s, jobs := newTestJobService(t, env)
+	ctx := context.Background()
+
+	owned, err := s.CreateJob(ctx, "owner-a", model.JobKindDesc, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := s.CreateJob(ctx, "", model.JobKindDesc, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := waitJob(t, jobs, owned.Id); job.Status != model.JobDone || job.Desc == nil || job.Desc.Title != "page" || job.Attempts != 1 {
+		t.Errorf("owned job = %+v", job)
+	}
+
+	if _, err := s.GetJob(ctx, "owner-a", owned.Id); err != nil {
+		t.Errorf("owner should see the job: %v", err)
+	}
+	for _, owner := range []string{"", "owner-b"} {
+		if _, err := s.GetJob(ctx, owner, owned.Id); !errors.Is(err, apierr.NotFound) {
+			t.Errorf("owner %q should get not_found, got %v", owner, err)
+		}
+	}
+	for _, owner := range []string{"", "owner-b"} {
+		if _, err := s.GetJob(ctx, owner, public.Id); err != nil {
+			t.Errorf("job without owner should be visible to %q: %v", owner, err)
+		}
+	}
+}
+
+// 多次被放回队列的任务不再执行
+func TestJobAttemptsExhausted(t *testing.T) {
+	env := newTestEnv(t, nil)
+	site := newTestSite(t)
+	page := site.html("/page", `<html><head><title>page</title></head></html>`)
+	_, jobs := newTestJobService(t, env)
+
+	job := &model.Job{Id: "retried", Kind: model.JobKindDesc, Url: page, Status: model.JobRunning, Attempts: maxJobAttempts, CreatedAt: time.Now()}
+	if err := jobs.Enqueue(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	job = waitJob(t, jobs, job.Id)
+	if job.Status != model.JobFailed || job.Error == nil || job.Error.Reason != apierr.Internal.Reason {
+		t.Errorf("job = %+v", job)
+	}
+	if n := site.count("/page"); n != 0 {
+		t.Errorf("page fetched %d times, want 0", n)
+	}
+	if ids, _ := env.redis.List("jobs:processing"); len(ids) != 0 {
+		t.Errorf("processing = %v, job should be acked", ids)
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+	"ogimg/pkg/imaging"
+	"ogimg/pkg/mockup"
+
+	"go.uber.org/zap"
+)
+
+type MockupService interface {
+	// Render 按 platform 的卡片样式渲染 url 的预览，返回 png
+	Render(ctx context.Context, userUrl string, platform string) ([]byte, error)
+}
+
+type mockupService struct {
+	service      *Service
+	imageService ImageService
+	extractor    *extract.Extractor
+	renderer     *mockup.Renderer
+}
+
+func NewMockupService(service *Service, imageService ImageService) MockupService {
+	fonts, err := mockup.LoadFonts(service.conf.GetString("mockup.font_regular"), service.conf.GetString("mockup.font_bold"))
+	if err != nil {
+		panic(err)
+	}
+	return &mockupService{
+		service:      service,
+		imageService: imageService,
+		extractor:    service.newExtractor(),
+		renderer:     mockup.NewRenderer(fonts, service.conf.GetFloat64("mockup.scale")),
+	}
+}
+
+func (s *mockupService) Render(ctx context.Context, userUrl string, platform string) ([]byte, error) {
+	layout, ok := mockup.Layouts[platform]
+	if !ok {
+		return nil, apierr.InvalidRequest.WithMessage("unknown platform " + platform)
+	}
+	desc, err := s.imageService.GetOgDescByUrl(ctx, userUrl)
+	if err != nil {
+		return nil, err
+	}
+	card := mockup.Card{
+		Url:         userUrl,
+		Title:       desc.Desc.Title,
+		Description: desc.Desc.Description,
+	}
+
+	// 图片取不到时各平台显示纯文字卡片
+	img, err := s.imageService.GetOgImageByUrl(ctx, userUrl)
+	switch {
+	case err == nil:
+		card.Image = s.decode(ctx, img.Data)
+	case !errors.Is(err, apierr.NoImage):
+		s.service.logger.WithContext(ctx).Warn("Mockup without image", zap.String("url", userUrl), zap.Error(err))
+	}
+	if layout.UsesIcon() && desc.Desc.Logo != "" {
+		if logo, err := s.extractor.FetchImage(ctx, desc.Desc.Logo); err == nil {
+			card.Logo = s.decode(ctx, logo.Data)
+		}
+	}
+
+	rendered, err := s.renderer.Render(platform, card)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := imaging.Encode(rendered, "png")
+	return data, err
+}
+
+// decode svg、ico 等无法解码或尺寸过大的图片返回 nil
+func (s *mockupService) decode(ctx context.Context, data []byte) image.Image {
+	img, _, err := imaging.Decode(data)
+	if err != nil {
+		s.service.logger.WithContext(ctx).Debug("Decode image error", zap.Error(err))
+		return nil
+	}
+	return img
+}
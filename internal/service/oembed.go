@@ -0,0 +1,145 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"net/url"
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/card"
+	"ogimg/pkg/imaging"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// cardWidth 与 /v1/card 样式中的 max-width 一致
+	cardWidth = 500
+	// cardMargin iframe 中 body 默认的外边距
+	cardMargin = 8
+)
+
+type OEmbedService interface {
+	// GetOEmbed 返回 url 的 oEmbed 响应，有标题或描述时为 rich，只有图片时为 photo
+	GetOEmbed(ctx context.Context, userUrl string, opts model.OEmbedOptions) (*model.OEmbed, error)
+}
+
+type oembedService struct {
+	service      *Service
+	imageService ImageService
+}
+
+func NewOEmbedService(service *Service, imageService ImageService) OEmbedService {
+	return &oembedService{
+		service:      service,
+		imageService: imageService,
+	}
+}
+
+func (s *oembedService) GetOEmbed(ctx context.Context, userUrl string, opts model.OEmbedOptions) (*model.OEmbed, error) {
+	desc, err := s.imageService.GetOgDescByUrl(ctx, userUrl)
+	if err != nil {
+		return nil, err
+	}
+	c := card.Sanitize(card.Card{Url: userUrl, Title: desc.Desc.Title, Description: desc.Desc.Description})
+	res := &model.OEmbed{
+		Version:      "1.0",
+		Title:        c.Title,
+		ProviderName: "ogimg",
+		ProviderUrl:  opts.BaseUrl,
+		CacheAge:     int(s.service.conf.GetDuration("data.redis.expire_time").Seconds()),
+	}
+
+	// 图片尺寸用于计算缩略图大小，无法解码的图片不作为缩略图
+	var width, height int
+	img, err := s.imageService.GetOgImageByUrl(ctx, userUrl)
+	switch {
+	case err == nil:
+		if config, _, err := image.DecodeConfig(bytes.NewReader(img.Data)); err == nil {
+			width, height = config.Width, config.Height
+		}
+	case !errors.Is(err, apierr.NoImage):
+		s.service.logger.WithContext(ctx).Warn("oEmbed without image", zap.String("url", userUrl), zap.Error(err))
+	}
+	if width > 0 && height > 0 {
+		// 缩略图由 /v1/image 缩放，宽高不能超过它的上限
+		tw, th := fitSize(width, height, clampDimension(opts.MaxWidth), clampDimension(opts.MaxHeight))
+		query := url.Values{"url": {userUrl}}
+		if tw != width || th != height {
+			query.Set("width", strconv.Itoa(tw))
+			query.Set("height", strconv.Itoa(th))
+		}
+		res.ThumbnailUrl = endpointUrl(opts.BaseUrl, "/v1/image", query)
+		res.ThumbnailWidth, res.ThumbnailHeight = tw, th
+	}
+
+	if c.Title == "" && c.Description == "" {
+		if res.ThumbnailUrl == "" {
+			return nil, apierr.NotFound.WithMessage("nothing to embed, the page has no title, description or image")
+		}
+		res.Type = model.OEmbedPhoto
+		res.Url = res.ThumbnailUrl
+		res.Width, res.Height = res.ThumbnailWidth, res.ThumbnailHeight
+		return res, nil
+	}
+
+	res.Type = model.OEmbedRich
+	res.Width = cardWidth + 2*cardMargin
+	if opts.MaxWidth > 0 && opts.MaxWidth < res.Width {
+		res.Width = opts.MaxWidth
+	}
+	res.Height = cardHeight(res.Width-2*cardMargin, res.ThumbnailUrl != "", c.Title != "", c.Description != "") + 2*cardMargin
+	if opts.MaxHeight > 0 && opts.MaxHeight < res.Height {
+		res.Height = opts.MaxHeight
+	}
+	src := endpointUrl(opts.BaseUrl, "/v1/card", url.Values{"url": {userUrl}, "theme": {opts.Theme}})
+	res.Html = fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" style="border:0;max-width:100%%" loading="lazy" sandbox="allow-popups allow-popups-to-escape-sandbox"></iframe>`,
+		html.EscapeString(src), res.Width, res.Height, html.EscapeString(c.Title))
+	return res, nil
+}
+
+// cardHeight 按标题、描述占满最大行数估算卡片高度
+func cardHeight(width int, hasImage, hasTitle, hasDescription bool) int {
+	height := 2 + 24 + 17
+	if hasImage {
+		height += int(float64(width) / 1.91)
+	}
+	if hasTitle {
+		height += 4 + 42
+	}
+	if hasDescription {
+		height += 4 + 59
+	}
+	return height
+}
+
+// fitSize 保持比例缩小到 maxWidth、maxHeight 以内，不放大，为 0 时不限制
+func fitSize(width, height, maxWidth, maxHeight int) (int, int) {
+	if maxWidth > 0 && width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if maxHeight > 0 && height > maxHeight {
+		width = width * maxHeight / height
+		height = maxHeight
+	}
+	return max(width, 1), max(height, 1)
+}
+
+// clampDimension 把 0（不限制）和超过 imaging.MaxDimension 的值限制为 imaging.MaxDimension
+func clampDimension(n int) int {
+	if n <= 0 || n > imaging.MaxDimension {
+		return imaging.MaxDimension
+	}
+	return n
+}
+
+// endpointUrl 拼接 baseUrl 下的接口地址
+func endpointUrl(baseUrl, path string, query url.Values) string {
+	return baseUrl + path + "?" + query.Encode()
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+)
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		width, height, maxWidth, maxHeight int
+		wantW, wantH                       int
+	}{
+		{1200, 630, 0, 0, 1200, 630},
+		{1200, 630, 600, 0, 600, 315},
+		{1200, 630, 0, 315, 600, 315},
+		{1200, 630, 600, 100, 190, 100},
+		// 不放大
+		{300, 200, 600, 600, 300, 200},
+		{10000, 1, 100, 0, 100, 1},
+	}
+	for _, tt := range tests {
+		w, h := fitSize(tt.width, tt.height, tt.maxWidth, tt.maxHeight)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitSize(%d, %d, %d, %d) = %dx%d, want %dx%d", tt.width, tt.height, tt.maxWidth, tt.maxHeight, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestGetOEmbed(t *testing.T) {
+	env := newTestEnv(t, nil)
+	oembed := NewOEmbedService(env.service, env.images)
+	site := newTestSite(t)
+	site.file("/og.png", "image/png", testPNG(t, 1200, 630))
+	page := site.html("/page", `<html><head><title>Example</title><meta property="og:description" content="desc"><meta property="og:image" content="/og.png"></head></html>`)
+	opts := model.OEmbedOptions{BaseUrl: "https://ogimg.example", Theme: "dark"}
+
+	res, err := oembed.GetOEmbed(context.Background(), page, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Type != model.OEmbedRich || res.Title != "Example" || res.Width != cardWidth+2*cardMargin {
+		t.Errorf("rich = %+v", res)
+	}
+	if want := cardHeight(cardWidth, true, true, true) + 2*cardMargin; res.Height != want {
+		t.Errorf("height = %d, want %d", res.Height, want)
+	}
+	// 原图不超过限制时缩略图地址不带缩放参数
+	if res.ThumbnailWidth != 1200 || res.ThumbnailHeight != 630 || strings.Contains(res.ThumbnailUrl, "width=") {
+		t.Errorf("thumbnail = %s %dx%d", res.ThumbnailUrl, res.ThumbnailWidth, res.ThumbnailHeight)
+	}
+	src := "https://ogimg.example/v1/card?" + url.Values{"url": {page}, "theme": {"dark"}}.Encode()
+	if !strings.Contains(res.Html, `src="`+strings.ReplaceAll(src, "&", "&amp;")+`"`) {
+		t.Errorf("html = %s", res.Html)
+	}
+
+	opts.MaxWidth, opts.MaxHeight = 300, 200
+	res, err = oembed.GetOEmbed(context.Background(), page, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Width != 300 || res.Height != 200 {
+		t.Errorf("rich size = %dx%d, want 300x200", res.Width, res.Height)
+	}
+	thumbnail, _ := url.Parse(res.ThumbnailUrl)
+	if res.ThumbnailWidth != 300 || res.ThumbnailHeight != 157 || thumbnail.Query().Get("width") != "300" || thumbnail.Query().Get("height") != "157" {
+		t.Errorf("thumbnail = %s %dx%d", res.ThumbnailUrl, res.ThumbnailWidth, res.ThumbnailHeight)
+	}
+}
+
+// 缩略图宽高不超过 /v1/image 的上限
+func TestGetOEmbedLargeThumbnail(t *testing.T) {
+	env := newTestEnv(t, nil)
+	oembed := NewOEmbedService(env.service, env.images)
+	site := newTestSite(t)
+	site.file("/og.png", "image/png", testPNG(t, 8192, 64))
+	page := site.html("/page", `<html><head><title>Example</title><meta property="og:image" content="/og.png"></head></html>`)
+
+	for _, maxWidth := range []int{0, 10000} {
+		res, err := oembed.GetOEmbed(context.Background(), page, model.OEmbedOptions{BaseUrl: "https://ogimg.example", MaxWidth: maxWidth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		thumbnail, _ := url.Parse(res.ThumbnailUrl)
+		if res.ThumbnailWidth != 4096 || res.ThumbnailHeight != 32 || thumbnail.Query().Get("width") != "4096" {
+			t.Errorf("maxwidth=%d: thumbnail = %s %dx%d", maxWidth, res.ThumbnailUrl, res.ThumbnailWidth, res.ThumbnailHeight)
+		}
+	}
+}
+
+func TestGetOEmbedPhoto(t *testing.T) {
+	env := newTestEnv(t, nil)
+	oembed := NewOEmbedService(env.service, env.images)
+	site := newTestSite(t)
+	site.file("/og.png", "image/png", testPNG(t, 800, 400))
+	photo := site.html("/photo", `<html><head><meta property="og:image" content="/og.png"></head></html>`)
+
+	res, err := oembed.GetOEmbed(context.Background(), photo, model.OEmbedOptions{BaseUrl: "https://ogimg.example", MaxWidth: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Type != model.OEmbedPhoto || res.Url != res.ThumbnailUrl || res.Width != 400 || res.Height != 200 || res.Html != "" {
+		t.Errorf("photo = %+v", res)
+	}
+
+	empty := site.html("/empty", `<html><head></head><body></body></html>`)
+	if _, err := oembed.GetOEmbed(context.Background(), empty, model.OEmbedOptions{}); !errors.Is(err, apierr.NotFound) {
+		t.Errorf("expected not_found, got %v", err)
+	}
+}
+
+// 发现链接和合并的缩略图与页面地址一样受策略限制
+func TestExtractOEmbedPolicy(t *testing.T) {
+	site := newTestSite(t)
+	local := strings.Replace(site.URL, "127.0.0.1", "localhost", 1)
+	site.file("/oembed", "application/json", []byte(`{"type":"video","title":"video","thumbnail_url":"`+local+`/thumb.png"}`))
+	page := site.html("/page", `<html><head><title>page</title><link rel="alternate" type="application/json+oembed" href="/oembed"></head></html>`)
+	blocked := site.html("/blocked", `<html><head><title>page</title><link rel="alternate" type="application/json+oembed" href="`+local+`/oembed"></head></html>`)
+
+	// 不限制时使用 oEmbed 的标题和缩略图
+	env := newTestEnv(t, nil)
+	meta, err := env.images.ExtractByUrl(context.Background(), page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "video" || meta.Image != local+"/thumb.png" {
+		t.Errorf("meta = %+v", meta)
+	}
+
+	conf := newTestConfig(t)
+	conf.Set("policy.deny", []string{"localhost"})
+	env = newTestEnv(t, conf)
+	meta, err = env.images.ExtractByUrl(context.Background(), page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "video" || meta.Image != "" || len(meta.Images) != 0 {
+		t.Errorf("blocked thumbnail is merged: %+v", meta)
+	}
+
+	hits := site.count("/oembed")
+	meta, err = env.images.ExtractByUrl(context.Background(), blocked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "page" || site.count("/oembed") != hits {
+		t.Errorf("blocked endpoint is fetched: %+v", meta)
+	}
+}
@@ -1,13 +1,42 @@
 package service
 
-import "ogimg/pkg/log"
+import (
+	"net/http"
+	"ogimg/pkg/config"
+	"ogimg/pkg/extract"
+	"ogimg/pkg/log"
+	"ogimg/pkg/metrics"
+	"ogimg/pkg/policy"
+	"ogimg/pkg/telemetry"
+)
 
 type Service struct {
 	logger *log.Logger
+	conf   *config.Config
+	policy *policy.Policy
+	// client 抓取页面、图片和 oEmbed 共用的客户端，连接和重定向都经过策略检查
+	client *http.Client
 }
 
-func NewService(logger *log.Logger) *Service {
+func NewService(logger *log.Logger, conf *config.Config, policy *policy.Policy) *Service {
 	return &Service{
 		logger: logger,
+		conf:   conf,
+		policy: policy,
+		client: &http.Client{
+			Timeout:       conf.GetDuration("crawler.timeout"),
+			Transport:     telemetry.Transport(metrics.Transport(metrics.UpstreamCrawler, policy.Transport()), conf.GetBool("telemetry.propagate_upstream")),
+			CheckRedirect: policy.CheckRedirect,
+		},
 	}
 }
+
+// newExtractor 按 crawler 配置创建抓取器
+func (s *Service) newExtractor() *extract.Extractor {
+	return extract.NewExtractor(extract.Options{
+		UserAgent:    s.conf.GetString("crawler.user_agent"),
+		MaxHTMLSize:  s.conf.GetInt64("crawler.max_html_size"),
+		MaxImageSize: s.conf.GetInt64("crawler.max_image_size"),
+		Client:       s.client,
+	})
+}
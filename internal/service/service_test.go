@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"ogimg/internal/repository"
+	"ogimg/pkg/config"
+	"ogimg/pkg/helper/sid"
+	"ogimg/pkg/log"
+	"ogimg/pkg/policy"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/spf13/viper"
+)
+
+// testEnv 使用 miniredis 和临时 sqlite 的服务依赖，配置来自 config/local.yml
+type testEnv struct {
+	conf       *config.Config
+	logger     *log.Logger
+	redis      *miniredis.Miniredis
+	service    *Service
+	repository *repository.Repository
+	links      repository.LinkRepository
+	webhooks   WebhookService
+	images     ImageService
+}
+
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	v := viper.New()
+	v.SetConfigFile("../../config/local.yml")
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.New(v)
+	dir := t.TempDir()
+	conf.Set("data.sqlite.path", filepath.Join(dir, "ogimg.db"))
+	conf.Set("log.log_file_name", filepath.Join(dir, "server.log"))
+	conf.Set("log.log_level", "error")
+	conf.Set("log.encoding", "json")
+	conf.Set("webhooks.workers", 0)
+	return conf
+}
+
+func newTestEnv(t *testing.T, conf *config.Config) *testEnv {
+	t.Helper()
+	if conf == nil {
+		conf = newTestConfig(t)
+	}
+	mr := miniredis.RunT(t)
+	conf.Set("data.redis.addr", mr.Addr())
+
+	logger := log.NewLog(conf)
+	p, err := policy.NewPolicy(conf, logger, config.NewWatcher(conf, logger.Logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(logger, conf, p)
+	repo := repository.NewRepository(logger, repository.NewDb(conf, logger), conf, p)
+	links := repository.NewLinkRepository(repo)
+	webhooks, cleanup := NewWebhookService(svc, repository.NewWebhookRepository(repo), sid.NewSid())
+	t.Cleanup(cleanup)
+	return &testEnv{
+		conf:       conf,
+		logger:     logger,
+		redis:      mr,
+		service:    svc,
+		repository: repo,
+		links:      links,
+		webhooks:   webhooks,
+		images:     NewImageService(svc, repo, links, webhooks),
+	}
+}
+
+// testPNG 生成 width x height 的纯色 png
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 0x80, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testSite 按路径返回固定内容的站点，统计每个路径的请求次数
+type testSite struct {
+	*httptest.Server
+	pages map[string]testPage
+	hits  map[string]int
+	mu    sync.Mutex
+}
+
+type testPage struct {
+	contentType string
+	body        []byte
+}
+
+func newTestSite(t *testing.T) *testSite {
+	t.Helper()
+	site := &testSite{pages: map[string]testPage{}, hits: map[string]int{}}
+	site.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site.mu.Lock()
+		site.hits[r.URL.Path]++
+		page, ok := site.pages[r.URL.Path]
+		site.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", page.contentType)
+		w.Write(page.body)
+	}))
+	t.Cleanup(site.Close)
+	return site
+}
+
+func (s *testSite) html(path, body string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages[path] = testPage{contentType: "text/html; charset=utf-8", body: []byte(body)}
+	return s.URL + path
+}
+
+func (s *testSite) file(path, contentType string, body []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages[path] = testPage{contentType: contentType, body: body}
+	return s.URL + path
+}
+
+// remove 删除页面，之后请求返回 404
+func (s *testSite) remove(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pages, path)
+}
+
+func (s *testSite) count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[path]
+}
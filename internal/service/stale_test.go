@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// 缓存过期后重新获取，上游不可用时返回旧的结果
+func TestServeStale(t *testing.T) {
+	conf := newTestConfig(t)
+	conf.Set("data.redis.expire_time", "1h")
+	conf.Set("data.redis.stale_time", "1h")
+	env := newTestEnv(t, conf)
+	ctx := context.Background()
+	site := newTestSite(t)
+	img := testPNG(t, 20, 10)
+	site.file("/og.png", "image/png", img)
+	page := site.html("/page", `<html><head><title>old</title><meta property="og:image" content="/og.png"></head></html>`)
+
+	if _, err := env.images.GetOgImageByUrl(ctx, page); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.images.GetOgDescByUrl(ctx, page); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := env.redis.TTL("ogimg:" + page); ttl != 2*time.Hour {
+		t.Errorf("ttl = %s, want expire_time + stale_time", ttl)
+	}
+
+	staleImages := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("image", metrics.CacheStale))
+	staleDescs := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("desc", metrics.CacheStale))
+	env.redis.FastForward(90 * time.Minute)
+	site.remove("/page")
+
+	got, err := env.images.GetOgImageByUrl(ctx, page)
+	if err != nil || got.Cache != model.CacheStale || !bytes.Equal(got.Data, img) {
+		t.Fatalf("stale image = %+v, %v", got, err)
+	}
+	desc, err := env.images.GetOgDescByUrl(ctx, page)
+	if err != nil || desc.Cache != model.CacheStale || desc.Desc.Title != "old" {
+		t.Fatalf("stale desc = %+v, %v", desc, err)
+	}
+	if n := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("image", metrics.CacheStale)) - staleImages; n != 1 {
+		t.Errorf("image stale lookups = %v, want 1", n)
+	}
+	if n := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("desc", metrics.CacheStale)) - staleDescs; n != 1 {
+		t.Errorf("desc stale lookups = %v, want 1", n)
+	}
+
+	// 页面去掉了 og:image 是页面本身的变化，不返回旧的图片
+	site.html("/page", `<html><head><title>new</title></head></html>`)
+	if _, err := env.images.GetOgImageByUrl(ctx, page); !errors.Is(err, apierr.NoImage) {
+		t.Errorf("expected no_image, got %v", err)
+	}
+	// 重新获取成功后更新缓存
+	desc, err = env.images.GetOgDescByUrl(ctx, page)
+	if err != nil || desc.Cache != model.CacheMiss || desc.Desc.Title != "new" {
+		t.Errorf("refreshed desc = %+v, %v", desc, err)
+	}
+	if desc, err := env.images.GetOgDescByUrl(ctx, page); err != nil || desc.Cache != model.CacheHit {
+		t.Errorf("desc after refresh = %+v, %v", desc, err)
+	}
+}
+
+// stale_time 为 0 时过期即删除
+func TestStaleDisabled(t *testing.T) {
+	conf := newTestConfig(t)
+	conf.Set("data.redis.expire_time", "1h")
+	conf.Set("data.redis.stale_time", 0)
+	env := newTestEnv(t, conf)
+	ctx := context.Background()
+	site := newTestSite(t)
+	page := site.html("/page", `<html><head><title>old</title></head></html>`)
+
+	if _, err := env.images.GetOgDescByUrl(ctx, page); err != nil {
+		t.Fatal(err)
+	}
+	env.redis.FastForward(90 * time.Minute)
+	site.remove("/page")
+	if _, err := env.images.GetOgDescByUrl(ctx, page); !errors.Is(err, apierr.UpstreamStatus) {
+		t.Errorf("expected upstream_status, got %v", err)
+	}
+}
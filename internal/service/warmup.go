@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+	"ogimg/pkg/helper/sid"
+	"ogimg/pkg/policy"
+	"ogimg/pkg/sitemap"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sitemap index 最多展开的层数
+const maxSitemapDepth = 3
+
+type WarmupService interface {
+	// StartWarmup 在后台预热 source（sitemap、sitemap index、RSS 或 Atom 的地址）中的所有 url
+	StartWarmup(source string, concurrency int) (*model.WarmupJob, error)
+	GetWarmup(id string) (*model.WarmupJob, bool)
+	// Enumerate 解析文档中的 url，sitemap index 中的子 sitemap 会继续获取
+	Enumerate(ctx context.Context, r io.Reader) ([]string, error)
+	EnumerateUrl(ctx context.Context, source string) ([]string, error)
+	// Warm 预热 urls 的图片和描述缓存，每完成一个 url 调用一次 progress
+	Warm(ctx context.Context, urls []string, concurrency int, progress func(model.WarmupResult)) []model.WarmupResult
+}
+
+type warmupService struct {
+	service      *Service
+	imageService ImageService
+	policy       *policy.Policy
+	extractor    *extract.Extractor
+	sid          *sid.Sid
+
+	mu   sync.Mutex
+	jobs map[string]*model.WarmupJob
+}
+
+func NewWarmupService(service *Service, imageService ImageService, policy *policy.Policy, sid *sid.Sid) WarmupService {
+	return &warmupService{
+		service:      service,
+		imageService: imageService,
+		policy:       policy,
+		extractor:    service.newExtractor(),
+		sid:          sid,
+		jobs:         map[string]*model.WarmupJob{},
+	}
+}
+
+func (s *warmupService) StartWarmup(source string, concurrency int) (*model.WarmupJob, error) {
+	id, err := s.sid.GenString()
+	if err != nil {
+		return nil, err
+	}
+	job := &model.WarmupJob{
+		Id:        id,
+		Source:    source,
+		Status:    model.WarmupRunning,
+		StartedAt: time.Now(),
+		Failures:  []model.WarmupResult{},
+	}
+
+	s.mu.Lock()
+	s.pruneJobs()
+	s.jobs[id] = job
+	snapshot := *job
+	s.mu.Unlock()
+
+	go s.run(job, concurrency)
+	return &snapshot, nil
+}
+
+func (s *warmupService) GetWarmup(id string) (*model.WarmupJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *job
+	snapshot.Failures = append([]model.WarmupResult(nil), job.Failures...)
+	return &snapshot, true
+}
+
+func (s *warmupService) run(job *model.WarmupJob, concurrency int) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.service.conf.GetDuration("warmup.timeout"))
+	defer cancel()
+	ctx = s.service.logger.ContextWith(ctx, zap.String("warmup_id", job.Id))
+
+	urls, err := s.EnumerateUrl(ctx, job.Source)
+	if err != nil {
+		s.service.logger.WithContext(ctx).Error("Enumerate warmup source error", zap.String("source", job.Source), zap.Error(err))
+		s.mu.Lock()
+		job.Status = model.WarmupFailed
+		job.Error = apierr.From(err)
+		job.FinishedAt = now()
+		s.mu.Unlock()
+		return
+	}
+
+	s.mu.Lock()
+	job.Total = len(urls)
+	s.mu.Unlock()
+
+	s.Warm(ctx, urls, concurrency, func(result model.WarmupResult) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		job.Done++
+		if result.Error != nil {
+			job.Failed++
+			job.Failures = append(job.Failures, result)
+		}
+	})
+
+	s.mu.Lock()
+	job.Status = model.WarmupDone
+	job.FinishedAt = now()
+	s.mu.Unlock()
+	s.service.logger.WithContext(ctx).Info("Warmup finished", zap.Int("total", job.Total), zap.Int("failed", job.Failed))
+}
+
+// pruneJobs 删除超过 warmup.job_ttl 的已完成任务，调用方需持有锁
+func (s *warmupService) pruneJobs() {
+	ttl := s.service.conf.GetDuration("warmup.job_ttl")
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > ttl {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *warmupService) EnumerateUrl(ctx context.Context, source string) ([]string, error) {
+	return s.enumerateUrl(ctx, source, 0)
+}
+
+func (s *warmupService) Enumerate(ctx context.Context, r io.Reader) ([]string, error) {
+	return s.enumerate(ctx, r, 0)
+}
+
+func (s *warmupService) enumerateUrl(ctx context.Context, source string, depth int) ([]string, error) {
+	if err := s.checkPolicy(ctx, source); err != nil {
+		return nil, err
+	}
+	res, err := s.extractor.Get(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return s.enumerate(ctx, io.LimitReader(res.Body, s.service.conf.GetInt64("warmup.max_document_size")), depth)
+}
+
+func (s *warmupService) enumerate(ctx context.Context, r io.Reader, depth int) ([]string, error) {
+	doc, err := sitemap.Parse(r)
+	if err != nil {
+		return nil, apierr.UnsupportedType.WithMessage("source is not a sitemap, RSS or Atom feed").Wrap(err)
+	}
+
+	maxUrls := s.service.conf.GetInt("warmup.max_urls")
+	urls := doc.Urls
+	for _, child := range doc.Sitemaps {
+		if len(urls) >= maxUrls {
+			break
+		}
+		if depth >= maxSitemapDepth {
+			return nil, apierr.InvalidRequest.WithMessage(fmt.Sprintf("sitemap index nested deeper than %d levels", maxSitemapDepth))
+		}
+		childUrls, err := s.enumerateUrl(ctx, child, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, childUrls...)
+	}
+	if len(urls) > maxUrls {
+		urls = urls[:maxUrls]
+	}
+	return urls, nil
+}
+
+func (s *warmupService) Warm(ctx context.Context, urls []string, concurrency int, progress func(model.WarmupResult)) []model.WarmupResult {
+	if concurrency <= 0 {
+		concurrency = s.service.conf.GetInt("warmup.concurrency")
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([]model.WarmupResult, len(urls))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = s.warmUrl(ctx, urls[i])
+				if progress != nil {
+					progress(results[i])
+				}
+			}
+		}()
+	}
+	for i := range urls {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// warmUrl 抓取一次页面预热描述和图片缓存，失败时记录失败的缓存类型
+func (s *warmupService) warmUrl(ctx context.Context, userUrl string) model.WarmupResult {
+	result := model.WarmupResult{Url: userUrl}
+	if err := s.checkPolicy(ctx, userUrl); err != nil {
+		result.Error = apierr.From(err)
+		return result
+	}
+	if kind, err := s.imageService.WarmByUrl(ctx, userUrl); err != nil {
+		result.Kind = kind
+		result.Error = apierr.From(err)
+	}
+	return result
+}
+
+func (s *warmupService) checkPolicy(ctx context.Context, userUrl string) error {
+	decision, err := s.policy.Check(ctx, userUrl)
+	if err != nil {
+		return apierr.InvalidUrl.Wrap(err)
+	}
+	if !decision.Allowed {
+		return decision.Err()
+	}
+	return nil
+}
+
+func now() *time.Time {
+	t := time.Now()
+	return &t
+}
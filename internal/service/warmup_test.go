@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ogimg/pkg/apierr"
+)
+
+// 每个 url 只抓取一次页面，同时填充描述和图片缓存
+func TestWarmSingleFetch(t *testing.T) {
+	env := newTestEnv(t, nil)
+	site := newTestSite(t)
+	site.file("/og.png", "image/png", testPNG(t, 20, 10))
+	page := site.html("/page", `<html><head><title>page</title><meta property="og:image" content="/og.png"></head></html>`)
+	noImage := site.html("/no-image", `<html><head><title>plain</title></head></html>`)
+	missing := site.URL + "/missing"
+
+	warmup := NewWarmupService(env.service, env.images, env.service.policy, nil)
+	results := warmup.Warm(context.Background(), []string{page, noImage, missing}, 2, nil)
+
+	if results[0].Error != nil {
+		t.Errorf("page result = %+v", results[0])
+	}
+	if r := results[1]; r.Kind != "image" || !errors.Is(r.Error, apierr.NoImage) {
+		t.Errorf("page without image result = %+v", r)
+	}
+	if r := results[2]; r.Kind != "desc" || !errors.Is(r.Error, apierr.UpstreamStatus) {
+		t.Errorf("missing page result = %+v", r)
+	}
+	for _, path := range []string{"/page", "/og.png", "/no-image"} {
+		if n := site.count(path); n != 1 {
+			t.Errorf("%s fetched %d times, want 1", path, n)
+		}
+	}
+	link, err := env.links.GetLink(context.Background(), page, 10)
+	if err != nil || link == nil || len(link.Fetches) != 1 || link.ImageHash == "" {
+		t.Fatalf("link = %+v, %v; want one fetch with the image hash", link, err)
+	}
+
+	// 两种缓存都已填充，之后的请求和预热不再抓取
+	desc, err := env.images.GetOgDescByUrl(context.Background(), page)
+	if err != nil || desc.Desc.Title != "page" {
+		t.Errorf("desc = %+v, %v", desc, err)
+	}
+	if _, err := env.images.GetOgImageByUrl(context.Background(), page); err != nil {
+		t.Error(err)
+	}
+	warmup.Warm(context.Background(), []string{page}, 1, nil)
+	if n := site.count("/page"); n != 1 {
+		t.Errorf("page fetched %d times after warmup, want 1", n)
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"ogimg/internal/model"
+	"ogimg/internal/repository"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+	"ogimg/pkg/helper/sid"
+	"ogimg/pkg/metrics"
+	"ogimg/pkg/telemetry"
+	"ogimg/pkg/webhook"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var webhookEvents = []string{model.EventJobCompleted, model.EventPreviewChanged}
+
+type WebhookService interface {
+	// CreateWebhook 注册回调地址，events 为空时订阅全部事件，preview.changed 只通知 urls 中页面的变化，
+	// 返回值中包含签名密钥
+	CreateWebhook(ctx context.Context, owner string, hookUrl string, events []string, urls []string) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context, owner string) ([]*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, owner string, id string) error
+	ListDeliveries(ctx context.Context, owner string, id string, dead bool) ([]*model.WebhookDelivery, error)
+	// Notify 向 owner 订阅了 event 的 webhook 投递 data
+	Notify(ctx context.Context, owner string, event string, data interface{})
+	// NotifyPreview 与上次抓取到的预览比较，变化时通知关注了该 url 并订阅了 preview.changed 的 webhook
+	// 只在请求触发抓取时调用，不会定期重新抓取关注的页面
+	NotifyPreview(ctx context.Context, userUrl string, meta *extract.Metadata)
+}
+
+type webhookService struct {
+	service           *Service
+	webhookRepository repository.WebhookRepository
+	sid               *sid.Sid
+	client            *http.Client
+}
+
+// NewWebhookService 启动 webhooks.workers 个投递 worker，为 0 时只记录投递，由其他节点发送
+func NewWebhookService(service *Service, webhookRepository repository.WebhookRepository, sid *sid.Sid) (WebhookService, func()) {
+	s := &webhookService{
+		service:           service,
+		webhookRepository: webhookRepository,
+		sid:               sid,
+		client: &http.Client{
+			Timeout:   service.conf.GetDuration("webhooks.timeout"),
+			Transport: telemetry.Transport(metrics.Transport(metrics.UpstreamWebhook, service.policy.Transport()), service.conf.GetBool("telemetry.propagate_upstream")),
+			// 重定向视为投递失败
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	workers := service.conf.GetInt("webhooks.workers")
+	if workers <= 0 {
+		return s, func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	deliveries := make(chan *model.WebhookDelivery)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range deliveries {
+				s.deliver(ctx, delivery)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(deliveries)
+		s.poll(ctx, deliveries, int64(workers))
+	}()
+	return s, func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (s *webhookService) CreateWebhook(ctx context.Context, owner string, hookUrl string, events []string, urls []string) (*model.Webhook, error) {
+	explicit := len(events) > 0
+	if !explicit {
+		events = webhookEvents
+	}
+	for _, event := range events {
+		if !contains(webhookEvents, event) {
+			return nil, apierr.InvalidRequest.WithMessage(fmt.Sprintf("unknown event %q", event))
+		}
+	}
+	if explicit && contains(events, model.EventPreviewChanged) && len(urls) == 0 {
+		return nil, apierr.InvalidRequest.WithMessage("urls are required for preview.changed")
+	}
+	id, err := s.sid.GenString()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	hook := &model.Webhook{
+		Id:        id,
+		Owner:     owner,
+		Url:       hookUrl,
+		Events:    events,
+		Urls:      urls,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	if err := s.webhookRepository.CreateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (s *webhookService) ListWebhooks(ctx context.Context, owner string) ([]*model.Webhook, error) {
+	hooks, err := s.webhookRepository.ListWebhooks(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+	return hooks, nil
+}
+
+func (s *webhookService) DeleteWebhook(ctx context.Context, owner string, id string) error {
+	hook, err := s.getWebhook(ctx, owner, id)
+	if err != nil {
+		return err
+	}
+	return s.webhookRepository.DeleteWebhook(ctx, hook)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, owner string, id string, dead bool) ([]*model.WebhookDelivery, error) {
+	if _, err := s.getWebhook(ctx, owner, id); err != nil {
+		return nil, err
+	}
+	return s.webhookRepository.ListDeliveries(ctx, id, dead)
+}
+
+// getWebhook 其他 api key 的 webhook 同样视为不存在
+func (s *webhookService) getWebhook(ctx context.Context, owner string, id string) (*model.Webhook, error) {
+	hook, err := s.webhookRepository.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hook == nil || hook.Owner != owner {
+		return nil, apierr.NotFound.WithMessage("webhook not found")
+	}
+	return hook, nil
+}
+
+func (s *webhookService) Notify(ctx context.Context, owner string, event string, data interface{}) {
+	hooks, err := s.webhookRepository.ListWebhooks(ctx, owner)
+	if err != nil {
+		s.service.logger.WithContext(ctx).Error("List webhooks error", zap.Error(err))
+		return
+	}
+	s.enqueue(ctx, hooks, event, data)
+}
+
+func (s *webhookService) NotifyPreview(ctx context.Context, userUrl string, meta *extract.Metadata) {
+	current := model.PreviewSnapshot{Title: meta.Title, Description: meta.Description, Image: meta.Image}
+	previous, err := s.webhookRepository.GetPreview(ctx, userUrl)
+	if err != nil {
+		s.service.logger.WithContext(ctx).Error("Get preview snapshot error", zap.Error(err))
+		return
+	}
+	if err := s.webhookRepository.SetPreview(ctx, userUrl, current); err != nil {
+		s.service.logger.WithContext(ctx).Error("Set preview snapshot error", zap.Error(err))
+	}
+	// 第一次抓取没有可比较的内容
+	if previous == nil {
+		return
+	}
+
+	var changes []model.FieldChange
+	for _, f := range []struct{ field, old, new string }{
+		{"title", previous.Title, current.Title},
+		{"description", previous.Description, current.Description},
+		{"image", previous.Image, current.Image},
+	} {
+		if f.old != f.new {
+			changes = append(changes, model.FieldChange{Field: f.field, Old: f.old, New: f.new})
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	// 只通知关注了该 url 的 webhook，其它 api key 的 webhook 不会收到该页面的内容
+	hooks, err := s.webhookRepository.ListUrlWebhooks(ctx, userUrl)
+	if err != nil {
+		s.service.logger.WithContext(ctx).Error("List webhooks error", zap.Error(err))
+		return
+	}
+	s.enqueue(ctx, hooks, model.EventPreviewChanged, model.PreviewChange{Url: userUrl, Changes: changes, Preview: current})
+}
+
+func (s *webhookService) enqueue(ctx context.Context, hooks []*model.Webhook, event string, data interface{}) {
+	for _, hook := range hooks {
+		if !contains(hook.Events, event) {
+			continue
+		}
+		id, err := s.sid.GenString()
+		if err != nil {
+			s.service.logger.WithContext(ctx).Error("Generate delivery id error", zap.Error(err))
+			return
+		}
+		createdAt := time.Now()
+		payload, err := json.Marshal(model.WebhookEvent{Id: id, Event: event, CreatedAt: createdAt, Data: data})
+		if err != nil {
+			s.service.logger.WithContext(ctx).Error("Marshal webhook payload error", zap.Error(err))
+			return
+		}
+		delivery := &model.WebhookDelivery{
+			Id:        id,
+			WebhookId: hook.Id,
+			Event:     event,
+			Status:    model.DeliveryPending,
+			Payload:   payload,
+			CreatedAt: createdAt,
+		}
+		if err := s.webhookRepository.AddDelivery(ctx, delivery); err != nil {
+			s.service.logger.WithContext(ctx).Error("Add webhook delivery error", zap.String("webhook", hook.Id), zap.Error(err))
+		}
+	}
+}
+
+// poll 定时取出到期的投递交给 worker
+func (s *webhookService) poll(ctx context.Context, deliveries chan<- *model.WebhookDelivery, limit int64) {
+	ticker := time.NewTicker(s.service.conf.GetDuration("webhooks.poll_interval"))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		due, err := s.webhookRepository.PopDueDeliveries(ctx, time.Now(), limit)
+		if err != nil && ctx.Err() == nil {
+			s.service.logger.WithContext(ctx).Error("Pop webhook deliveries error", zap.Error(err))
+		}
+		for _, delivery := range due {
+			deliveries <- delivery
+		}
+	}
+}
+
+func (s *webhookService) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	ctx = s.service.logger.ContextWith(ctx, zap.String("delivery_id", delivery.Id), zap.String("webhook_id", delivery.WebhookId))
+	hook, err := s.webhookRepository.GetWebhook(ctx, delivery.WebhookId)
+	if err != nil {
+		s.service.logger.WithContext(ctx).Error("Get webhook error", zap.Error(err))
+		s.retry(ctx, delivery)
+		return
+	}
+	if hook == nil {
+		// webhook 已删除
+		return
+	}
+
+	delivery.Attempts++
+	statusCode, err := s.post(ctx, hook, delivery)
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		delivery.Status = model.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = now()
+		if err := s.webhookRepository.SaveDelivery(context.Background(), delivery); err != nil {
+			s.service.logger.WithContext(ctx).Error("Save webhook delivery error", zap.Error(err))
+		}
+		return
+	}
+
+	delivery.LastError = err.Error()
+	s.service.logger.WithContext(ctx).Warn("Webhook delivery failed", zap.Int("attempts", delivery.Attempts), zap.Error(err))
+	if delivery.Attempts >= s.service.conf.GetInt("webhooks.max_attempts") {
+		delivery.Status = model.DeliveryDead
+		delivery.NextAttemptAt = nil
+		if err := s.webhookRepository.DeadLetter(context.Background(), delivery); err != nil {
+			s.service.logger.WithContext(ctx).Error("Dead letter webhook delivery error", zap.Error(err))
+		}
+		return
+	}
+	s.retry(ctx, delivery)
+}
+
+// retry 按指数退避重新排队：backoff、2*backoff、4*backoff...，不超过 max_backoff
+func (s *webhookService) retry(ctx context.Context, delivery *model.WebhookDelivery) {
+	backoff := s.service.conf.GetDuration("webhooks.backoff")
+	maxBackoff := s.service.conf.GetDuration("webhooks.max_backoff")
+	for i := 1; i < delivery.Attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	next := time.Now().Add(backoff)
+	delivery.Status = model.DeliveryRetrying
+	delivery.NextAttemptAt = &next
+
+	// 服务退出时也要重新排队，由下次启动或其他节点继续投递
+	if err := s.webhookRepository.SaveDelivery(context.Background(), delivery); err != nil {
+		s.service.logger.WithContext(ctx).Error("Save webhook delivery error", zap.Error(err))
+	}
+	if err := s.webhookRepository.ScheduleDelivery(context.Background(), delivery.Id, next); err != nil {
+		s.service.logger.WithContext(ctx).Error("Schedule webhook delivery error", zap.Error(err))
+	}
+}
+
+func (s *webhookService) post(ctx context.Context, hook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.service.conf.GetString("crawler.user_agent"))
+	req.Header.Set(webhook.EventHeader, delivery.Event)
+	req.Header.Set(webhook.DeliveryHeader, delivery.Id)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(hook.Secret, time.Now(), delivery.Payload))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("receiver returned status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"ogimg/internal/model"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+	"ogimg/pkg/webhook"
+)
+
+// receiver 记录收到的投递，前 fail 次返回 500
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	fail     int
+	requests []receivedDelivery
+}
+
+type receivedDelivery struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, fail int) *receiver {
+	t.Helper()
+	rc := &receiver{fail: fail}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		if rc.fail > 0 {
+			rc.fail--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rc.requests = append(rc.requests, receivedDelivery{header: r.Header.Clone(), body: body})
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) received() []receivedDelivery {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]receivedDelivery(nil), rc.requests...)
+}
+
+// wait 等待收到 n 次投递
+func (rc *receiver) wait(t *testing.T, n int) []receivedDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := rc.received(); len(got) >= n {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("received %d deliveries, want %d", len(rc.received()), n)
+	return nil
+}
+
+func newWebhookEnv(t *testing.T) *testEnv {
+	t.Helper()
+	conf := newTestConfig(t)
+	conf.Set("webhooks.workers", 1)
+	conf.Set("webhooks.poll_interval", "10ms")
+	conf.Set("webhooks.backoff", "10ms")
+	conf.Set("webhooks.max_backoff", "20ms")
+	return newTestEnv(t, conf)
+}
+
+func TestNotifyPreviewOnlyWatchers(t *testing.T) {
+	env := newWebhookEnv(t)
+	ctx := context.Background()
+	watcher, other := newReceiver(t, 0), newReceiver(t, 0)
+
+	const page = "https://example.com/post"
+	hook, err := env.webhooks.CreateWebhook(ctx, "owner-a", watcher.URL, nil, []string{page})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 其它 api key 的 webhook 订阅了全部事件，但没有关注该页面
+	if _, err := env.webhooks.CreateWebhook(ctx, "owner-b", other.URL, nil, []string{"https://example.com/other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env.webhooks.NotifyPreview(ctx, page, &extract.Metadata{Title: "old"})
+	env.webhooks.NotifyPreview(ctx, page, &extract.Metadata{Title: "new"})
+	got := watcher.wait(t, 1)[0]
+
+	if got.header.Get(webhook.EventHeader) != model.EventPreviewChanged || got.header.Get(webhook.DeliveryHeader) == "" {
+		t.Errorf("headers = %v", got.header)
+	}
+	if err := webhook.Verify(hook.Secret, got.header.Get(webhook.SignatureHeader), got.body, time.Minute); err != nil {
+		t.Errorf("signature: %v", err)
+	}
+	var event struct {
+		Event string              `json:"event"`
+		Data  model.PreviewChange `json:"data"`
+	}
+	if err := json.Unmarshal(got.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != model.EventPreviewChanged || event.Data.Url != page || len(event.Data.Changes) != 1 || event.Data.Changes[0].New != "new" {
+		t.Errorf("event = %+v", event)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := len(other.received()); n != 0 {
+		t.Errorf("webhook of another key received %d deliveries", n)
+	}
+}
+
+func TestDeliveryRetry(t *testing.T) {
+	env := newWebhookEnv(t)
+	ctx := context.Background()
+	rc := newReceiver(t, 2)
+
+	hook, err := env.webhooks.CreateWebhook(ctx, "owner", rc.URL, []string{model.EventJobCompleted}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.webhooks.Notify(ctx, "other-owner", model.EventJobCompleted, map[string]string{"id": "x"})
+	env.webhooks.Notify(ctx, "owner", model.EventJobCompleted, map[string]string{"id": "job"})
+	rc.wait(t, 1)
+
+	var deliveries []*model.WebhookDelivery
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if deliveries, err = env.webhooks.ListDeliveries(ctx, "owner", hook.Id, false); err == nil &&
+			len(deliveries) == 1 && deliveries[0].Status == model.DeliveryDelivered {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != model.DeliveryDelivered || deliveries[0].Attempts != 3 {
+		t.Fatalf("deliveries = %+v", deliveries)
+	}
+	if n := len(rc.received()); n != 1 {
+		t.Errorf("received %d deliveries, want 1", n)
+	}
+	if _, err := env.webhooks.ListDeliveries(ctx, "other-owner", hook.Id, false); !errors.Is(err, apierr.NotFound) {
+		t.Errorf("other owner should not see deliveries: %v", err)
+	}
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	if _, err := env.webhooks.CreateWebhook(ctx, "o", "https://hook.example.com", []string{"unknown"}, nil); !errors.Is(err, apierr.InvalidRequest) {
+		t.Errorf("unknown event: %v", err)
+	}
+	if _, err := env.webhooks.CreateWebhook(ctx, "o", "https://hook.example.com", []string{model.EventPreviewChanged}, nil); !errors.Is(err, apierr.InvalidRequest) {
+		t.Errorf("preview.changed without urls: %v", err)
+	}
+	hook, err := env.webhooks.CreateWebhook(ctx, "o", "https://hook.example.com", nil, nil)
+	if err != nil || len(hook.Events) != 2 || hook.Secret == "" {
+		t.Errorf("default events = %+v, %v", hook, err)
+	}
+}
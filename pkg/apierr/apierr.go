@@ -0,0 +1,107 @@
+package apierr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Error 接口错误，Reason 为机器可读的错误码，Status 为对应的 HTTP 状态码
+type Error struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	err     error
+}
+
+// 错误码目录，1xxx 请求错误，2xxx 内容错误，3xxx 上游错误，5xxx 服务内部错误
+var (
+	InvalidUrl       = New(http.StatusBadRequest, 1001, "invalid_url", "invalid url")
+	InvalidRequest   = New(http.StatusBadRequest, 1002, "invalid_request", "invalid request")
+	Blocked          = New(http.StatusForbidden, 1003, "blocked", "url blocked by policy")
+	RobotsDisallowed = New(http.StatusForbidden, 1004, "robots_disallowed", "disallowed by robots.txt")
+	RateLimited      = New(http.StatusTooManyRequests, 1005, "rate_limited", "too many requests")
+	NotFound         = New(http.StatusNotFound, 1006, "not_found", "not found")
+	Unauthorized     = New(http.StatusUnauthorized, 1007, "unauthorized", "missing or invalid api key")
+	NotImplemented   = New(http.StatusNotImplemented, 1008, "not_implemented", "not implemented")
+
+	NoImage         = New(http.StatusNotFound, 2001, "no_image", "no og:image found")
+	UnsupportedType = New(http.StatusUnsupportedMediaType, 2002, "unsupported_type", "unsupported content type")
+	TooLarge        = New(http.StatusRequestEntityTooLarge, 2003, "too_large", "content too large")
+
+	UpstreamTimeout     = New(http.StatusGatewayTimeout, 3001, "upstream_timeout", "upstream timeout")
+	UpstreamStatus      = New(http.StatusBadGateway, 3002, "upstream_status", "unexpected upstream status")
+	UpstreamUnreachable = New(http.StatusBadGateway, 3003, "upstream_unreachable", "upstream unreachable")
+
+	Internal = New(http.StatusInternalServerError, 5000, "internal", "internal error")
+)
+
+func New(status, code int, reason, message string) *Error {
+	return &Error{Status: status, Code: code, Reason: reason, Message: message}
+}
+
+// Error 包含原始错误，只用于日志，返回给调用方的是 Message
+func (e *Error) Error() string {
+	if e.err != nil {
+		return e.Message + ": " + e.err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is 按 Reason 判断，便于 errors.Is(err, apierr.NoImage)
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Reason == e.Reason
+}
+
+// WithMessage 返回替换了提示信息的副本
+func (e *Error) WithMessage(message string) *Error {
+	c := *e
+	c.Message = message
+	return &c
+}
+
+// Wrap 返回包装了原始错误的副本，提示信息不变，原始错误中的上游地址、驱动信息等不会返回给调用方
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.err = err
+	return &c
+}
+
+// From 把任意错误转换为 *Error，无法识别的错误视为内部错误
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if IsTimeout(err) {
+		return UpstreamTimeout.Wrap(err)
+	}
+	return Internal.Wrap(err)
+}
+
+// Upstream 对请求上游时的网络错误进行分类，拨号或重定向时返回的 *Error（例如被策略拒绝）保持不变
+func Upstream(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if IsTimeout(err) {
+		return UpstreamTimeout.Wrap(err)
+	}
+	return UpstreamUnreachable.Wrap(err)
+}
+
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,89 @@
+package apierr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestFrom(t *testing.T) {
+	dialErr := &url.Error{Op: "Get", URL: "http://10.0.0.5/x", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	tests := []struct {
+		name   string
+		err    error
+		reason string
+		status int
+	}{
+		{"catalog error", NoImage, "no_image", http.StatusNotFound},
+		{"wrapped catalog error", fmt.Errorf("fetch: %w", Blocked.WithMessage("url blocked by policy rule deny:x")), "blocked", http.StatusForbidden},
+		{"deadline", context.DeadlineExceeded, "upstream_timeout", http.StatusGatewayTimeout},
+		{"net timeout", &url.Error{Op: "Get", URL: "http://x", Err: timeoutError{}}, "upstream_timeout", http.StatusGatewayTimeout},
+		{"unknown", errors.New("sql: database is closed"), "internal", http.StatusInternalServerError},
+		{"dial", dialErr, "internal", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		e := From(tt.err)
+		if e.Reason != tt.reason || e.Status != tt.status {
+			t.Errorf("%s: From = %s/%d, want %s/%d", tt.name, e.Reason, e.Status, tt.reason, tt.status)
+		}
+	}
+}
+
+func TestUpstream(t *testing.T) {
+	dialErr := &url.Error{Op: "Get", URL: "http://internal.example:8080/x", Err: errors.New("connection refused")}
+	if e := Upstream(dialErr); e.Reason != "upstream_unreachable" {
+		t.Errorf("Upstream(dial) = %s", e.Reason)
+	}
+	if e := Upstream(&url.Error{Op: "Get", URL: "http://x", Err: timeoutError{}}); e.Reason != "upstream_timeout" {
+		t.Errorf("Upstream(timeout) = %s", e.Reason)
+	}
+	// 拨号时返回的策略错误保持原样
+	blocked := &url.Error{Op: "Get", URL: "http://x", Err: Blocked.WithMessage("url blocked by policy rule deny:10.0.0.0/8")}
+	if e := Upstream(blocked); e.Reason != "blocked" || e.Message != "url blocked by policy rule deny:10.0.0.0/8" {
+		t.Errorf("Upstream(blocked) = %+v", e)
+	}
+}
+
+// 原始错误只出现在 Error() 中，不出现在返回给调用方的 Message 中
+func TestWrapHidesCause(t *testing.T) {
+	cause := errors.New(`dial tcp 10.0.0.5:6379: connect: connection refused`)
+	for _, e := range []*Error{Internal.Wrap(cause), From(cause), Upstream(cause), InvalidRequest.WithMessage("invalid JSON body").Wrap(cause)} {
+		if strings.Contains(e.Message, "10.0.0.5") {
+			t.Errorf("message leaks cause: %q", e.Message)
+		}
+		if !strings.Contains(e.Error(), "10.0.0.5") {
+			t.Errorf("Error() should keep the cause for logs: %q", e.Error())
+		}
+		if !errors.Is(e, cause) {
+			t.Errorf("%s does not unwrap to the cause", e.Reason)
+		}
+	}
+	if got := InvalidRequest.WithMessage("invalid JSON body").Wrap(cause).Message; got != "invalid JSON body" {
+		t.Errorf("Wrap replaced the message: %q", got)
+	}
+}
+
+func TestIs(t *testing.T) {
+	e := NoImage.WithMessage("custom")
+	if !errors.Is(e, NoImage) || errors.Is(e, TooLarge) {
+		t.Error("Is should compare by reason")
+	}
+	if !errors.Is(fmt.Errorf("x: %w", e), NoImage) {
+		t.Error("Is should see through wrapping")
+	}
+	// 副本不修改目录中的错误
+	if NoImage.Message != "no og:image found" {
+		t.Errorf("catalog error modified: %q", NoImage.Message)
+	}
+}
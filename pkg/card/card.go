@@ -0,0 +1,113 @@
+// Package card 把预览信息渲染成可以直接嵌入页面的 HTML 卡片，样式内联，不依赖外部资源
+package card
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+	// ThemeAuto 跟随访问者系统的深色模式
+	ThemeAuto = "auto"
+
+	maxTitle       = 200
+	maxDescription = 500
+)
+
+var Themes = []string{ThemeLight, ThemeDark, ThemeAuto}
+
+//go:embed card.html
+var cardTemplate string
+
+// html/template 按上下文转义，页面中的字符串不会被当作 HTML 或脚本
+var tmpl = template.Must(template.New("card").Parse(cardTemplate))
+
+// Card 卡片内容，除 OEmbed 外均来自被抓取的页面，渲染前会清理
+type Card struct {
+	Url         string
+	Title       string
+	Description string
+	Logo        string
+	Image       string
+	// OEmbed 卡片的 oEmbed 地址，用于输出发现链接
+	OEmbed string
+}
+
+type view struct {
+	Card
+	Domain string
+	Theme  string
+}
+
+// ValidTheme 判断是否为支持的主题
+func ValidTheme(theme string) bool {
+	for _, t := range Themes {
+		if t == theme {
+			return true
+		}
+	}
+	return false
+}
+
+// Render 渲染卡片，theme 不支持时使用浅色主题
+func Render(c Card, theme string) (string, error) {
+	if !ValidTheme(theme) {
+		theme = ThemeLight
+	}
+	c = Sanitize(c)
+	v := view{Card: c, Domain: domain(c.Url), Theme: theme}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Sanitize 去掉控制字符、合并空白并截断过长的文字，丢弃非 http(s) 的地址
+func Sanitize(c Card) Card {
+	return Card{
+		Url:         safeUrl(c.Url),
+		Title:       cleanText(c.Title, maxTitle),
+		Description: cleanText(c.Description, maxDescription),
+		Logo:        safeUrl(c.Logo),
+		Image:       safeUrl(c.Image),
+		OEmbed:      safeUrl(c.OEmbed),
+	}
+}
+
+func cleanText(s string, limit int) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return ' '
+		}
+		return r
+	}, s)
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) > limit {
+		s = strings.TrimSpace(string([]rune(s)[:limit-1])) + "…"
+	}
+	return s
+}
+
+func safeUrl(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+func domain(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
@@ -0,0 +1,98 @@
+package card
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitize(t *testing.T) {
+	c := Sanitize(Card{
+		Url:         " https://example.com/post?a=1 ",
+		Title:       "  Example\n\tpage\x00\x1b[31m  ",
+		Description: "bad \xff byte",
+		Logo:        "javascript:alert(1)",
+		Image:       "data:image/png;base64,AAAA",
+		OEmbed:      "/relative/oembed",
+	})
+	want := Card{
+		Url:         "https://example.com/post?a=1",
+		Title:       "Example page [31m",
+		Description: "bad byte",
+	}
+	if c != want {
+		t.Errorf("sanitized = %+v, want %+v", c, want)
+	}
+
+	for _, s := range []string{"", "//example.com/a.png", "ftp://example.com/a.png", "https://", "http://exa mple.com"} {
+		if got := safeUrl(s); got != "" {
+			t.Errorf("safeUrl(%q) = %q", s, got)
+		}
+	}
+}
+
+func TestCleanTextLimit(t *testing.T) {
+	s := cleanText(strings.Repeat("标题 ", 200), maxTitle)
+	if n := utf8.RuneCountInString(s); n > maxTitle {
+		t.Errorf("title is %d characters", n)
+	}
+	if !strings.HasSuffix(s, "…") {
+		t.Errorf("title %q does not end with an ellipsis", s)
+	}
+	if s := cleanText(strings.Repeat("a", maxTitle), maxTitle); s != strings.Repeat("a", maxTitle) {
+		t.Errorf("title at the limit must not be truncated")
+	}
+}
+
+func TestRender(t *testing.T) {
+	html, err := Render(Card{
+		Url:         "https://www.example.com/post",
+		Title:       `</span><script>alert("x")</script>`,
+		Description: `<img src=x onerror=alert(1)>`,
+		Image:       `https://example.com/a.png" onerror="alert(1)`,
+		Logo:        "javascript:alert(1)",
+		OEmbed:      "https://ogimg.example/v1/oembed?url=https%3A%2F%2Fwww.example.com%2Fpost",
+	}, "neon")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []string{"<script>", "<img src=x", `" onerror="`, "javascript:", "ogimg-neon"} {
+		if strings.Contains(html, bad) {
+			t.Errorf("card contains %q:\n%s", bad, html)
+		}
+	}
+	for _, good := range []string{
+		`class="ogimg-card ogimg-light"`,
+		`href="https://www.example.com/post"`,
+		"&lt;/span&gt;&lt;script&gt;",
+		">example.com<",
+		`type="application/json+oembed"`,
+	} {
+		if !strings.Contains(html, good) {
+			t.Errorf("card is missing %q:\n%s", good, html)
+		}
+	}
+	// 非法的 logo 被丢弃，不输出空的 img
+	if strings.Contains(html, "ogimg-logo\" src") {
+		t.Errorf("card contains a logo:\n%s", html)
+	}
+}
+
+func TestRenderTheme(t *testing.T) {
+	for _, theme := range Themes {
+		html, err := Render(Card{Url: "https://example.com"}, theme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(html, "ogimg-card ogimg-"+theme) {
+			t.Errorf("%s theme is not applied", theme)
+		}
+		if strings.Contains(html, "ogimg-image\"") || strings.Contains(html, "json+oembed") {
+			t.Errorf("empty card renders an image or oembed link:\n%s", html)
+		}
+	}
+	if ValidTheme("neon") || !ValidTheme(ThemeAuto) {
+		t.Error("ValidTheme")
+	}
+}
@@ -0,0 +1,301 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/webhook"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client ogimg 的 Go 客户端，5xx 和 429 会按指数退避重试
+type Client struct {
+	baseUrl    string
+	httpClient *http.Client
+	header     http.Header
+	adminKey   string
+	appKey     string
+	appSecret  string
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient 使用自定义的 http.Client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader 为每个请求添加请求头，例如网关的鉴权信息
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithAPIKey 通过 X-API-Key 发送 api key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.header.Set("X-API-Key", key)
+	}
+}
+
+// WithAdminKey 设置 /admin 接口使用的 key，只在请求 /admin 时发送
+func WithAdminKey(key string) Option {
+	return func(c *Client) {
+		c.adminKey = key
+	}
+}
+
+// WithSigning 按 security.api_sign 的 app_key 和 app_security 对每个请求签名
+func WithSigning(appKey, appSecret string) Option {
+	return func(c *Client) {
+		c.appKey = appKey
+		c.appSecret = appSecret
+	}
+}
+
+// WithRetry 设置最大重试次数和初始退避时间，maxRetries 为 0 时不重试
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+func NewClient(baseUrl string, opts ...Option) *Client {
+	c := &Client{
+		baseUrl:    strings.TrimRight(baseUrl, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		header:     http.Header{},
+		maxRetries: 3,
+		backoff:    200 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type Image struct {
+	Data        []byte
+	ContentType string
+}
+
+type Desc struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Logo        string `json:"logo"`
+}
+
+// BatchDescResult 单个 url 的结果，Error 可以用 errors.Is(err, apierr.InvalidUrl) 判断
+type BatchDescResult struct {
+	Url   string        `json:"url"`
+	Desc  *Desc         `json:"desc,omitempty"`
+	Error *apierr.Error `json:"error,omitempty"`
+}
+
+// ImageOptions 服务端缩放参数，Width、Height 为 0 时不限制，Fit 为 contain 或 cover
+type ImageOptions struct {
+	Width  int
+	Height int
+	Fit    string
+}
+
+// Image 获取网站的 og:image，opts 为 nil 时返回原图
+func (c *Client) Image(ctx context.Context, siteUrl string, opts *ImageOptions) (*Image, error) {
+	q := query(siteUrl)
+	if opts != nil {
+		if opts.Width > 0 {
+			q.Set("width", strconv.Itoa(opts.Width))
+		}
+		if opts.Height > 0 {
+			q.Set("height", strconv.Itoa(opts.Height))
+		}
+		if opts.Fit != "" {
+			q.Set("fit", opts.Fit)
+		}
+	}
+	return c.image(ctx, "/v1/image", q)
+}
+
+// Icon 获取网站图标，页面没有声明时为站点的 /favicon.ico
+func (c *Client) Icon(ctx context.Context, siteUrl string) (*Image, error) {
+	return c.image(ctx, "/v1/icon", query(siteUrl))
+}
+
+func (c *Client) image(ctx context.Context, path string, q url.Values) (*Image, error) {
+	res, body, err := c.do(ctx, http.MethodGet, path, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Data: body, ContentType: res.Header.Get("Content-Type")}, nil
+}
+
+// Desc 获取网站的标题、描述和 logo
+func (c *Client) Desc(ctx context.Context, siteUrl string) (*Desc, error) {
+	_, body, err := c.do(ctx, http.MethodGet, "/v1/desc", query(siteUrl), nil)
+	if err != nil {
+		return nil, err
+	}
+	var desc Desc
+	if err := json.Unmarshal(body, &desc); err != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+// DescBatch 批量获取网站描述，结果顺序与 siteUrls 一致
+func (c *Client) DescBatch(ctx context.Context, siteUrls []string) ([]BatchDescResult, error) {
+	payload, err := json.Marshal(siteUrls)
+	if err != nil {
+		return nil, err
+	}
+	_, body, err := c.do(ctx, http.MethodPost, "/v1/desc/batch", nil, payload)
+	if err != nil {
+		return nil, err
+	}
+	var results []BatchDescResult
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Purge 删除 url 的图片、图标和描述缓存，返回删除的缓存项数量，需要 WithAdminKey
+func (c *Client) Purge(ctx context.Context, siteUrl string) (int64, error) {
+	_, body, err := c.do(ctx, http.MethodDelete, "/admin/cache", query(siteUrl), nil)
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, err
+	}
+	return result.Deleted, nil
+}
+
+func query(siteUrl string) url.Values {
+	return url.Values{"url": {siteUrl}}
+}
+
+// do 发送请求并在可重试的错误上退避重试，非 2xx 响应解析为 *apierr.Error
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, payload []byte) (*http.Response, []byte, error) {
+	endpoint := c.baseUrl + path
+	if len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, body, err := c.send(ctx, method, endpoint, payload)
+		if err == nil && res.StatusCode < 300 {
+			return res, body, nil
+		}
+		if err == nil {
+			err = parseError(res, body)
+		}
+		if attempt >= c.maxRetries || !retryable(ctx, res, err) {
+			return nil, nil, err
+		}
+
+		wait := c.backoff << attempt
+		if wait > c.maxBackoff || wait <= 0 {
+			wait = c.maxBackoff
+		}
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		if res != nil {
+			if after, convErr := strconv.Atoi(res.Header.Get("Retry-After")); convErr == nil {
+				wait = time.Duration(after) * time.Second
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, endpoint string, payload []byte) (*http.Response, []byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range c.header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.adminKey != "" && strings.HasPrefix(endpoint, c.baseUrl+"/admin/") {
+		req.Header.Set("X-API-Key", c.adminKey)
+	}
+	if c.appKey != "" {
+		// 每次重试重新签名，避免时间戳过期
+		req.Header.Set("X-Ogimg-App-Key", c.appKey)
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(c.appSecret, time.Now(), webhook.RequestPayload(method, req.URL.RequestURI(), payload)))
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, body, nil
+}
+
+func parseError(res *http.Response, body []byte) error {
+	var envelope struct {
+		Code    int    `json:"code"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Message == "" {
+		return apierr.New(res.StatusCode, 0, "", fmt.Sprintf("unexpected status %d", res.StatusCode))
+	}
+	return apierr.New(res.StatusCode, envelope.Code, envelope.Reason, envelope.Message)
+}
+
+// retryable 429 和 5xx 可以重试，但上游返回的错误状态码不会因重试而改变
+func retryable(ctx context.Context, res *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if res == nil {
+		// 网络错误
+		return true
+	}
+	if errors.Is(err, apierr.UpstreamStatus) {
+		return false
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
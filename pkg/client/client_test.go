@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/webhook"
+)
+
+// writeError 按服务端的错误格式返回
+func writeError(w http.ResponseWriter, e *apierr.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": e.Code, "reason": e.Reason, "message": e.Message, "data": map[string]string{}})
+}
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	opts = append([]Option{WithRetry(3, time.Millisecond)}, opts...)
+	return NewClient(srv.URL+"/", opts...)
+}
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			writeError(w, apierr.Internal)
+			return
+		}
+		w.Write([]byte(`{"title":"GitHub"}`))
+	})
+	desc, err := c.Desc(context.Background(), "https://github.com")
+	if err != nil || desc.Title != "GitHub" {
+		t.Fatalf("Desc = %+v, %v", desc, err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeError(w, apierr.UpstreamUnreachable)
+	})
+	_, err := c.Desc(context.Background(), "https://github.com")
+	if !errors.Is(err, apierr.UpstreamUnreachable) {
+		t.Fatalf("expected upstream_unreachable, got %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("calls = %d, want 1 + 3 retries", calls)
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	for _, e := range []*apierr.Error{apierr.UpstreamStatus, apierr.InvalidUrl, apierr.NoImage} {
+		var calls int32
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			writeError(w, e)
+		})
+		if _, err := c.Image(context.Background(), "https://github.com", nil); !errors.Is(err, e) {
+			t.Errorf("expected %s, got %v", e.Reason, err)
+		}
+		if calls != 1 {
+			t.Errorf("%s: calls = %d, want 1", e.Reason, calls)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls int32
+	var first time.Time
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			writeError(w, apierr.RateLimited)
+			return
+		}
+		if d := time.Since(first); d < time.Second {
+			t.Errorf("retried after %s, want at least Retry-After", d)
+		}
+		w.Write([]byte(`{}`))
+	})
+	if _, err := c.Desc(context.Background(), "https://github.com"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		writeError(w, apierr.RateLimited)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Desc(ctx, "https://github.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("cancel took %s", d)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/desc" {
+			writeError(w, apierr.Blocked.WithMessage("url blocked by policy rule deny:example.com"))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("<html>bad gateway page</html>"))
+	}, WithRetry(0, 0))
+
+	_, err := c.Desc(context.Background(), "https://example.com")
+	var e *apierr.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *apierr.Error, got %T", err)
+	}
+	if e.Status != http.StatusForbidden || e.Code != apierr.Blocked.Code || e.Reason != "blocked" || e.Message != "url blocked by policy rule deny:example.com" {
+		t.Errorf("error = %+v", e)
+	}
+
+	// 不是 JSON 的错误响应只保留状态码
+	_, err = c.Image(context.Background(), "https://example.com", nil)
+	if !errors.As(err, &e) || e.Status != http.StatusBadRequest || e.Reason != "" || e.Message != "unexpected status 400" {
+		t.Errorf("error = %+v", err)
+	}
+}
+
+func TestImageOptions(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/image" || q.Get("url") != "https://github.com" || q.Get("width") != "600" || q.Has("height") || q.Get("fit") != "cover" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	})
+	img, err := c.Image(context.Background(), "https://github.com", &ImageOptions{Width: 600, Fit: "cover"})
+	if err != nil || string(img.Data) != "png" || img.ContentType != "image/png" {
+		t.Fatalf("Image = %+v, %v", img, err)
+	}
+}
+
+func TestIconAndPurge(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/icon":
+			if r.Header.Get("X-API-Key") != "user" {
+				t.Errorf("icon request key = %q, want the api key", r.Header.Get("X-API-Key"))
+			}
+			w.Header().Set("Content-Type", "image/x-icon")
+			w.Write([]byte("ico"))
+		case r.Method == http.MethodDelete && r.URL.Path == "/admin/cache":
+			if r.Header.Get("X-API-Key") != "admin" || r.URL.Query().Get("url") != "https://github.com" {
+				t.Errorf("unexpected purge request %s key %q", r.URL, r.Header.Get("X-API-Key"))
+			}
+			w.Write([]byte(`{"deleted":3}`))
+		default:
+			writeError(w, apierr.NotFound)
+		}
+	}, WithAPIKey("user"), WithAdminKey("admin"))
+
+	img, err := c.Icon(context.Background(), "https://github.com")
+	if err != nil || string(img.Data) != "ico" || img.ContentType != "image/x-icon" {
+		t.Fatalf("Icon = %+v, %v", img, err)
+	}
+	deleted, err := c.Purge(context.Background(), "https://github.com")
+	if err != nil || deleted != 3 {
+		t.Fatalf("Purge = %d, %v", deleted, err)
+	}
+}
+
+func TestSigning(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payload := webhook.RequestPayload(r.Method, r.RequestURI, body)
+		if r.Header.Get("X-Ogimg-App-Key") != "app" {
+			t.Errorf("app key = %q", r.Header.Get("X-Ogimg-App-Key"))
+		}
+		if err := webhook.Verify("secret", r.Header.Get(webhook.SignatureHeader), payload, time.Minute); err != nil {
+			t.Errorf("%s %s: %v", r.Method, r.RequestURI, err)
+		}
+		w.Write([]byte(`[]`))
+	}, WithSigning("app", "secret"))
+
+	if _, err := c.DescBatch(context.Background(), []string{"https://github.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Image(context.Background(), "https://github.com/?a=1&b=2", &ImageOptions{Height: 10}); err != nil {
+		t.Fatal(err)
+	}
+}
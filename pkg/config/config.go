@@ -4,11 +4,28 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
-func NewConfig() *viper.Viper {
+// EnvPrefix 环境变量覆盖配置的前缀，例如 OGIMG_DATA_REDIS_ADDR 覆盖 data.redis.addr
+const EnvPrefix = "OGIMG"
+
+// Config 并发安全的配置，Watcher 重载时持有写锁更新，读取时持有读锁
+type Config struct {
+	mu sync.RWMutex
+	v  *viper.Viper
+}
+
+// New 包装已读取的配置，之后只通过 Config 读写
+func New(v *viper.Viper) *Config {
+	return &Config{v: v}
+}
+
+func NewConfig() *Config {
 	envConf := os.Getenv("APP_CONF")
 	if envConf == "" {
 		flag.StringVar(&envConf, "conf", "./config/local.yml", "config path, eg: -conf config/local.yml")
@@ -18,15 +35,98 @@ func NewConfig() *viper.Viper {
 		envConf = "./config/local.yml"
 	}
 	fmt.Println("load conf file:", envConf)
-	return getConfig(envConf)
+	conf := getConfig(envConf)
+	if err := Validate(conf); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config %s:\n%v\n", envConf, err)
+		os.Exit(1)
+	}
+	return New(conf)
 
 }
 func getConfig(path string) *viper.Viper {
-	conf := viper.New()
-	conf.SetConfigFile(path)
-	err := conf.ReadInConfig()
+	conf, err := readConfig(path)
 	if err != nil {
 		panic(err)
 	}
 	return conf
 }
+
+// readConfig 读取配置文件，并允许 OGIMG_ 前缀的环境变量覆盖其中任意一项
+func readConfig(path string) (*viper.Viper, error) {
+	conf := viper.New()
+	conf.SetConfigFile(path)
+	conf.SetEnvPrefix(EnvPrefix)
+	conf.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	conf.AutomaticEnv()
+	if err := conf.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func (c *Config) Get(key string) interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.Get(key)
+}
+
+func (c *Config) GetString(key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.GetString(key)
+}
+
+func (c *Config) GetStringSlice(key string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.GetStringSlice(key)
+}
+
+func (c *Config) GetBool(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.GetBool(key)
+}
+
+func (c *Config) GetInt(key string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.GetInt(key)
+}
+
+func (c *Config) GetInt64(key string) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.GetInt64(key)
+}
+
+func (c *Config) GetFloat64(key string) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.GetFloat64(key)
+}
+
+func (c *Config) GetDuration(key string) time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.GetDuration(key)
+}
+
+func (c *Config) IsSet(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.IsSet(key)
+}
+
+// Set 覆盖一项配置，用于测试和命令行参数
+func (c *Config) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.v.Set(key, value)
+}
+
+func (c *Config) ConfigFileUsed() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v.ConfigFileUsed()
+}
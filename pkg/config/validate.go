@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// 未出现在配置中的项不做检查，由使用方的默认值处理
+var (
+	positiveDurations = []string{
+		"data.redis.expire_time",
+		"crawler.timeout",
+		"crawler.robots.cache_ttl",
+		"warmup.timeout",
+		"warmup.job_ttl",
+		"jobs.timeout",
+		"jobs.ttl",
+		"webhooks.poll_interval",
+		"webhooks.timeout",
+		"webhooks.backoff",
+		"webhooks.max_backoff",
+		"webhooks.log_ttl",
+		"webhooks.snapshot_ttl",
+		"health.timeout",
+		"health.fetch_interval",
+		"log.debug_tolerance",
+		"security.api_sign.tolerance",
+	}
+	positiveInts = []string{
+		"crawler.max_html_size",
+		"crawler.max_image_size",
+		"batch.max_urls",
+		"batch.concurrency",
+		"warmup.concurrency",
+		"warmup.max_concurrency",
+		"warmup.max_urls",
+		"warmup.max_document_size",
+		"jobs.workers",
+		"jobs.queue_size",
+		"webhooks.max_attempts",
+		"webhooks.log_size",
+		"webhooks.max_urls",
+		"history.max_versions",
+		"history.max_changes",
+		"history.max_fetches",
+	}
+	nonNegativeDurations = []string{
+		"data.redis.stale_time",
+	}
+	nonNegativeInts = []string{
+		"webhooks.workers",
+		"log.sampling.initial",
+		"log.sampling.thereafter",
+	}
+	enums = map[string][]string{
+		"data.db.driver":      {"sqlite", "mysql", "none"},
+		"crawler.robots.mode": {"off", "advisory", "enforce"},
+		"jobs.queue":          {"redis", "memory"},
+		"log.log_level":       {"debug", "info", "warn", "error"},
+		"log.encoding":        {"json", "console"},
+		"telemetry.exporter":  {"stdout", "otlp", "file"},
+	}
+)
+
+// Validate 检查配置项的取值，返回所有有问题的配置项
+func Validate(conf *viper.Viper) error {
+	var errs []error
+	invalid := func(key string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if port, err := cast.ToIntE(conf.Get("http.port")); err != nil || port <= 0 || port > 65535 {
+		invalid("http.port", "must be a port number between 1 and 65535, got %q", conf.GetString("http.port"))
+	}
+	if conf.GetString("data.redis.addr") == "" {
+		invalid("data.redis.addr", "is required")
+	}
+
+	for _, key := range positiveDurations {
+		if !conf.IsSet(key) {
+			continue
+		}
+		if d, err := cast.ToDurationE(conf.Get(key)); err != nil || d <= 0 {
+			invalid(key, "must be a positive duration such as 30s or 1h, got %q", conf.GetString(key))
+		}
+	}
+	for _, key := range nonNegativeDurations {
+		if !conf.IsSet(key) {
+			continue
+		}
+		if d, err := cast.ToDurationE(conf.Get(key)); err != nil || d < 0 {
+			invalid(key, "must be zero or a positive duration such as 30s or 1h, got %q", conf.GetString(key))
+		}
+	}
+	for _, key := range positiveInts {
+		if !conf.IsSet(key) {
+			continue
+		}
+		if n, err := cast.ToInt64E(conf.Get(key)); err != nil || n <= 0 {
+			invalid(key, "must be a positive integer, got %q", conf.GetString(key))
+		}
+	}
+	for _, key := range nonNegativeInts {
+		if !conf.IsSet(key) {
+			continue
+		}
+		if n, err := cast.ToInt64E(conf.Get(key)); err != nil || n < 0 {
+			invalid(key, "must be zero or a positive integer, got %q", conf.GetString(key))
+		}
+	}
+	for key, values := range enums {
+		if !conf.IsSet(key) {
+			continue
+		}
+		if v := conf.GetString(key); !contains(values, v) {
+			invalid(key, "must be one of %s, got %q", strings.Join(values, ", "), v)
+		}
+	}
+
+	if conf.GetBool("security.api_sign.enabled") {
+		for _, key := range []string{"security.api_sign.app_key", "security.api_sign.app_security"} {
+			if conf.GetString(key) == "" {
+				invalid(key, "is required when security.api_sign.enabled is true")
+			}
+		}
+	}
+	if conf.IsSet("telemetry.sample_ratio") {
+		if ratio, err := cast.ToFloat64E(conf.Get("telemetry.sample_ratio")); err != nil || ratio < 0 || ratio > 1 {
+			invalid("telemetry.sample_ratio", "must be between 0 and 1, got %q", conf.GetString("telemetry.sample_ratio"))
+		}
+	}
+	if conf.IsSet("mockup.scale") {
+		if scale, err := cast.ToFloat64E(conf.Get("mockup.scale")); err != nil || scale <= 0 || scale > 4 {
+			invalid("mockup.scale", "must be greater than 0 and at most 4, got %q", conf.GetString("mockup.scale"))
+		}
+	}
+	for _, key := range []string{"mockup.font_regular", "mockup.font_bold"} {
+		if path := conf.GetString(key); path != "" {
+			if _, err := os.Stat(path); err != nil {
+				invalid(key, "%v", err)
+			}
+		}
+	}
+	// 请求中的 Host 和 X-Forwarded-Proto 由调用方控制，不能用来生成返回的地址
+	if publicUrl := conf.GetString("http.public_url"); publicUrl == "" {
+		invalid("http.public_url", "is required")
+	} else if u, err := url.Parse(publicUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("http.public_url", "must be an absolute http(s) url, got %q", publicUrl)
+	}
+	if fetchUrl := conf.GetString("health.fetch_url"); fetchUrl != "" {
+		if u, err := url.Parse(fetchUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("health.fetch_url", "must be an absolute http(s) url, got %q", fetchUrl)
+		}
+	}
+
+	// map 遍历顺序不固定，按错误信息排序后输出
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateWorkers(t *testing.T) {
+	tests := []struct {
+		key   string
+		value interface{}
+		ok    bool
+	}{
+		{"jobs.workers", 1, true},
+		{"jobs.workers", 0, false},
+		{"jobs.workers", -1, false},
+		// 为 0 时只排队不投递
+		{"webhooks.workers", 0, true},
+		{"webhooks.workers", -1, false},
+	}
+	for _, tt := range tests {
+		v := viper.New()
+		v.Set("http.port", 8000)
+		v.Set("http.public_url", "https://ogimg.example")
+		v.Set("data.redis.addr", "127.0.0.1:6379")
+		v.Set(tt.key, tt.value)
+		err := Validate(v)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: %v, Validate = %v", tt.key, tt.value, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), tt.key) {
+			t.Errorf("error does not name %s: %v", tt.key, err)
+		}
+	}
+}
+
+func TestValidatePublicUrl(t *testing.T) {
+	for value, ok := range map[string]bool{
+		"https://ogimg.example":  true,
+		"http://localhost:8888/": true,
+		"":                       false,
+		"ogimg.example":          false,
+		"ftp://ogimg.example":    false,
+	} {
+		v := viper.New()
+		v.Set("http.port", 8000)
+		v.Set("data.redis.addr", "127.0.0.1:6379")
+		v.Set("http.public_url", value)
+		if err := Validate(v); (err == nil) != ok {
+			t.Errorf("http.public_url %q: Validate = %v", value, err)
+		}
+	}
+}
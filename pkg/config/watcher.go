@@ -0,0 +1,171 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Reloadable 可以不重启生效的配置项，以 . 结尾时匹配整段配置，其余配置项修改后需要重启
+var Reloadable = []string{
+	"log.log_level",
+	"log.debug_secret",
+	"log.debug_tolerance",
+	"security.api_sign.",
+	"policy.",
+	"auth.",
+	"data.redis.expire_time",
+	"data.redis.stale_time",
+	"crawler.robots.",
+	"batch.",
+	"warmup.",
+	"jobs.timeout",
+	"jobs.ttl",
+	"webhooks.max_attempts",
+	"webhooks.backoff",
+	"webhooks.max_backoff",
+	"webhooks.log_size",
+	"webhooks.log_ttl",
+	"webhooks.snapshot_ttl",
+	"webhooks.max_urls",
+	"health.",
+	"history.",
+	"oembed.",
+}
+
+// Change 一次配置重载中生效的配置项
+type Change struct {
+	Keys []string
+}
+
+// Watcher 监听配置文件变化，校验通过后只应用 Reloadable 中的配置项，并通知订阅者
+type Watcher struct {
+	conf       *Config
+	logger     *zap.Logger
+	mu         sync.Mutex
+	subs       []subscriber
+	validators []func(*Config) error
+}
+
+type subscriber struct {
+	prefixes []string
+	ch       chan Change
+}
+
+// NewWatcher logger 使用 zap 实例，避免与 pkg/log 循环引用
+func NewWatcher(conf *Config, logger *zap.Logger) *Watcher {
+	return &Watcher{conf: conf, logger: logger}
+}
+
+// Subscribe 订阅 prefixes 匹配的配置项的变化（规则同 Reloadable），不传 prefixes 时订阅所有变化。
+// 通道缓冲为 1，订阅者处理不及时会合并通知，收到通知后应从配置中读取最新值
+func (w *Watcher) Subscribe(prefixes ...string) <-chan Change {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ch := make(chan Change, 1)
+	w.subs = append(w.subs, subscriber{prefixes: prefixes, ch: ch})
+	return ch
+}
+
+// AddValidator 添加重载前的额外校验，任一校验失败时整个重载被拒绝
+func (w *Watcher) AddValidator(validate func(*Config) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.validators = append(w.validators, validate)
+}
+
+// Watch 开始监听配置文件，只需调用一次
+func (w *Watcher) Watch() {
+	file, err := readConfig(w.conf.ConfigFileUsed())
+	if err != nil {
+		w.logger.Error("Watch config error", zap.Error(err))
+		return
+	}
+	file.OnConfigChange(func(e fsnotify.Event) {
+		w.reload(file, e.Name)
+	})
+	file.WatchConfig()
+}
+
+func (w *Watcher) reload(next *viper.Viper, name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := Validate(next); err != nil {
+		w.logger.Error("Reload config error, keep previous config", zap.String("file", name), zap.Error(err))
+		return
+	}
+	for _, validate := range w.validators {
+		if err := validate(New(next)); err != nil {
+			w.logger.Error("Reload config error, keep previous config", zap.String("file", name), zap.Error(err))
+			return
+		}
+	}
+
+	// 持有写锁一次更新所有配置项，读取方不会看到只更新了一半的配置
+	var applied, skipped []string
+	w.conf.mu.Lock()
+	for _, key := range changedKeys(w.conf.v, next) {
+		if !matchPrefix(Reloadable, key) {
+			skipped = append(skipped, key)
+			continue
+		}
+		w.conf.v.Set(key, next.Get(key))
+		applied = append(applied, key)
+	}
+	w.conf.mu.Unlock()
+	if len(skipped) > 0 {
+		w.logger.Warn("Config changes require a restart", zap.Strings("keys", skipped))
+	}
+	if len(applied) == 0 {
+		return
+	}
+	w.logger.Info("Config reloaded", zap.String("file", name), zap.Strings("keys", applied))
+
+	for _, sub := range w.subs {
+		var keys []string
+		for _, key := range applied {
+			if len(sub.prefixes) == 0 || matchPrefix(sub.prefixes, key) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		select {
+		case sub.ch <- Change{Keys: keys}:
+		default:
+		}
+	}
+}
+
+// changedKeys 比较两份配置，返回取值不同的配置项
+func changedKeys(prev, next *viper.Viper) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, key := range append(prev.AllKeys(), next.AllKeys()...) {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if !reflect.DeepEqual(prev.Get(key), next.Get(key)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func matchPrefix(prefixes []string, key string) bool {
+	for _, prefix := range prefixes {
+		if key == prefix || (strings.HasSuffix(prefix, ".") && strings.HasPrefix(key, prefix)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func writeConfig(t *testing.T, path string, maxUrls int, port int) {
+	t.Helper()
+	content := fmt.Sprintf("http:\n  port: %d\n  public_url: https://ogimg.example\ndata:\n  redis:\n    addr: 127.0.0.1:6379\nbatch:\n  max_urls: %d\n", port, maxUrls)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestConfig(t *testing.T, path string) *viper.Viper {
+	t.Helper()
+	v, err := readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// 重载时并发读取配置，需要 go test -race 检查
+func TestReloadWhileReading(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig(t, path, 1, 8000)
+	conf := New(readTestConfig(t, path))
+	w := NewWatcher(conf, zap.NewNop())
+	changes := w.Subscribe("batch.")
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if n := conf.GetInt("batch.max_urls"); n < 1 || n > 100 {
+					t.Errorf("batch.max_urls = %d", n)
+					return
+				}
+				conf.IsSet("http.port")
+				conf.GetString("data.redis.addr")
+			}
+		}()
+	}
+
+	for i := 2; i <= 100; i++ {
+		writeConfig(t, path, i, 8000)
+		w.reload(readTestConfig(t, path), path)
+	}
+	close(stop)
+	wg.Wait()
+
+	if n := conf.GetInt("batch.max_urls"); n != 100 {
+		t.Errorf("batch.max_urls = %d after reload, want 100", n)
+	}
+	select {
+	case change := <-changes:
+		if len(change.Keys) != 1 || change.Keys[0] != "batch.max_urls" {
+			t.Errorf("change = %+v", change)
+		}
+	default:
+		t.Error("subscriber was not notified")
+	}
+}
+
+// 不可重载的配置项保持原值，校验失败的配置整个被拒绝
+func TestReloadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig(t, path, 10, 8000)
+	conf := New(readTestConfig(t, path))
+	w := NewWatcher(conf, zap.NewNop())
+
+	writeConfig(t, path, 20, 9000)
+	w.reload(readTestConfig(t, path), path)
+	if conf.GetInt("batch.max_urls") != 20 || conf.GetInt("http.port") != 8000 {
+		t.Errorf("max_urls = %d, port = %d", conf.GetInt("batch.max_urls"), conf.GetInt("http.port"))
+	}
+
+	writeConfig(t, path, -1, 8000)
+	w.reload(readTestConfig(t, path), path)
+	if n := conf.GetInt("batch.max_urls"); n != 20 {
+		t.Errorf("invalid config applied, max_urls = %d", n)
+	}
+
+	w.AddValidator(func(next *Config) error {
+		if next.GetInt("batch.max_urls") == 30 {
+			return fmt.Errorf("rejected")
+		}
+		return nil
+	})
+	writeConfig(t, path, 30, 8000)
+	w.reload(readTestConfig(t, path), path)
+	if n := conf.GetInt("batch.max_urls"); n != 20 {
+		t.Errorf("config rejected by a validator applied, max_urls = %d", n)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig(t, path, 10, 8000)
+	conf := New(readTestConfig(t, path))
+	w := NewWatcher(conf, zap.NewNop())
+	changes := w.Subscribe("batch.")
+	w.Watch()
+
+	writeConfig(t, path, 42, 8000)
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change after editing the file")
+	}
+	if n := conf.GetInt("batch.max_urls"); n != 42 {
+		t.Errorf("batch.max_urls = %d", n)
+	}
+}
@@ -0,0 +1,172 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"ogimg/pkg/apierr"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/html"
+)
+
+// tracer 使用全局 TracerProvider，调用方未配置 OpenTelemetry 时不产生开销
+var tracer = otel.Tracer("ogimg/pkg/extract")
+
+// Options 抓取参数，零值使用默认配置
+type Options struct {
+	UserAgent    string
+	MaxHTMLSize  int64
+	MaxImageSize int64
+	Client       *http.Client
+}
+
+// Extractor 抓取页面并提取预览信息，可以脱离 HTTP 服务单独使用
+type Extractor struct {
+	opts Options
+}
+
+type Image struct {
+	Data        []byte
+	ContentType string
+}
+
+func NewExtractor(opts Options) *Extractor {
+	if opts.UserAgent == "" {
+		opts.UserAgent = "Mozilla/5.0 (compatible; ogimg/1.0; +https://github.com/peterroe/ogimg)"
+	}
+	if opts.MaxHTMLSize <= 0 {
+		opts.MaxHTMLSize = 5 << 20
+	}
+	if opts.MaxImageSize <= 0 {
+		opts.MaxImageSize = 10 << 20
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Extractor{opts: opts}
+}
+
+// Extract 抓取 pageUrl 并提取预览信息
+func (e *Extractor) Extract(ctx context.Context, pageUrl string) (meta *Metadata, err error) {
+	ctx, span := tracer.Start(ctx, "extract.Extract", trace.WithAttributes(attribute.String("url.full", pageUrl)))
+	defer func() { endSpan(span, err) }()
+
+	doc, err := e.FetchPage(ctx, pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	_, parseSpan := tracer.Start(ctx, "extract.ParseNode")
+	meta = ParseNode(doc, pageUrl)
+	parseSpan.SetAttributes(attribute.Int("extract.image_candidates", len(meta.Images)))
+	parseSpan.End()
+	return meta, nil
+}
+
+// ExtractImage 抓取 pageUrl 的预览信息和 og:image 图片
+func (e *Extractor) ExtractImage(ctx context.Context, pageUrl string) (*Metadata, *Image, error) {
+	meta, err := e.Extract(ctx, pageUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta.Image == "" {
+		return meta, nil, apierr.NoImage
+	}
+	img, err := e.FetchImage(ctx, meta.Image)
+	if err != nil {
+		return meta, nil, err
+	}
+	return meta, img, nil
+}
+
+// FetchPage 获取并解析 HTML，超过 MaxHTMLSize 的部分会被忽略；
+// HTML 边下载边解析，span 中包含了读取响应体的时间
+func (e *Extractor) FetchPage(ctx context.Context, pageUrl string) (doc *html.Node, err error) {
+	ctx, span := tracer.Start(ctx, "extract.FetchPage")
+	defer func() { endSpan(span, err) }()
+
+	res, err := e.Get(ctx, pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	contentType := res.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, apierr.UnsupportedType.WithMessage("page content type is " + contentType)
+	}
+
+	doc, err = html.Parse(io.LimitReader(res.Body, e.opts.MaxHTMLSize))
+	if err != nil {
+		return nil, apierr.Upstream(err)
+	}
+	return doc, nil
+}
+
+// FetchImage 下载图片，内容类型不是图片或超过 MaxImageSize 时返回错误
+func (e *Extractor) FetchImage(ctx context.Context, imageUrl string) (img *Image, err error) {
+	ctx, span := tracer.Start(ctx, "extract.FetchImage", trace.WithAttributes(attribute.String("url.full", imageUrl)))
+	defer func() {
+		if img != nil {
+			span.SetAttributes(attribute.Int("image.size", len(img.Data)), attribute.String("image.content_type", img.ContentType))
+		}
+		endSpan(span, err)
+	}()
+
+	res, err := e.Get(ctx, imageUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	maxSize := e.opts.MaxImageSize
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return nil, apierr.Upstream(err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, apierr.TooLarge.WithMessage(fmt.Sprintf("image is larger than %d bytes", maxSize))
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		// 部分站点返回 application/octet-stream，按内容识别
+		contentType = http.DetectContentType(body)
+		if !strings.HasPrefix(contentType, "image/") {
+			return nil, apierr.UnsupportedType.WithMessage("image content type is " + contentType)
+		}
+	}
+	return &Image{Data: body, ContentType: contentType}, nil
+}
+
+// Get 以配置的 User-Agent 发起 GET 请求，4xx/5xx 视为错误，调用方负责关闭 Body
+func (e *Extractor) Get(ctx context.Context, rawUrl string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return nil, apierr.InvalidUrl.Wrap(err)
+	}
+	req.Header.Set("User-Agent", e.opts.UserAgent)
+	res, err := e.opts.Client.Do(req)
+	if err != nil {
+		return nil, apierr.Upstream(err)
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		res.Body.Close()
+		return nil, apierr.UpstreamStatus.WithMessage(fmt.Sprintf("upstream returned status %d", res.StatusCode))
+	}
+	return res, nil
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,204 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ogimg/pkg/apierr"
+)
+
+const testPage = `<!doctype html>
+<html><head>
+<title>Example</title>
+<meta name="description" content="plain description">
+<link rel="icon" href="/favicon.png">
+<meta property="og:image" content="/og.png">
+<meta name="twitter:image" content="https://cdn.example.net/tw.png">
+<link rel="image_src" href="img/src.png">
+<meta property="og:url" content="https://example.com/og-url">
+</head><body><meta property="og:image" content="/late.png"></body></html>`
+
+func TestParse(t *testing.T) {
+	meta, err := Parse(strings.NewReader(testPage), "https://example.com/blog/post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{
+		Url:         "https://example.com/blog/post",
+		Title:       "Example",
+		Description: "plain description",
+		Logo:        "https://example.com/favicon.png",
+		Image:       "https://example.com/og.png",
+		Canonical:   "https://example.com/og-url",
+	}
+	if meta.Url != want.Url || meta.Title != want.Title || meta.Description != want.Description ||
+		meta.Logo != want.Logo || meta.Image != want.Image || meta.Canonical != want.Canonical {
+		t.Errorf("Parse = %+v, want %+v", *meta, want)
+	}
+
+	candidates := []Candidate{
+		{"https://example.com/og.png", "og:image"},
+		{"https://cdn.example.net/tw.png", "twitter:image"},
+		{"https://example.com/blog/img/src.png", "link:image_src"},
+		{"https://example.com/late.png", "og:image"},
+	}
+	if len(meta.Images) != len(candidates) {
+		t.Fatalf("Images = %+v", meta.Images)
+	}
+	for i, c := range candidates {
+		if meta.Images[i] != c {
+			t.Errorf("Images[%d] = %+v, want %+v", i, meta.Images[i], c)
+		}
+	}
+}
+
+func TestParseCanonical(t *testing.T) {
+	page := `<html><head><link rel="canonical" href="/canonical"><meta property="og:url" content="https://example.com/og"></head></html>`
+	meta, err := Parse(strings.NewReader(page), "https://example.com/a?b=c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Canonical != "https://example.com/canonical" {
+		t.Errorf("Canonical = %q, link rel=canonical should win over og:url", meta.Canonical)
+	}
+
+	meta, _ = Parse(strings.NewReader(`<html><head><title>x</title></head></html>`), "https://example.com/")
+	if meta.Canonical != "" || meta.Image != "" || meta.Logo != "" || len(meta.Images) != 0 {
+		t.Errorf("empty page = %+v", *meta)
+	}
+}
+
+func TestParseOEmbed(t *testing.T) {
+	tests := []struct {
+		page string
+		want string
+	}{
+		{`<link rel="alternate" type="application/json+oembed" href="/oembed?format=json">`, "https://example.com/oembed?format=json"},
+		{`<link rel="Alternate" type=" Application/JSON+oEmbed " href="https://oembed.example.net/?u=1">`, "https://oembed.example.net/?u=1"},
+		// 只使用 JSON 格式，取第一个
+		{`<link rel="alternate" type="text/xml+oembed" href="/oembed.xml"><link rel="alternate" type="application/json+oembed" href="/a.json"><link rel="alternate" type="application/json+oembed" href="/b.json">`, "https://example.com/a.json"},
+		{`<link rel="alternate" type="application/rss+xml" href="/feed">`, ""},
+		{`<link rel="stylesheet" type="application/json+oembed" href="/x">`, ""},
+	}
+	for _, tt := range tests {
+		meta, err := Parse(strings.NewReader("<html><head>"+tt.page+"</head></html>"), "https://example.com/blog/post")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.OEmbed != tt.want {
+			t.Errorf("OEmbed for %s = %q, want %q", tt.page, meta.OEmbed, tt.want)
+		}
+	}
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractImage(t *testing.T) {
+	img := testPNG(t)
+	var userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			userAgent = r.UserAgent()
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(testPage))
+		case "/og.png":
+			// 未声明图片类型时按内容识别
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(img)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	e := NewExtractor(Options{UserAgent: "test-agent/1.0"})
+	meta, got, err := e.ExtractImage(context.Background(), srv.URL+"/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userAgent != "test-agent/1.0" {
+		t.Errorf("User-Agent = %q", userAgent)
+	}
+	if meta.Title != "Example" || meta.Image != srv.URL+"/og.png" {
+		t.Errorf("meta = %+v", meta)
+	}
+	if !bytes.Equal(got.Data, img) || got.ContentType != "image/png" {
+		t.Errorf("image = %s, %d bytes", got.ContentType, len(got.Data))
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		case "/no-image":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>x</title></head></html>`))
+		case "/text.png":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("not an image"))
+		case "/large.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(make([]byte, 1024))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	e := NewExtractor(Options{MaxImageSize: 512})
+	ctx := context.Background()
+	if _, err := e.Extract(ctx, srv.URL+"/missing"); !errors.Is(err, apierr.UpstreamStatus) {
+		t.Errorf("404 page: %v", err)
+	}
+	if _, err := e.Extract(ctx, srv.URL+"/json"); !errors.Is(err, apierr.UnsupportedType) {
+		t.Errorf("json page: %v", err)
+	}
+	if _, _, err := e.ExtractImage(ctx, srv.URL+"/no-image"); !errors.Is(err, apierr.NoImage) {
+		t.Errorf("page without og:image: %v", err)
+	}
+	if _, err := e.FetchImage(ctx, srv.URL+"/text.png"); !errors.Is(err, apierr.UnsupportedType) {
+		t.Errorf("text image: %v", err)
+	}
+	if _, err := e.FetchImage(ctx, srv.URL+"/large.png"); !errors.Is(err, apierr.TooLarge) {
+		t.Errorf("large image: %v", err)
+	}
+	if _, err := e.Extract(ctx, "://bad"); !errors.Is(err, apierr.InvalidUrl) {
+		t.Errorf("invalid url: %v", err)
+	}
+}
+
+func TestExtractCanceled(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewExtractor(Options{}).Extract(ctx, srv.URL); err == nil {
+		t.Fatal("expected error for canceled context")
+	}
+}
@@ -0,0 +1,245 @@
+package extract
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Metadata 页面的预览信息，所有 url 均已转换为绝对地址
+type Metadata struct {
+	Url         string      `json:"url"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Logo        string      `json:"logo"`
+	Image       string      `json:"image"`
+	Images      []Candidate `json:"images"`
+	Canonical   string      `json:"canonical"`
+	// OEmbed 页面中 link rel="alternate" 声明的 JSON oEmbed 地址
+	OEmbed string `json:"oembed,omitempty"`
+}
+
+// Candidate 页面中声明的候选图片，Source 为声明方式，例如 og:image、twitter:image
+type Candidate struct {
+	Url    string `json:"url"`
+	Source string `json:"source"`
+}
+
+// Parse 解析 HTML，baseUrl 用于把相对地址转换为绝对地址
+func Parse(r io.Reader, baseUrl string) (*Metadata, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNode(doc, baseUrl), nil
+}
+
+// ParseNode 从已解析的 HTML 文档中提取预览信息
+func ParseNode(doc *html.Node, baseUrl string) *Metadata {
+	base, _ := url.Parse(baseUrl)
+	meta := &Metadata{Url: baseUrl}
+
+	findWebSiteDesc(doc, meta)
+	meta.Logo = resolve(base, meta.Logo)
+	meta.Image = resolve(base, findMetaContent(doc, "og:image"))
+	for _, c := range findImageCandidates(doc) {
+		c.Url = resolve(base, c.Url)
+		meta.Images = append(meta.Images, c)
+	}
+	meta.Canonical = resolve(base, findCanonical(doc))
+	meta.OEmbed = resolve(base, findOEmbed(doc))
+	return meta
+}
+
+// findOEmbed 查找 oEmbed 发现链接，只使用 JSON 格式
+func findOEmbed(n *html.Node) string {
+	var href string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if href != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" && strings.EqualFold(attr(n, "rel"), "alternate") &&
+			strings.EqualFold(strings.TrimSpace(attr(n, "type")), "application/json+oembed") {
+			href = attr(n, "href")
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return href
+}
+
+// findCanonical 优先使用 link rel="canonical"，其次使用 og:url
+func findCanonical(n *html.Node) string {
+	var canonical string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if canonical != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" && strings.EqualFold(attr(n, "rel"), "canonical") {
+			canonical = attr(n, "href")
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	if canonical == "" {
+		canonical = findMetaContent(n, "og:url")
+	}
+	return canonical
+}
+
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || base == nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+func findMetaContent(n *html.Node, propertyValue string) string {
+	if n.Type == html.ElementNode && n.Data == "meta" {
+		var property, content string
+		for _, attr := range n.Attr {
+			if attr.Key == "property" {
+				property = attr.Val
+			}
+			if attr.Key == "content" {
+				content = attr.Val
+			}
+			if property == propertyValue && content != "" {
+				return content
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		result := findMetaContent(c, propertyValue)
+		if result != "" {
+			return result
+		}
+	}
+	return ""
+}
+
+// findImageCandidates 按文档顺序收集 og:image、twitter:image 和 image_src
+func findImageCandidates(n *html.Node) []Candidate {
+	var candidates []Candidate
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "meta":
+				key := strings.ToLower(attr(n, "property"))
+				if key == "" {
+					key = strings.ToLower(attr(n, "name"))
+				}
+				switch key {
+				case "og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src":
+					if content := attr(n, "content"); content != "" {
+						candidates = append(candidates, Candidate{Url: content, Source: key})
+					}
+				}
+			case "link":
+				if strings.EqualFold(attr(n, "rel"), "image_src") {
+					if href := attr(n, "href"); href != "" {
+						candidates = append(candidates, Candidate{Url: href, Source: "link:image_src"})
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return candidates
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func findWebSiteDesc(n *html.Node, desc *Metadata) {
+	// 找到 head 标签并遍历里面的内容
+	var headNode *html.Node
+	var findHead func(*html.Node) *html.Node
+	findHead = func(n *html.Node) *html.Node {
+		if n.Type == html.ElementNode && n.Data == "head" {
+			return n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if result := findHead(c); result != nil {
+				return result
+			}
+		}
+		return nil
+	}
+	headNode = findHead(n)
+	if headNode != nil {
+		for c := headNode.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				switch c.Data {
+				case "meta":
+					var isDescription bool
+
+					for _, attr := range c.Attr {
+						if (attr.Key == "name" && attr.Val == "description") ||
+							(attr.Key == "property" && attr.Val == "og:description") {
+							isDescription = true
+							break
+						}
+					}
+
+					if isDescription {
+						for _, attr := range c.Attr {
+							if attr.Key == "content" {
+								desc.Description = attr.Val
+								break
+							}
+						}
+					}
+				case "link":
+					var isIcon bool
+
+					for _, attr := range c.Attr {
+						if attr.Key == "rel" && attr.Val == "icon" {
+							isIcon = true
+							break
+						}
+					}
+
+					if isIcon {
+						for _, attr := range c.Attr {
+							if attr.Key == "href" {
+								desc.Logo = attr.Val
+								break
+							}
+						}
+					}
+				case "title":
+					if c.FirstChild != nil {
+						desc.Title = c.FirstChild.Data
+					}
+				}
+			}
+		}
+	}
+}
@@ -3,10 +3,12 @@ package resp
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"ogimg/pkg/apierr"
 )
 
 type response struct {
 	Code    int         `json:"code"`
+	Reason  string      `json:"reason,omitempty"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
 }
@@ -19,10 +21,14 @@ func HandleSuccess(ctx *gin.Context, data interface{}) {
 	ctx.JSON(http.StatusOK, resp)
 }
 
-func HandleError(ctx *gin.Context, httpCode, code int, message string, data interface{}) {
+// HandleAPIError 按错误码目录返回错误，HTTP 状态码由错误类型决定
+func HandleAPIError(ctx *gin.Context, err error, data interface{}) {
 	if data == nil {
 		data = map[string]string{}
 	}
-	resp := response{Code: code, Message: message, Data: data}
-	ctx.JSON(httpCode, resp)
+	e := apierr.From(err)
+	// 原始错误记录在访问日志中
+	ctx.Error(err)
+	resp := response{Code: e.Code, Reason: e.Reason, Message: e.Message, Data: data}
+	ctx.JSON(e.Status, resp)
 }
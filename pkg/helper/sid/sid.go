@@ -1,7 +1,10 @@
 package sid
 
 import (
+	"hash/fnv"
+	"net"
 	"ogimg/pkg/helper/convert"
+	"os"
 
 	"github.com/pkg/errors"
 	"github.com/sony/sonyflake"
@@ -12,12 +15,36 @@ type Sid struct {
 }
 
 func NewSid() *Sid {
-	sf := sonyflake.NewSonyflake(sonyflake.Settings{})
+	sf := sonyflake.NewSonyflake(sonyflake.Settings{MachineID: machineID})
 	if sf == nil {
 		panic("sonyflake not created")
 	}
 	return &Sid{sf}
 }
+
+// machineID 默认取私有 IPv4 地址的低 16 位，没有私有地址（例如只有公网 IP 的主机）时使用主机名的哈希
+func machineID() (uint16, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err == nil {
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() {
+				continue
+			}
+			if ip := ipNet.IP.To4(); ip != nil && ip.IsPrivate() {
+				return uint16(ip[2])<<8 + uint16(ip[3]), nil
+			}
+		}
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New32a()
+	h.Write([]byte(hostname))
+	return uint16(h.Sum32()), nil
+}
+
 func (s Sid) GenString() (string, error) {
 	// 生成分布式ID
 	id, err := s.sf.NextID()
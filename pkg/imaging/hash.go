@@ -0,0 +1,35 @@
+package imaging
+
+import (
+	"image"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// DHash 计算图片的感知哈希（dHash）：缩小为 9x8 灰度图后比较左右相邻像素的亮度。
+// 重新压缩、缩放后的同一张图片哈希基本不变
+func DHash(data []byte) (uint64, error) {
+	src, _, err := Decode(data)
+	if err != nil {
+		return 0, err
+	}
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// Distance 两个哈希的汉明距离，0 为相同，小于 10 通常是同一张图片的不同版本
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
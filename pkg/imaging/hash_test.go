@@ -0,0 +1,89 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// pattern 生成 width x height 的图片，flip 为 true 时左右翻转
+func pattern(width, height int, flip bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx := x
+			if flip {
+				fx = width - 1 - x
+			}
+			v := uint8((fx*255/width + (y*8/height)*30) % 256)
+			img.Set(x, y, color.RGBA{R: v, G: v / 2, B: 255 - v, A: 0xff})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func dhash(t *testing.T, data []byte) uint64 {
+	t.Helper()
+	hash, err := DHash(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestDHash(t *testing.T) {
+	original := dhash(t, encodePNG(t, pattern(400, 200, false)))
+
+	// 缩放和有损压缩后的同一张图片哈希接近
+	resized := dhash(t, encodePNG(t, pattern(1200, 600, false)))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, pattern(400, 200, false), &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	recompressed := dhash(t, buf.Bytes())
+	if d := Distance(original, resized); d >= 10 {
+		t.Errorf("resized distance = %d", d)
+	}
+	if d := Distance(original, recompressed); d >= 10 {
+		t.Errorf("recompressed distance = %d", d)
+	}
+
+	// 不同的图片距离大
+	flipped := dhash(t, encodePNG(t, pattern(400, 200, true)))
+	if d := Distance(original, flipped); d < 20 {
+		t.Errorf("different image distance = %d", d)
+	}
+
+	if _, err := DHash([]byte("<svg></svg>")); err == nil {
+		t.Error("expected an error for data that is not a raster image")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0xff, 0, 8},
+		{0xffffffffffffffff, 0, 64},
+		{0b1010, 0b0110, 2},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
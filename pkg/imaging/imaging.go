@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	FitContain = "contain"
+	FitCover   = "cover"
+
+	// MaxPixels 允许解码的最大像素数，解码后约占 200MB 内存
+	MaxPixels = 50_000_000
+	// MaxDimension 缩放后的最大宽高
+	MaxDimension = 4096
+)
+
+// ErrTooManyPixels 图片声明的尺寸超过 MaxPixels，文件很小的 png、gif 也可能声明极大的尺寸
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// ResizeOptions 宽高只填一个时按比例缩放，都填写时按 Fit 处理：
+// contain 保持比例缩放到宽高以内，cover 保持比例填满宽高并居中裁剪
+type ResizeOptions struct {
+	Width  int
+	Height int
+	Fit    string
+	// Format 输出格式 png 或 jpeg，为空时与原图一致（gif、webp 输出为 png）
+	Format string
+}
+
+// Resize 缩放图片，返回新的图片内容和 Content-Type
+func Resize(data []byte, opts ResizeOptions) ([]byte, string, error) {
+	src, format, err := Decode(data)
+	if err != nil {
+		return nil, "", err
+	}
+	dst := ResizeImage(src, opts)
+
+	if opts.Format != "" {
+		format = opts.Format
+	}
+	return Encode(dst, format)
+}
+
+// Decode 先读取图片头中的尺寸，超过 MaxPixels 时不解码
+func Decode(data []byte) (image.Image, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, "", fmt.Errorf("%w: %dx%d", ErrTooManyPixels, config.Width, config.Height)
+	}
+	return image.Decode(bytes.NewReader(data))
+}
+
+// ResizeImage 按 opts 缩放已解码的图片
+func ResizeImage(src image.Image, opts ResizeOptions) image.Image {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	w, h := opts.Width, opts.Height
+	if sw == 0 || sh == 0 || (w <= 0 && h <= 0) {
+		return src
+	}
+
+	srcRect := src.Bounds()
+	switch {
+	case w <= 0:
+		w = sw * h / sh
+	case h <= 0:
+		h = sh * w / sw
+	case opts.Fit == FitCover:
+		// 按目标比例从原图中间裁剪
+		if sw*h > sh*w {
+			cw := sh * w / h
+			x := srcRect.Min.X + (sw-cw)/2
+			srcRect = image.Rect(x, srcRect.Min.Y, x+cw, srcRect.Max.Y)
+		} else {
+			ch := sw * h / w
+			y := srcRect.Min.Y + (sh-ch)/2
+			srcRect = image.Rect(srcRect.Min.X, y, srcRect.Max.X, y+ch)
+		}
+	default:
+		if sw*h > sh*w {
+			h = sh * w / sw
+		} else {
+			w = sw * h / sh
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, srcRect, draw.Over, nil)
+	return dst
+}
+
+// Encode 编码为 png 或 jpeg，其它格式输出为 png
+func Encode(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg", "jpg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	case "png", "gif", "webp", "":
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported image format %q", format)
+	}
+}
@@ -0,0 +1,80 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// bomb 生成文件很小、但声明了 width x height 画布的 gif
+func bomb(t *testing.T, width, height uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), []color.Color{color.Black}), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// 逻辑屏幕宽高位于 GIF89a 之后，小端序
+	binary.LittleEndian.PutUint16(data[6:], width)
+	binary.LittleEndian.PutUint16(data[8:], height)
+	return data
+}
+
+func TestResize(t *testing.T) {
+	src := encodePNG(t, pattern(400, 200, false))
+	tests := []struct {
+		opts          ResizeOptions
+		width, height int
+	}{
+		{ResizeOptions{Width: 100}, 100, 50},
+		{ResizeOptions{Height: 100}, 200, 100},
+		{ResizeOptions{Width: 100, Height: 100, Fit: FitContain}, 100, 50},
+		{ResizeOptions{Width: 100, Height: 100, Fit: FitCover}, 100, 100},
+		{ResizeOptions{Width: 100, Height: 100}, 100, 50},
+	}
+	for _, tt := range tests {
+		data, contentType, err := Resize(src, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Width != tt.width || config.Height != tt.height || format != "png" || contentType != "image/png" {
+			t.Errorf("%+v: got %dx%d %s %s, want %dx%d", tt.opts, config.Width, config.Height, format, contentType, tt.width, tt.height)
+		}
+	}
+
+	if _, contentType, err := Resize(src, ResizeOptions{Width: 100, Format: "jpeg"}); err != nil || contentType != "image/jpeg" {
+		t.Errorf("jpeg output = %s, %v", contentType, err)
+	}
+	if _, _, err := Resize(src, ResizeOptions{Width: 100, Format: "bmp"}); err == nil {
+		t.Error("expected an error for an unsupported output format")
+	}
+}
+
+func TestDecodePixelLimit(t *testing.T) {
+	data := bomb(t, 65535, 65535)
+	if len(data) > 100 {
+		t.Fatalf("bomb is %d bytes", len(data))
+	}
+	if _, _, err := Decode(data); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Decode err = %v", err)
+	}
+	if _, _, err := Resize(data, ResizeOptions{Width: 100}); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Resize err = %v", err)
+	}
+	if _, err := DHash(data); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("DHash err = %v", err)
+	}
+
+	// 上限以内的图片正常解码
+	if _, format, err := Decode(bomb(t, 300, 200)); err != nil || format != "gif" {
+		t.Errorf("Decode = %s %v", format, err)
+	}
+}
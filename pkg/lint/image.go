@@ -0,0 +1,194 @@
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"mime"
+	"strconv"
+
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	RecommendedWidth  = 1200
+	RecommendedHeight = 630
+	// 小于该尺寸时 facebook 不展示图片
+	MinWidth  = 200
+	MinHeight = 200
+	// 小于该尺寸时大图卡片退化为小图
+	LargeMinWidth  = 600
+	LargeMinHeight = 315
+	// twitter 限制 5MB，facebook 限制 8MB
+	WarnSize  = 5 << 20
+	ErrorSize = 8 << 20
+)
+
+// ImageInfo 预览图片的实际情况
+type ImageInfo struct {
+	Url         string  `json:"url"`
+	Reachable   bool    `json:"reachable"`
+	ContentType string  `json:"content_type,omitempty"`
+	Format      string  `json:"format,omitempty"`
+	Size        int     `json:"size,omitempty"`
+	Width       int     `json:"width,omitempty"`
+	Height      int     `json:"height,omitempty"`
+	AspectRatio float64 `json:"aspect_ratio,omitempty"`
+}
+
+// CheckImage 检查下载到的图片，fetchErr 为 FetchImage 返回的错误
+func CheckImage(p *Page, imageUrl string, img *extract.Image, fetchErr error) (*ImageInfo, []Issue) {
+	info := &ImageInfo{Url: imageUrl}
+	if fetchErr != nil {
+		return info, []Issue{fetchIssue(fetchErr)}
+	}
+	info.Reachable = true
+	info.ContentType = img.ContentType
+	info.Size = len(img.Data)
+
+	var issues []Issue
+	switch {
+	case info.Size > ErrorSize:
+		issues = append(issues, Issue{
+			Rule:     "image_size",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("image is %d bytes, facebook rejects images over %d bytes", info.Size, ErrorSize),
+			Property: "og:image",
+		})
+	case info.Size > WarnSize:
+		issues = append(issues, Issue{
+			Rule:     "image_size",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("image is %d bytes, twitter rejects images over %d bytes", info.Size, WarnSize),
+			Property: "og:image",
+		})
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		issues = append(issues, Issue{
+			Rule:     "image_format",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("image %s is not jpeg, png, gif or webp", img.ContentType),
+			Property: "og:image",
+		})
+		return info, issues
+	}
+	info.Format = format
+	info.Width = config.Width
+	info.Height = config.Height
+	if config.Height > 0 {
+		info.AspectRatio = float64(config.Width) / float64(config.Height)
+	}
+
+	issues = append(issues, checkContentType(p, img.ContentType, format)...)
+	issues = append(issues, checkDimensions(p, config.Width, config.Height)...)
+	return info, issues
+}
+
+func fetchIssue(err error) Issue {
+	message := apierr.From(err).Message
+	issue := Issue{
+		Rule:     "image_unreachable",
+		Severity: SeverityError,
+		Message:  "image cannot be fetched: " + message,
+		Property: "og:image",
+	}
+	switch {
+	case errors.Is(err, apierr.TooLarge):
+		issue.Rule = "image_size"
+		issue.Message = message
+	case errors.Is(err, apierr.UnsupportedType):
+		issue.Rule = "content_type"
+		issue.Message = "image url does not serve an image: " + message
+	}
+	return issue
+}
+
+// checkContentType 比较响应头、og:image:type 与图片实际格式
+func checkContentType(p *Page, contentType, format string) []Issue {
+	actual := "image/" + format
+	var issues []Issue
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != actual {
+		issues = append(issues, Issue{
+			Rule:     "content_type",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("image is served as %s but is %s", mediaType, actual),
+			Property: "og:image",
+		})
+	}
+	if declared := p.Get("og:image:type"); declared != "" && declared != actual {
+		issues = append(issues, Issue{
+			Rule:     "content_type",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("og:image:type is %s but image is %s", declared, actual),
+			Property: "og:image:type",
+		})
+	}
+	return issues
+}
+
+func checkDimensions(p *Page, width, height int) []Issue {
+	var issues []Issue
+	switch {
+	case width < MinWidth || height < MinHeight:
+		issues = append(issues, Issue{
+			Rule:     "image_dimensions",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("image is %dx%d, smaller than the %dx%d minimum", width, height, MinWidth, MinHeight),
+			Property: "og:image",
+		})
+	case width < LargeMinWidth || height < LargeMinHeight:
+		issues = append(issues, Issue{
+			Rule:     "image_dimensions",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("image is %dx%d, large previews need at least %dx%d", width, height, LargeMinWidth, LargeMinHeight),
+			Property: "og:image",
+		})
+	case width < RecommendedWidth || height < RecommendedHeight:
+		issues = append(issues, Issue{
+			Rule:     "image_dimensions",
+			Severity: SeverityInfo,
+			Message:  fmt.Sprintf("image is %dx%d, %dx%d is recommended for high resolution displays", width, height, RecommendedWidth, RecommendedHeight),
+			Property: "og:image",
+		})
+	}
+
+	// 偏离 1.91:1 超过 5% 时各平台会裁剪
+	target := float64(RecommendedWidth) / float64(RecommendedHeight)
+	if height > 0 && aspectOff(width, height, target) > 0.05 {
+		issues = append(issues, Issue{
+			Rule:     "image_aspect_ratio",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("image aspect ratio is %.2f:1, platforms crop to 1.91:1", float64(width)/float64(height)),
+			Property: "og:image",
+		})
+	}
+
+	for _, d := range []struct {
+		key    string
+		actual int
+	}{{"og:image:width", width}, {"og:image:height", height}} {
+		value := p.Get(d.key)
+		if value == "" {
+			continue
+		}
+		declared, err := strconv.Atoi(value)
+		if err != nil || declared != d.actual {
+			issues = append(issues, Issue{
+				Rule:     "image_dimensions",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%s is %q but image is %d", d.key, value, d.actual),
+				Property: d.key,
+			})
+		}
+	}
+	return issues
+}
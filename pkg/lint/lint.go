@@ -0,0 +1,176 @@
+// Package lint 检查页面的 Open Graph 和 Twitter Card 标签，找出会导致分享预览异常的问题
+package lint
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Issue 一条检查结果，Rule 为机器可读的规则名
+type Issue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Property string `json:"property,omitempty"`
+	Platform string `json:"platform,omitempty"`
+}
+
+// Report 检查报告，没有 error 级别的问题时 Valid 为 true
+type Report struct {
+	Url      string     `json:"url"`
+	Valid    bool       `json:"valid"`
+	Errors   int        `json:"errors"`
+	Warnings int        `json:"warnings"`
+	Infos    int        `json:"infos"`
+	Issues   []Issue    `json:"issues"`
+	Image    *ImageInfo `json:"image,omitempty"`
+}
+
+// NewReport 汇总各项检查的结果
+func NewReport(pageUrl string, issues []Issue, image *ImageInfo) *Report {
+	report := &Report{Url: pageUrl, Issues: []Issue{}, Image: image}
+	for _, issue := range issues {
+		switch issue.Severity {
+		case SeverityError:
+			report.Errors++
+		case SeverityWarning:
+			report.Warnings++
+		default:
+			report.Infos++
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+	report.Valid = report.Errors == 0
+	return report
+}
+
+// Tag 页面中的一个 meta 标签，Key 为小写的 property 或 name
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Page 检查所需的页面内容
+type Page struct {
+	Url       string
+	Tags      []Tag
+	Titles    []string
+	Canonical string
+}
+
+// Parse 收集页面中所有带 content 的 meta 标签、title 和 canonical 链接
+func Parse(doc *html.Node, pageUrl string) *Page {
+	page := &Page{Url: pageUrl}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "meta":
+				key := attr(n, "property")
+				if key == "" {
+					key = attr(n, "name")
+				}
+				if content, ok := attrOk(n, "content"); key != "" && ok {
+					page.Tags = append(page.Tags, Tag{Key: strings.ToLower(strings.TrimSpace(key)), Value: strings.TrimSpace(content)})
+				}
+			case "title":
+				// svg 中的 title 不是页面标题
+				if n.Parent == nil || n.Parent.Data != "svg" {
+					page.Titles = append(page.Titles, strings.TrimSpace(text(n)))
+				}
+			case "link":
+				if strings.EqualFold(attr(n, "rel"), "canonical") && page.Canonical == "" {
+					page.Canonical = attr(n, "href")
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return page
+}
+
+// Get 返回第一个 key 标签的值
+func (p *Page) Get(key string) string {
+	for _, tag := range p.Tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// Count 返回 key 标签出现的次数
+func (p *Page) Count(key string) int {
+	n := 0
+	for _, tag := range p.Tags {
+		if tag.Key == key {
+			n++
+		}
+	}
+	return n
+}
+
+// Title 返回第一个 title 标签的内容
+func (p *Page) Title() string {
+	if len(p.Titles) == 0 {
+		return ""
+	}
+	return p.Titles[0]
+}
+
+// ImageUrl 返回平台实际使用的图片地址：优先 og:image，其次 twitter:image，按页面地址转换为绝对地址
+func (p *Page) ImageUrl() string {
+	ref := p.Get("og:image")
+	if ref == "" {
+		ref = p.Get("twitter:image")
+	}
+	return p.Resolve(ref)
+}
+
+// Resolve 按页面地址把 ref 转换为绝对地址
+func (p *Page) Resolve(ref string) string {
+	base, err := url.Parse(p.Url)
+	if ref == "" || err != nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := attrOk(n, key)
+	return v
+}
+
+func attrOk(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func text(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return b.String()
+}
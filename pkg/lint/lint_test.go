@@ -0,0 +1,294 @@
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+
+	"golang.org/x/net/html"
+)
+
+const completePage = `<html><head>
+<title>Example page</title>
+<link rel="canonical" href="/post">
+<meta property="og:title" content="Example page">
+<meta property="og:type" content="article">
+<meta property="og:url" content="https://example.com/post">
+<meta property="og:image" content="https://example.com/cover.png">
+<meta property="og:image:alt" content="Cover">
+<meta property="og:description" content="An example page">
+<meta property="og:site_name" content="Example">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:title" content="Example page">
+<meta name="twitter:description" content="An example page">
+<meta name="twitter:image" content="https://example.com/cover.png">
+</head>
+<body><svg><title>icon</title></svg></body></html>`
+
+func parse(t *testing.T, body string) *Page {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Parse(doc, "https://example.com/post")
+}
+
+// page 在 completePage 的基础上删除 remove 中的行、追加 extra
+func page(t *testing.T, remove []string, extra ...string) *Page {
+	t.Helper()
+	var lines []string
+	for _, line := range strings.Split(completePage, "\n") {
+		keep := true
+		for _, r := range remove {
+			if strings.Contains(line, r) {
+				keep = false
+			}
+		}
+		if keep {
+			lines = append(lines, line)
+		}
+	}
+	body := strings.Join(lines, "\n")
+	body = strings.Replace(body, "</head>", strings.Join(extra, "\n")+"</head>", 1)
+	return parse(t, body)
+}
+
+// find 返回规则和属性都匹配的问题
+func find(issues []Issue, rule, property string) *Issue {
+	for i := range issues {
+		if issues[i].Rule == rule && issues[i].Property == property {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+func expect(t *testing.T, issues []Issue, rule, property, severity string) {
+	t.Helper()
+	issue := find(issues, rule, property)
+	if issue == nil {
+		t.Errorf("missing %s issue for %s in %+v", rule, property, issues)
+		return
+	}
+	if issue.Severity != severity {
+		t.Errorf("%s %s severity = %s, want %s", rule, property, issue.Severity, severity)
+	}
+}
+
+func TestParse(t *testing.T) {
+	p := parse(t, completePage)
+	if got := p.Title(); got != "Example page" || len(p.Titles) != 1 {
+		t.Errorf("titles = %q, svg title must be ignored", p.Titles)
+	}
+	if p.Canonical != "/post" {
+		t.Errorf("canonical = %q", p.Canonical)
+	}
+	if got := p.Get("twitter:card"); got != "summary_large_image" {
+		t.Errorf("twitter:card = %q", got)
+	}
+	if got := p.ImageUrl(); got != "https://example.com/cover.png" {
+		t.Errorf("image url = %q", got)
+	}
+
+	p = parse(t, `<meta name="Twitter:Image" content=" /a.png "><meta property="og:title">`)
+	if got := p.ImageUrl(); got != "https://example.com/a.png" {
+		t.Errorf("twitter image url = %q", got)
+	}
+	if p.Count("og:title") != 0 {
+		t.Error("meta without content must be skipped")
+	}
+}
+
+func TestCheckPageComplete(t *testing.T) {
+	if issues := CheckPage(parse(t, completePage)); len(issues) != 0 {
+		t.Errorf("issues = %+v", issues)
+	}
+}
+
+func TestCheckRequired(t *testing.T) {
+	issues := CheckPage(page(t, []string{"og:title", "og:description", "og:site_name", "og:image:alt"}))
+	expect(t, issues, "og_required", "og:title", SeverityError)
+	expect(t, issues, "og_recommended", "og:description", SeverityWarning)
+	expect(t, issues, "og_recommended", "og:site_name", SeverityInfo)
+	expect(t, issues, "og_recommended", "og:image:alt", SeverityInfo)
+	if find(issues, "og_required", "og:type") != nil {
+		t.Error("og:type is present")
+	}
+
+	report := NewReport("https://example.com/post", issues, nil)
+	if report.Valid || report.Errors != 1 || report.Warnings != 1 || report.Infos != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	if report := NewReport("https://example.com/post", nil, nil); !report.Valid || report.Issues == nil {
+		t.Errorf("empty report = %+v", report)
+	}
+}
+
+func TestCheckTwitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		remove   []string
+		extra    []string
+		rule     string
+		property string
+		severity string
+	}{
+		{"missing card", []string{"twitter:card"}, nil, "twitter_card", "twitter:card", SeverityWarning},
+		{"invalid card", []string{"twitter:card"}, []string{`<meta name="twitter:card" content="gallery">`}, "twitter_card", "twitter:card", SeverityError},
+		{"fallback", []string{"twitter:title"}, nil, "twitter_fallback", "twitter:title", SeverityInfo},
+		{"no title", []string{"twitter:title", "og:title"}, nil, "twitter_incomplete", "twitter:title", SeverityError},
+		{"large card without image", []string{"twitter:image", "og:image"}, nil, "twitter_incomplete", "twitter:image", SeverityError},
+		{"summary without image", []string{"twitter:image", "og:image", "twitter:card"}, []string{`<meta name="twitter:card" content="summary">`}, "twitter_incomplete", "twitter:image", SeverityWarning},
+		{"player", []string{"twitter:card"}, []string{`<meta name="twitter:card" content="player">`, `<meta name="twitter:player" content="https://example.com/embed">`}, "twitter_incomplete", "twitter:player:width", SeverityError},
+		{"app", []string{"twitter:card"}, []string{`<meta name="twitter:card" content="app">`}, "twitter_incomplete", "twitter:app:id:iphone", SeverityError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, checkTwitter(page(t, tt.remove, tt.extra...)), tt.rule, tt.property, tt.severity)
+		})
+	}
+
+	issues := checkTwitter(page(t, []string{"twitter:card"}, `<meta name="twitter:card" content="player">`, `<meta name="twitter:player" content="https://example.com/embed">`))
+	if find(issues, "twitter_incomplete", "twitter:player") != nil {
+		t.Error("twitter:player is present")
+	}
+}
+
+func TestCheckUrls(t *testing.T) {
+	tests := []struct {
+		value    string
+		rule     string
+		severity string
+	}{
+		{"/cover.png", "relative_url", SeverityError},
+		{"//cdn.example.com/cover.png", "relative_url", SeverityWarning},
+		{"ftp://example.com/cover.png", "invalid_url", SeverityError},
+		{"http://example.com/%zz", "invalid_url", SeverityError},
+		{"http://example.com/cover.png", "insecure_url", SeverityInfo},
+	}
+	for _, tt := range tests {
+		p := page(t, []string{"og:image\""}, `<meta property="og:image" content="`+tt.value+`">`)
+		expect(t, checkUrls(p), tt.rule, "og:image", tt.severity)
+	}
+
+	// og:url 使用 http 不提示
+	p := page(t, []string{"og:url"}, `<meta property="og:url" content="http://example.com/post">`)
+	if issue := find(checkUrls(p), "insecure_url", "og:url"); issue != nil {
+		t.Errorf("unexpected %+v", issue)
+	}
+	expect(t, checkUrls(p), "canonical_mismatch", "og:url", SeverityInfo)
+
+	// 相对地址的 canonical 按页面地址解析后相同
+	if issues := checkUrls(parse(t, completePage)); len(issues) != 0 {
+		t.Errorf("issues = %+v", issues)
+	}
+}
+
+func TestCheckDuplicates(t *testing.T) {
+	p := page(t, nil, `<meta property="og:title" content="Other">`, `<title>Other</title>`)
+	issues := checkDuplicates(p)
+	expect(t, issues, "duplicate_tag", "og:title", SeverityWarning)
+	expect(t, issues, "duplicate_tag", "title", SeverityWarning)
+	if len(issues) != 2 {
+		t.Errorf("issues = %+v", issues)
+	}
+	// og:image 可以出现多次
+	if issues := checkDuplicates(page(t, nil, `<meta property="og:image" content="https://example.com/b.png">`)); len(issues) != 0 {
+		t.Errorf("issues = %+v", issues)
+	}
+}
+
+func TestCheckLengths(t *testing.T) {
+	// 65 个字符的标题只超过 google 的限制，按字符而不是字节计数
+	title := strings.Repeat("标", 65)
+	p := page(t, []string{"<title>", "og:title", "twitter:title"},
+		`<title>`+title+`</title>`, `<meta property="og:title" content="short">`)
+	issues := checkLengths(p)
+	if len(issues) != 1 || issues[0].Rule != "title_length" || issues[0].Platform != "google" {
+		t.Errorf("issues = %+v", issues)
+	}
+
+	// twitter 优先使用 twitter:description
+	description := strings.Repeat("d", 250)
+	p = page(t, []string{"twitter:description"}, `<meta name="twitter:description" content="`+description+`">`)
+	issues = checkLengths(p)
+	if len(issues) != 1 || issues[0].Rule != "description_length" || issues[0].Platform != "twitter" {
+		t.Errorf("issues = %+v", issues)
+	}
+}
+
+func testImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCheckImage(t *testing.T) {
+	p := parse(t, completePage)
+	info, issues := CheckImage(p, "https://example.com/cover.png", &extract.Image{Data: testImage(t, 1200, 630), ContentType: "image/png"}, nil)
+	if len(issues) != 0 {
+		t.Errorf("issues = %+v", issues)
+	}
+	if !info.Reachable || info.Format != "png" || info.Width != 1200 || info.Height != 630 {
+		t.Errorf("info = %+v", info)
+	}
+
+	tests := []struct {
+		name          string
+		width, height int
+		contentType   string
+		extra         []string
+		rule          string
+		property      string
+		severity      string
+	}{
+		{"too small", 100, 100, "image/png", nil, "image_dimensions", "og:image", SeverityError},
+		{"small", 400, 210, "image/png", nil, "image_dimensions", "og:image", SeverityWarning},
+		{"low resolution", 800, 420, "image/png", nil, "image_dimensions", "og:image", SeverityInfo},
+		{"square", 1200, 1200, "image/png", nil, "image_aspect_ratio", "og:image", SeverityWarning},
+		{"wrong content type", 1200, 630, "image/jpeg", nil, "content_type", "og:image", SeverityWarning},
+		{"wrong declared type", 1200, 630, "image/png", []string{`<meta property="og:image:type" content="image/jpeg">`}, "content_type", "og:image:type", SeverityWarning},
+		{"wrong declared width", 1200, 630, "image/png", []string{`<meta property="og:image:width" content="1000">`}, "image_dimensions", "og:image:width", SeverityWarning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, issues := CheckImage(page(t, nil, tt.extra...), "", &extract.Image{Data: testImage(t, tt.width, tt.height), ContentType: tt.contentType}, nil)
+			expect(t, issues, tt.rule, tt.property, tt.severity)
+		})
+	}
+
+	info, issues = CheckImage(p, "", &extract.Image{Data: []byte("<svg></svg>"), ContentType: "image/svg+xml"}, nil)
+	expect(t, issues, "image_format", "og:image", SeverityError)
+	if !info.Reachable || info.Format != "" {
+		t.Errorf("info = %+v", info)
+	}
+}
+
+func TestCheckImageFetchError(t *testing.T) {
+	p := parse(t, completePage)
+	tests := []struct {
+		err  error
+		rule string
+	}{
+		{errors.New("connection refused"), "image_unreachable"},
+		{apierr.TooLarge, "image_size"},
+		{apierr.UnsupportedType, "content_type"},
+	}
+	for _, tt := range tests {
+		info, issues := CheckImage(p, "https://example.com/cover.png", nil, tt.err)
+		if info.Reachable || len(issues) != 1 {
+			t.Fatalf("%v: info = %+v, issues = %+v", tt.err, info, issues)
+		}
+		expect(t, issues, tt.rule, "og:image", SeverityError)
+	}
+}
@@ -0,0 +1,300 @@
+package lint
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// 必须的 Open Graph 属性，缺少时部分平台不展示预览
+var requiredOG = []string{"og:title", "og:type", "og:image", "og:url"}
+
+// 只应出现一次的标签，重复时各平台取值不一致
+var singleTags = []string{
+	"og:title", "og:description", "og:type", "og:url", "og:site_name",
+	"twitter:card", "twitter:title", "twitter:description", "twitter:site",
+	"description",
+}
+
+// 值必须是绝对地址的标签
+var urlTags = []string{"og:image", "og:image:url", "og:image:secure_url", "og:url", "twitter:image"}
+
+var twitterCards = map[string]bool{"summary": true, "summary_large_image": true, "app": true, "player": true}
+
+// Platform 各平台开始截断标题和描述的长度（按字符计），为经验值
+type Platform struct {
+	Name           string
+	TitleMax       int
+	DescriptionMax int
+}
+
+var Platforms = []Platform{
+	{Name: "google", TitleMax: 60, DescriptionMax: 160},
+	{Name: "facebook", TitleMax: 88, DescriptionMax: 200},
+	{Name: "twitter", TitleMax: 70, DescriptionMax: 200},
+	{Name: "linkedin", TitleMax: 120, DescriptionMax: 160},
+	{Name: "slack", TitleMax: 150, DescriptionMax: 300},
+}
+
+// CheckPage 检查页面标签，不包括需要下载图片的规则
+func CheckPage(p *Page) []Issue {
+	var issues []Issue
+	issues = append(issues, checkRequired(p)...)
+	issues = append(issues, checkTwitter(p)...)
+	issues = append(issues, checkUrls(p)...)
+	issues = append(issues, checkDuplicates(p)...)
+	issues = append(issues, checkLengths(p)...)
+	return issues
+}
+
+func checkRequired(p *Page) []Issue {
+	var issues []Issue
+	for _, key := range requiredOG {
+		if p.Get(key) == "" {
+			issues = append(issues, Issue{
+				Rule:     "og_required",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("missing required property %s", key),
+				Property: key,
+			})
+		}
+	}
+	if p.Get("og:description") == "" {
+		issues = append(issues, Issue{
+			Rule:     "og_recommended",
+			Severity: SeverityWarning,
+			Message:  "missing og:description, platforms fall back to page text or show none",
+			Property: "og:description",
+		})
+	}
+	if p.Get("og:site_name") == "" {
+		issues = append(issues, Issue{
+			Rule:     "og_recommended",
+			Severity: SeverityInfo,
+			Message:  "missing og:site_name",
+			Property: "og:site_name",
+		})
+	}
+	if p.Get("og:image") != "" && p.Get("og:image:alt") == "" {
+		issues = append(issues, Issue{
+			Rule:     "og_recommended",
+			Severity: SeverityInfo,
+			Message:  "missing og:image:alt, screen readers cannot describe the image",
+			Property: "og:image:alt",
+		})
+	}
+	return issues
+}
+
+func checkTwitter(p *Page) []Issue {
+	card := p.Get("twitter:card")
+	if card == "" {
+		return []Issue{{
+			Rule:     "twitter_card",
+			Severity: SeverityWarning,
+			Message:  "missing twitter:card, twitter shows a summary card",
+			Property: "twitter:card",
+		}}
+	}
+	if !twitterCards[card] {
+		return []Issue{{
+			Rule:     "twitter_card",
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("invalid twitter:card %q, expected summary, summary_large_image, app or player", card),
+			Property: "twitter:card",
+		}}
+	}
+
+	var issues []Issue
+	// twitter 缺少 twitter:title 等标签时使用对应的 og 标签
+	for _, f := range []struct{ key, fallback string }{
+		{"twitter:title", "og:title"},
+		{"twitter:description", "og:description"},
+		{"twitter:image", "og:image"},
+	} {
+		if p.Get(f.key) != "" {
+			continue
+		}
+		if p.Get(f.fallback) != "" {
+			issues = append(issues, Issue{
+				Rule:     "twitter_fallback",
+				Severity: SeverityInfo,
+				Message:  fmt.Sprintf("missing %s, twitter uses %s", f.key, f.fallback),
+				Property: f.key,
+			})
+			continue
+		}
+		severity := SeverityWarning
+		// 大图卡片没有图片时不展示
+		if f.key == "twitter:image" && card == "summary_large_image" {
+			severity = SeverityError
+		}
+		if f.key == "twitter:title" {
+			severity = SeverityError
+		}
+		issues = append(issues, Issue{
+			Rule:     "twitter_incomplete",
+			Severity: severity,
+			Message:  fmt.Sprintf("%s card is missing %s and %s", card, f.key, f.fallback),
+			Property: f.key,
+		})
+	}
+	if card == "player" {
+		for _, key := range []string{"twitter:player", "twitter:player:width", "twitter:player:height"} {
+			if p.Get(key) == "" {
+				issues = append(issues, Issue{
+					Rule:     "twitter_incomplete",
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("player card is missing %s", key),
+					Property: key,
+				})
+			}
+		}
+	}
+	if card == "app" && p.Get("twitter:app:id:iphone") == "" && p.Get("twitter:app:id:googleplay") == "" {
+		issues = append(issues, Issue{
+			Rule:     "twitter_incomplete",
+			Severity: SeverityError,
+			Message:  "app card is missing twitter:app:id:iphone or twitter:app:id:googleplay",
+			Property: "twitter:app:id:iphone",
+		})
+	}
+	return issues
+}
+
+// checkUrls 协议要求 url 类标签使用绝对地址，相对地址部分平台无法解析
+func checkUrls(p *Page) []Issue {
+	var issues []Issue
+	for _, key := range urlTags {
+		for _, tag := range p.Tags {
+			if tag.Key != key || tag.Value == "" {
+				continue
+			}
+			u, err := url.Parse(tag.Value)
+			switch {
+			case err != nil:
+				issues = append(issues, Issue{
+					Rule:     "invalid_url",
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("%s is not a valid url: %q", key, tag.Value),
+					Property: key,
+				})
+			case strings.HasPrefix(tag.Value, "//"):
+				issues = append(issues, Issue{
+					Rule:     "relative_url",
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("%s is protocol-relative, use an absolute https url: %q", key, tag.Value),
+					Property: key,
+				})
+			case !u.IsAbs():
+				issues = append(issues, Issue{
+					Rule:     "relative_url",
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("%s must be an absolute url, resolves to %s: %q", key, p.Resolve(tag.Value), tag.Value),
+					Property: key,
+				})
+			case u.Scheme != "http" && u.Scheme != "https":
+				issues = append(issues, Issue{
+					Rule:     "invalid_url",
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("%s must use http or https: %q", key, tag.Value),
+					Property: key,
+				})
+			case u.Scheme == "http" && key != "og:url":
+				issues = append(issues, Issue{
+					Rule:     "insecure_url",
+					Severity: SeverityInfo,
+					Message:  fmt.Sprintf("%s uses http, some platforms only load https images", key),
+					Property: key,
+				})
+			}
+		}
+	}
+
+	ogUrl := p.Get("og:url")
+	if ogUrl != "" && p.Canonical != "" && p.Resolve(ogUrl) != p.Resolve(p.Canonical) {
+		issues = append(issues, Issue{
+			Rule:     "canonical_mismatch",
+			Severity: SeverityInfo,
+			Message:  fmt.Sprintf("og:url %s differs from canonical link %s", ogUrl, p.Resolve(p.Canonical)),
+			Property: "og:url",
+		})
+	}
+	return issues
+}
+
+func checkDuplicates(p *Page) []Issue {
+	var issues []Issue
+	for _, key := range singleTags {
+		if n := p.Count(key); n > 1 {
+			issues = append(issues, Issue{
+				Rule:     "duplicate_tag",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%s appears %d times, platforms may pick different values", key, n),
+				Property: key,
+			})
+		}
+	}
+	if n := len(p.Titles); n > 1 {
+		issues = append(issues, Issue{
+			Rule:     "duplicate_tag",
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("title element appears %d times", n),
+			Property: "title",
+		})
+	}
+	return issues
+}
+
+// checkLengths 按各平台的取值顺序取标题和描述，超过截断长度时提示
+func checkLengths(p *Page) []Issue {
+	var issues []Issue
+	for _, platform := range Platforms {
+		title, description := platformText(p, platform.Name)
+		if n := utf8.RuneCountInString(title); n > platform.TitleMax {
+			issues = append(issues, Issue{
+				Rule:     "title_length",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("title is %d characters, %s truncates after %d", n, platform.Name, platform.TitleMax),
+				Platform: platform.Name,
+			})
+		}
+		if n := utf8.RuneCountInString(description); n > platform.DescriptionMax {
+			issues = append(issues, Issue{
+				Rule:     "description_length",
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("description is %d characters, %s truncates after %d", n, platform.Name, platform.DescriptionMax),
+				Platform: platform.Name,
+			})
+		}
+	}
+	return issues
+}
+
+func platformText(p *Page, platform string) (title, description string) {
+	switch platform {
+	case "google":
+		return first(p.Title(), p.Get("og:title")), first(p.Get("description"), p.Get("og:description"))
+	case "twitter":
+		return first(p.Get("twitter:title"), p.Get("og:title"), p.Title()),
+			first(p.Get("twitter:description"), p.Get("og:description"), p.Get("description"))
+	default:
+		return first(p.Get("og:title"), p.Title()), first(p.Get("og:description"), p.Get("description"))
+	}
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// aspectOff 实际宽高比与 target 的相对偏差
+func aspectOff(width, height int, target float64) float64 {
+	return math.Abs(float64(width)/float64(height)-target) / target
+}
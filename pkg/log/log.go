@@ -1,44 +1,37 @@
 package log
 
 import (
+	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
+	"ogimg/pkg/config"
 	"os"
 	"time"
 )
 
 const LOGGER_KEY = "zapLogger"
 
+// ctxLoggerKey context.Context 中保存 zap 实例的键
+type ctxLoggerKey struct{}
+
 type Logger struct {
 	*zap.Logger
+	level zap.AtomicLevel
 }
 
-func NewLog(conf *viper.Viper) *Logger {
+func NewLog(conf *config.Config) *Logger {
 	return initZap(conf)
 }
 
-func initZap(conf *viper.Viper) *Logger {
+func initZap(conf *config.Config) *Logger {
 	// 日志地址 "out.log" 自定义
 	lp := conf.GetString("log.log_file_name")
 	// 日志级别 DEBUG,ERROR, INFO
 	lv := conf.GetString("log.log_level")
-	var level zapcore.Level
-	//debug<info<warn<error<fatal<panic
-	switch lv {
-	case "debug":
-		level = zap.DebugLevel
-	case "info":
-		level = zap.InfoLevel
-	case "warn":
-		level = zap.WarnLevel
-	case "error":
-		level = zap.ErrorLevel
-	default:
-		level = zap.InfoLevel
-	}
+	level := zap.NewAtomicLevelAt(parseLevel(lv))
 	hook := lumberjack.Logger{
 		Filename:   lp,                             // 日志文件路径
 		MaxSize:    conf.GetInt("log.max_size"),    // 每个日志文件保存的最大尺寸 单位：M
@@ -83,11 +76,48 @@ func initZap(conf *viper.Viper) *Logger {
 		zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout), zapcore.AddSync(&hook)), // 打印到控制台和文件
 		level, // 日志级别
 	)
+	// 每秒内同一条消息只输出前 initial 条，之后每 thereafter 条输出一条
+	if initial := conf.GetInt("log.sampling.initial"); initial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, initial, conf.GetInt("log.sampling.thereafter"))
+	}
 	if conf.GetString("env") != "prod" {
-		return &Logger{zap.New(core, zap.Development(), zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel))}
+		return &Logger{zap.New(core, zap.Development(), zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel)), level}
+	}
+	return &Logger{zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel)), level}
+
+}
+
+// parseLevel 解析日志级别，未知级别按 info 处理
+func parseLevel(lv string) zapcore.Level {
+	//debug<info<warn<error<fatal<panic
+	switch lv {
+	case "debug":
+		return zap.DebugLevel
+	case "info":
+		return zap.InfoLevel
+	case "warn":
+		return zap.WarnLevel
+	case "error":
+		return zap.ErrorLevel
+	default:
+		return zap.InfoLevel
 	}
-	return &Logger{zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel))}
+}
+
+// Level 返回当前日志级别
+func (l *Logger) Level() string {
+	return l.level.Level().String()
+}
 
+// SetLevel 修改日志级别，立即对所有派生的 logger 生效
+func (l *Logger) SetLevel(lv string) error {
+	switch lv {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("unknown log level %q", lv)
+	}
+	l.level.SetLevel(parseLevel(lv))
+	return nil
 }
 
 // 自定义时间编码器
@@ -96,20 +126,55 @@ func timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format("2006-01-02 15:04:05.000000000"))
 }
 
-// NewContext 给指定的context添加字段
+// NewContext 给指定的context添加字段，同时写入 ctx.Request 的 context，service 层拿到的 context 也能取出
 func (l *Logger) NewContext(ctx *gin.Context, fields ...zapcore.Field) {
-	ctx.Set(LOGGER_KEY, l.WithContext(ctx).With(fields...))
+	setContext(ctx, l.WithContext(ctx).With(fields...))
 }
 
-// WithContext 从指定的context返回一个zap实例
-func (l *Logger) WithContext(ctx *gin.Context) *Logger {
+// EnableDebug 让当前请求的 logger 输出所有级别的日志，且不受采样限制
+func (l *Logger) EnableDebug(ctx *gin.Context) {
+	setContext(ctx, l.WithContext(ctx).WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return debugCore{core}
+	})))
+}
+
+func setContext(ctx *gin.Context, zl *zap.Logger) {
+	ctx.Set(LOGGER_KEY, zl)
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), ctxLoggerKey{}, zl))
+}
+
+// debugCore 跳过被包装 core 的级别判断和采样，直接写入
+type debugCore struct {
+	zapcore.Core
+}
+
+func (c debugCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c debugCore) With(fields []zapcore.Field) zapcore.Core {
+	return debugCore{c.Core.With(fields)}
+}
+
+func (c debugCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+// ContextWith 返回添加了字段的 context，用于后台任务等没有 gin.Context 的场景
+func (l *Logger) ContextWith(ctx context.Context, fields ...zapcore.Field) context.Context {
+	return context.WithValue(ctx, ctxLoggerKey{}, l.WithContext(ctx).With(fields...))
+}
+
+// WithContext 从指定的context返回一个zap实例，*gin.Context 和 request context 均可
+func (l *Logger) WithContext(ctx context.Context) *Logger {
 	if ctx == nil {
 		return l
 	}
-	zl, _ := ctx.Get(LOGGER_KEY)
-	ctxLogger, ok := zl.(*zap.Logger)
-	if ok {
-		return &Logger{ctxLogger}
+	if zl, ok := ctx.Value(ctxLoggerKey{}).(*zap.Logger); ok {
+		return &Logger{zl, l.level}
+	}
+	if zl, ok := ctx.Value(LOGGER_KEY).(*zap.Logger); ok {
+		return &Logger{zl, l.level}
 	}
 	return l
 }
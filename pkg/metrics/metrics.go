@@ -0,0 +1,147 @@
+// Package metrics 定义 Prometheus 指标，注册在默认 registry 上，通过 Handler 暴露
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"ogimg/pkg/apierr"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+	CacheStale = "stale"
+
+	UpstreamCrawler = "crawler"
+	UpstreamRobots  = "robots"
+	UpstreamWebhook = "webhook"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ogimg_http_requests_total",
+		Help: "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ogimg_http_request_duration_seconds",
+		Help:    "HTTP request latency by route, method and status.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"route", "method", "status"})
+
+	HTTPResponseBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ogimg_http_response_bytes_total",
+		Help: "Bytes served by route.",
+	}, []string{"route"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ogimg_cache_requests_total",
+		Help: "Cache lookups by kind (image, desc, icon) and result (hit, miss, stale, error).",
+	}, []string{"kind", "result"})
+
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ogimg_upstream_fetch_duration_seconds",
+		Help:    "Time until upstream response headers, by target and outcome (ok or the error reason).",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"target", "outcome"})
+
+	UpstreamInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ogimg_upstream_in_flight",
+		Help: "Upstream fetches in flight by target.",
+	}, []string{"target"})
+
+	RedisDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ogimg_redis_duration_seconds",
+		Help:    "Redis command latency by command and result.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, 1},
+	}, []string{"command", "result"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveCache 记录一次缓存查询
+func ObserveCache(kind, result string) {
+	CacheRequests.WithLabelValues(kind, result).Inc()
+}
+
+// Transport 统计上游请求的耗时、进行中的数量和错误类型，next 为 nil 时使用 http.DefaultTransport
+func Transport(target string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{target: target, next: next}
+}
+
+type transport struct {
+	target string
+	next   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	inFlight := UpstreamInFlight.WithLabelValues(t.target)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	outcome := "ok"
+	if err != nil {
+		outcome = apierr.Upstream(err).Reason
+	} else if res.StatusCode >= http.StatusBadRequest {
+		outcome = apierr.UpstreamStatus.Reason
+	}
+	UpstreamDuration.WithLabelValues(t.target, outcome).Observe(time.Since(start).Seconds())
+	return res, err
+}
+
+type redisStartKey struct{}
+
+// RedisHook 统计 redis 命令耗时，pipeline 按 "pipeline" 计一次
+type RedisHook struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	observeRedis(ctx, cmd.Name(), cmd.Err())
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	observeRedis(ctx, "pipeline", err)
+	return nil
+}
+
+func observeRedis(ctx context.Context, command string, err error) {
+	start, ok := ctx.Value(redisStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	// redis.Nil 表示 key 不存在，不算错误
+	result := "ok"
+	if err != nil && err != redis.Nil {
+		result = "error"
+	}
+	RedisDuration.WithLabelValues(command, result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,140 @@
+package mockup
+
+import "image/color"
+
+const (
+	FieldSite        = "site"
+	FieldDomain      = "domain"
+	FieldTitle       = "title"
+	FieldDescription = "description"
+
+	ImageTop    = "top"
+	ImageBottom = "bottom"
+)
+
+// Layout 一个平台的链接卡片样式，尺寸单位为 CSS 像素，渲染时乘以缩放倍数
+type Layout struct {
+	// Width 卡片宽度，Padding 卡片外的留白，Canvas 为留白处的背景（聊天窗口或信息流）
+	Width   int
+	Padding int
+	Canvas  color.Color
+
+	Background color.Color
+	// TextBackground 文字区域的背景，为空时与 Background 相同
+	TextBackground color.Color
+	Border         color.Color
+	Radius         int
+	// Accent 卡片左侧的竖条
+	Accent      color.Color
+	AccentWidth int
+	// Inset 文字区域的内边距
+	Inset int
+
+	// ImagePosition 图片在文字上方或下方，ImageInset 为 true 时图片在内边距以内
+	ImagePosition string
+	ImageInset    bool
+	// ImageRatio 图片按宽高比居中裁剪，为 0 时保持原比例并限制在 ImageMaxWidth、ImageMaxHeight 以内
+	ImageRatio     float64
+	ImageMaxWidth  int
+	ImageMaxHeight int
+	ImageRadius    int
+	// TitleOverlay 标题以半透明标签显示在图片左下角，不再出现在文字区域
+	TitleOverlay bool
+
+	Blocks []Block
+}
+
+// UsesIcon 是否显示网站图标
+func (l Layout) UsesIcon() bool {
+	for _, block := range l.Blocks {
+		if block.Icon {
+			return true
+		}
+	}
+	return false
+}
+
+// Block 文字区域中的一行或一段
+type Block struct {
+	Field  string
+	Prefix string
+	Size   float64
+	Bold   bool
+	Color  color.Color
+	// Lines 最多显示的行数，超出时以省略号结尾，0 为不限制
+	Lines int
+	Upper bool
+	// Icon 在文字前显示网站图标
+	Icon bool
+	// TextOnly 只在没有图片时显示
+	TextOnly bool
+	// Gap 与下一段的间距
+	Gap int
+}
+
+func rgb(hex uint32) color.RGBA {
+	return color.RGBA{R: uint8(hex >> 16), G: uint8(hex >> 8), B: uint8(hex), A: 0xff}
+}
+
+// Layouts 各平台的卡片样式，参照各平台网页版的默认浅色主题（Discord 为深色）
+var Layouts = map[string]Layout{
+	"slack": {
+		Width: 520, Padding: 16, Canvas: rgb(0xffffff),
+		Background: rgb(0xffffff), Accent: rgb(0xdddddd), AccentWidth: 4, Inset: 12,
+		ImagePosition: ImageBottom, ImageInset: true, ImageMaxWidth: 360, ImageMaxHeight: 240, ImageRadius: 8,
+		Blocks: []Block{
+			{Field: FieldSite, Size: 13, Bold: true, Color: rgb(0x1d1c1d), Lines: 1, Icon: true, Gap: 4},
+			{Field: FieldTitle, Size: 15, Bold: true, Color: rgb(0x1264a3), Lines: 2, Gap: 4},
+			{Field: FieldDescription, Size: 15, Color: rgb(0x1d1c1d), Lines: 4},
+		},
+	},
+	"twitter": {
+		Width: 506, Padding: 16, Canvas: rgb(0xffffff),
+		Background: rgb(0xffffff), Border: rgb(0xcfd9de), Radius: 16, Inset: 12,
+		ImagePosition: ImageTop, ImageRatio: 1.91, TitleOverlay: true,
+		Blocks: []Block{
+			{Field: FieldDomain, Prefix: "From ", Size: 13, Color: rgb(0x536471), Lines: 1, Gap: 2},
+			// 没有图片时为 summary 卡片
+			{Field: FieldTitle, Size: 15, Color: rgb(0x0f1419), Lines: 1, TextOnly: true, Gap: 2},
+			{Field: FieldDescription, Size: 15, Color: rgb(0x536471), Lines: 2, TextOnly: true},
+		},
+	},
+	"facebook": {
+		Width: 500, Padding: 16, Canvas: rgb(0xffffff),
+		Background: rgb(0xffffff), TextBackground: rgb(0xf0f2f5), Inset: 12,
+		ImagePosition: ImageTop, ImageRatio: 1.91,
+		Blocks: []Block{
+			{Field: FieldDomain, Size: 13, Color: rgb(0x65676b), Lines: 1, Upper: true, Gap: 4},
+			{Field: FieldTitle, Size: 17, Bold: true, Color: rgb(0x050505), Lines: 2, Gap: 2},
+			{Field: FieldDescription, Size: 15, Color: rgb(0x65676b), Lines: 1},
+		},
+	},
+	"linkedin": {
+		Width: 552, Padding: 16, Canvas: rgb(0xf4f2ee),
+		Background: rgb(0xffffff), TextBackground: rgb(0xeef3f8), Inset: 12,
+		ImagePosition: ImageTop, ImageRatio: 1.91,
+		Blocks: []Block{
+			{Field: FieldTitle, Size: 14, Bold: true, Color: rgb(0x191919), Lines: 2, Gap: 4},
+			{Field: FieldDomain, Size: 12, Color: rgb(0x666666), Lines: 1},
+		},
+	},
+	"discord": {
+		Width: 432, Padding: 16, Canvas: rgb(0x313338),
+		Background: rgb(0x2b2d31), Radius: 4, Accent: rgb(0x1e1f22), AccentWidth: 4, Inset: 16,
+		ImagePosition: ImageBottom, ImageInset: true, ImageMaxWidth: 400, ImageMaxHeight: 300, ImageRadius: 4,
+		Blocks: []Block{
+			{Field: FieldSite, Size: 12, Color: rgb(0xdbdee1), Lines: 1, Gap: 8},
+			{Field: FieldTitle, Size: 16, Bold: true, Color: rgb(0x00a8fc), Lines: 2, Gap: 8},
+			{Field: FieldDescription, Size: 14, Color: rgb(0xdbdee1), Lines: 6},
+		},
+	},
+	"imessage": {
+		Width: 300, Padding: 16, Canvas: rgb(0xffffff),
+		Background: rgb(0xffffff), TextBackground: rgb(0xe9e9eb), Radius: 18, Inset: 12,
+		ImagePosition: ImageTop, ImageRatio: 1.91,
+		Blocks: []Block{
+			{Field: FieldTitle, Size: 15, Bold: true, Color: rgb(0x000000), Lines: 2, Gap: 2},
+			{Field: FieldDomain, Size: 13, Color: rgb(0x8e8e93), Lines: 1},
+		},
+	},
+}
@@ -0,0 +1,287 @@
+// Package mockup 按各平台的卡片样式把预览信息渲染成图片，用于查看链接分享后的效果
+package mockup
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+
+	"ogimg/pkg/imaging"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+var ErrUnknownPlatform = errors.New("unknown platform")
+
+// Card 卡片内容，Image、Logo 为空时不显示
+type Card struct {
+	Url         string
+	SiteName    string
+	Title       string
+	Description string
+	Image       image.Image
+	Logo        image.Image
+}
+
+func (c Card) field(name string) string {
+	switch name {
+	case FieldSite:
+		if c.SiteName != "" {
+			return c.SiteName
+		}
+		return c.domain()
+	case FieldDomain:
+		return c.domain()
+	case FieldTitle:
+		return c.Title
+	case FieldDescription:
+		return c.Description
+	}
+	return ""
+}
+
+func (c Card) domain() string {
+	u, err := url.Parse(c.Url)
+	if err != nil {
+		return c.Url
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// Platforms 返回支持的平台名称
+func Platforms() []string {
+	names := make([]string, 0, len(Layouts))
+	for name := range Layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Renderer 渲染卡片，Scale 为输出的像素倍数，2 对应高分屏
+type Renderer struct {
+	fonts *Fonts
+	scale float64
+}
+
+func NewRenderer(fonts *Fonts, scale float64) *Renderer {
+	if scale <= 0 {
+		scale = 1
+	}
+	return &Renderer{fonts: fonts, scale: scale}
+}
+
+type textBlock struct {
+	block      Block
+	face       font.Face
+	lines      []string
+	lineHeight int
+	icon       int
+}
+
+// Render 按 platform 的样式渲染卡片
+func (r *Renderer) Render(platform string, card Card) (image.Image, error) {
+	layout, ok := Layouts[platform]
+	if !ok {
+		return nil, ErrUnknownPlatform
+	}
+	px := func(v int) int {
+		return int(math.Round(float64(v) * r.scale))
+	}
+	width := px(layout.Width)
+	inset := px(layout.Inset)
+	accent := px(layout.AccentWidth)
+	textX := accent + inset
+	textWidth := width - textX - inset
+	overlay := layout.TitleOverlay && card.Image != nil && card.Title != ""
+
+	// 先排版文字，得到文字区域的高度
+	var texts []textBlock
+	textHeight := 0
+	for _, block := range layout.Blocks {
+		value := card.field(block.Field)
+		if value == "" || (block.TextOnly && card.Image != nil) || (overlay && block.Field == FieldTitle) {
+			continue
+		}
+		if block.Upper {
+			value = strings.ToUpper(value)
+		}
+		face, err := r.fonts.face(block.Bold, block.Size*r.scale)
+		if err != nil {
+			return nil, err
+		}
+		t := textBlock{block: block, face: face, lineHeight: int(math.Ceil(block.Size * 1.3 * r.scale))}
+		if block.Icon && card.Logo != nil {
+			t.icon = t.lineHeight
+		}
+		available := textWidth
+		if t.icon > 0 {
+			available -= t.icon + px(6)
+		}
+		t.lines = wrap(face, block.Prefix+value, available, block.Lines)
+		if len(texts) > 0 {
+			textHeight += px(texts[len(texts)-1].block.Gap)
+		}
+		textHeight += len(t.lines) * t.lineHeight
+		texts = append(texts, t)
+	}
+
+	var img image.Image
+	imgX, imgW, imgH := 0, width, 0
+	if layout.ImageInset {
+		imgX, imgW = textX, textWidth
+	}
+	if card.Image != nil {
+		if layout.ImageRatio > 0 {
+			imgH = int(math.Round(float64(imgW) / layout.ImageRatio))
+			img = imaging.ResizeImage(card.Image, imaging.ResizeOptions{Width: imgW, Height: imgH, Fit: imaging.FitCover})
+		} else {
+			// 保持原比例，不放大
+			b := card.Image.Bounds()
+			maxW := min(imgW, px(b.Dx()))
+			if layout.ImageMaxWidth > 0 {
+				maxW = min(maxW, px(layout.ImageMaxWidth))
+			}
+			maxH := px(b.Dy())
+			if layout.ImageMaxHeight > 0 {
+				maxH = min(maxH, px(layout.ImageMaxHeight))
+			}
+			img = imaging.ResizeImage(card.Image, imaging.ResizeOptions{Width: maxW, Height: maxH, Fit: imaging.FitContain})
+			imgW, imgH = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+	}
+
+	var imgY, textY, textTop, height int
+	switch {
+	case img != nil && layout.ImagePosition == ImageTop:
+		if layout.ImageInset {
+			imgY = inset
+		}
+		textTop = imgY + imgH
+		textY = textTop + inset
+		height = textY + textHeight + inset
+	case img != nil:
+		textY = inset
+		imgY = inset + textHeight
+		if textHeight > 0 {
+			imgY += px(8)
+		}
+		height = imgY + imgH + inset
+	default:
+		textY = inset
+		height = inset + textHeight + inset
+	}
+
+	cardImg := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill(cardImg, cardImg.Bounds(), layout.Background, 0)
+	if layout.TextBackground != nil {
+		fill(cardImg, image.Rect(0, textTop, width, height), layout.TextBackground, 0)
+	}
+	if layout.Accent != nil {
+		fill(cardImg, image.Rect(0, 0, accent, height), layout.Accent, 0)
+	}
+	if img != nil {
+		rect := image.Rect(imgX, imgY, imgX+imgW, imgY+imgH)
+		draw.DrawMask(cardImg, rect, img, img.Bounds().Min, roundedMask{rect: rect, radius: px(layout.ImageRadius)}, rect.Min, draw.Over)
+		if overlay {
+			if err := r.drawOverlay(cardImg, rect, card.Title, px); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	y := textY
+	for _, t := range texts {
+		x := textX
+		if t.icon > 0 {
+			rect := image.Rect(x, y, x+t.icon, y+t.icon)
+			logo := imaging.ResizeImage(card.Logo, imaging.ResizeOptions{Width: t.icon, Height: t.icon, Fit: imaging.FitCover})
+			draw.DrawMask(cardImg, rect, logo, logo.Bounds().Min, roundedMask{rect: rect, radius: px(3)}, rect.Min, draw.Over)
+			x += t.icon + px(6)
+		}
+		for _, line := range t.lines {
+			drawText(cardImg, t.face, line, x, y, t.lineHeight, t.block.Color)
+			y += t.lineHeight
+		}
+		y += px(t.block.Gap)
+	}
+
+	pad := px(layout.Padding)
+	canvas := image.NewRGBA(image.Rect(0, 0, width+2*pad, height+2*pad))
+	fill(canvas, canvas.Bounds(), layout.Canvas, 0)
+	cardRect := image.Rect(pad, pad, pad+width, pad+height)
+	if layout.Border != nil {
+		border := max(px(1), 1)
+		fill(canvas, cardRect.Inset(-border), layout.Border, px(layout.Radius)+border)
+	}
+	draw.DrawMask(canvas, cardRect, cardImg, image.Point{}, roundedMask{rect: cardRect, radius: px(layout.Radius)}, cardRect.Min, draw.Over)
+	return canvas, nil
+}
+
+// drawOverlay 在图片左下角画半透明标签显示标题
+func (r *Renderer) drawOverlay(dst *image.RGBA, rect image.Rectangle, title string, px func(int) int) error {
+	face, err := r.fonts.face(false, 13*r.scale)
+	if err != nil {
+		return err
+	}
+	margin, padX, padY := px(12), px(8), px(2)
+	lineHeight := int(math.Ceil(13 * 1.3 * r.scale))
+	text := truncate(face, strings.Join(strings.Fields(title), " "), fixed.I(rect.Dx()-2*margin-2*padX))
+	width := font.MeasureString(face, text).Ceil() + 2*padX
+	pill := image.Rect(rect.Min.X+margin, rect.Max.Y-margin-lineHeight-2*padY, rect.Min.X+margin+width, rect.Max.Y-margin)
+	fill(dst, pill, color.RGBA{A: 0xc4}, px(4))
+	drawText(dst, face, text, pill.Min.X+padX, pill.Min.Y+padY, lineHeight, color.White)
+	return nil
+}
+
+// drawText 在 (x, y) 开始的一行中垂直居中绘制文字
+func drawText(dst draw.Image, face font.Face, text string, x, y, lineHeight int, c color.Color) {
+	metrics := face.Metrics()
+	ascent, descent := metrics.Ascent.Ceil(), metrics.Descent.Ceil()
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y+(lineHeight-ascent-descent)/2+ascent),
+	}
+	d.DrawString(text)
+}
+
+func fill(dst draw.Image, rect image.Rectangle, c color.Color, radius int) {
+	draw.DrawMask(dst, rect, image.NewUniform(c), image.Point{}, roundedMask{rect: rect, radius: radius}, rect.Min, draw.Over)
+}
+
+// roundedMask 圆角矩形遮罩，边缘做简单的抗锯齿
+type roundedMask struct {
+	rect   image.Rectangle
+	radius int
+}
+
+func (m roundedMask) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (m roundedMask) Bounds() image.Rectangle {
+	return m.rect
+}
+
+func (m roundedMask) At(x, y int) color.Color {
+	if !(image.Point{X: x, Y: y}).In(m.rect) {
+		return color.Transparent
+	}
+	if m.radius <= 0 {
+		return color.Opaque
+	}
+	r := float64(m.radius)
+	px, py := float64(x)+0.5, float64(y)+0.5
+	cx := math.Max(float64(m.rect.Min.X)+r, math.Min(px, float64(m.rect.Max.X)-r))
+	cy := math.Max(float64(m.rect.Min.Y)+r, math.Min(py, float64(m.rect.Max.Y)-r))
+	a := math.Max(0, math.Min(1, r-math.Hypot(px-cx, py-cy)+0.5))
+	return color.Alpha{A: uint8(a * 0xff)}
+}
@@ -0,0 +1,217 @@
+package mockup
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+var red = color.RGBA{R: 0xff, A: 0xff}
+
+func solid(width, height int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func newRenderer(t *testing.T, scale float64) *Renderer {
+	t.Helper()
+	fonts, err := LoadFonts("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRenderer(fonts, scale)
+}
+
+func render(t *testing.T, r *Renderer, platform string, card Card) image.Image {
+	t.Helper()
+	img, err := r.Render(platform, card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// bounds 返回颜色为 c 的像素的外接矩形
+func bounds(img image.Image, c color.Color) image.Rectangle {
+	var rect image.Rectangle
+	want := color.RGBAModel.Convert(c)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.RGBAModel.Convert(img.At(x, y)) == want {
+				rect = rect.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return rect
+}
+
+var testCard = Card{
+	Url:         "https://www.example.com/post",
+	SiteName:    "Example",
+	Title:       "An example page",
+	Description: "A short description of the example page",
+}
+
+func TestCardField(t *testing.T) {
+	if got := testCard.field(FieldDomain); got != "example.com" {
+		t.Errorf("domain = %q", got)
+	}
+	if got := testCard.field(FieldSite); got != "Example" {
+		t.Errorf("site = %q", got)
+	}
+	if got := (Card{Url: "https://news.example.com/a"}).field(FieldSite); got != "news.example.com" {
+		t.Errorf("site without name = %q", got)
+	}
+}
+
+func TestPlatforms(t *testing.T) {
+	names := Platforms()
+	if len(names) != len(Layouts) || !sort.StringsAreSorted(names) {
+		t.Errorf("platforms = %v", names)
+	}
+	for _, name := range names {
+		layout := Layouts[name]
+		if layout.Width <= 0 || len(layout.Blocks) == 0 {
+			t.Errorf("%s layout is incomplete", name)
+		}
+		if layout.ImagePosition != ImageTop && layout.ImagePosition != ImageBottom {
+			t.Errorf("%s image position = %q", name, layout.ImagePosition)
+		}
+	}
+	if !Layouts["slack"].UsesIcon() || Layouts["facebook"].UsesIcon() {
+		t.Error("only slack shows the site icon")
+	}
+}
+
+func TestRenderUnknownPlatform(t *testing.T) {
+	if _, err := newRenderer(t, 1).Render("myspace", testCard); !errors.Is(err, ErrUnknownPlatform) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRenderImageTop(t *testing.T) {
+	r := newRenderer(t, 1)
+	card := testCard
+	text := render(t, r, "facebook", card)
+	card.Image = solid(1000, 1000, red)
+	withImage := render(t, r, "facebook", card)
+
+	// 宽度为卡片宽度加两侧留白，图片按 1.91:1 裁剪后放在文字上方
+	layout := Layouts["facebook"]
+	if got := withImage.Bounds().Dx(); got != layout.Width+2*layout.Padding {
+		t.Errorf("width = %d", got)
+	}
+	imgH := 262 // 500 / 1.91
+	if got := withImage.Bounds().Dy() - text.Bounds().Dy(); got != imgH {
+		t.Errorf("image adds %d pixels, want %d", got, imgH)
+	}
+	want := image.Rect(layout.Padding, layout.Padding, layout.Padding+layout.Width, layout.Padding+imgH)
+	if got := bounds(withImage, red); got != want {
+		t.Errorf("image at %v, want %v", got, want)
+	}
+}
+
+func TestRenderImageBottom(t *testing.T) {
+	card := testCard
+	card.Image = solid(1000, 1000, red)
+	img := render(t, newRenderer(t, 1), "slack", card)
+
+	// 图片保持比例限制在 360x240 以内，放在文字下方、内边距以内
+	layout := Layouts["slack"]
+	got := bounds(img, red)
+	if got.Dx() != 240 || got.Dy() != 240 {
+		t.Errorf("image is %dx%d, want 240x240", got.Dx(), got.Dy())
+	}
+	if x := layout.Padding + layout.AccentWidth + layout.Inset; got.Min.X != x {
+		t.Errorf("image x = %d, want %d", got.Min.X, x)
+	}
+	if bottom := img.Bounds().Dy() - layout.Padding - layout.Inset; got.Max.Y != bottom {
+		t.Errorf("image bottom = %d, want %d", got.Max.Y, bottom)
+	}
+
+	// 小图不放大
+	card.Image = solid(100, 50, red)
+	if got := bounds(render(t, newRenderer(t, 1), "slack", card), red); got.Dx() != 100 || got.Dy() != 50 {
+		t.Errorf("small image is %dx%d", got.Dx(), got.Dy())
+	}
+}
+
+func TestRenderTitleOverlay(t *testing.T) {
+	r := newRenderer(t, 1)
+	card := testCard
+	card.Image = solid(1000, 1000, red)
+	img := render(t, r, "twitter", card)
+
+	// 有图片时只剩下 domain 一行：265 图片 + 12 + 17 + 12，加两侧留白
+	if got := img.Bounds(); got.Dx() != 538 || got.Dy() != 338 {
+		t.Errorf("size = %v", got)
+	}
+
+	// 没有图片时为 summary 卡片，显示标题和一行描述
+	summary := render(t, r, "twitter", testCard)
+	if got := summary.Bounds().Dy(); got != 16+12+17+2+20+2+20+12+16 {
+		t.Errorf("summary height = %d", got)
+	}
+}
+
+func TestRenderScale(t *testing.T) {
+	card := testCard
+	card.Image = solid(1000, 1000, red)
+	one := render(t, newRenderer(t, 1), "linkedin", card)
+	two := render(t, newRenderer(t, 2), "linkedin", card)
+	if two.Bounds().Dx() != 2*one.Bounds().Dx() {
+		t.Errorf("width = %d, want %d", two.Bounds().Dx(), 2*one.Bounds().Dx())
+	}
+	if d := two.Bounds().Dy() - 2*one.Bounds().Dy(); d < -2 || d > 2 {
+		t.Errorf("height = %d, want about %d", two.Bounds().Dy(), 2*one.Bounds().Dy())
+	}
+	if NewRenderer(nil, 0).scale != 1 {
+		t.Error("scale defaults to 1")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	fonts, err := LoadFonts("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	face, err := fonts.face(false, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	width := font.MeasureString(face, "the quick brown fox").Ceil()
+
+	// 在空白处断开，连续空白合并为一个空格
+	lines := wrap(face, "the quick  brown fox\njumps over the lazy dog", width, 0)
+	if len(lines) < 3 || lines[0] != "the quick brown fox" || strings.Join(lines, " ") != "the quick brown fox jumps over the lazy dog" {
+		t.Errorf("lines = %q", lines)
+	}
+	for _, line := range lines {
+		if font.MeasureString(face, line) > fixed.I(width) {
+			t.Errorf("%q is wider than %d", line, width)
+		}
+	}
+
+	lines = wrap(face, "the quick brown fox jumps over the lazy dog", width, 2)
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], ellipsis) {
+		t.Errorf("lines = %q", lines)
+	}
+
+	// 没有空白的长文本按字符断开，每行至少一个字符
+	lines = wrap(face, strings.Repeat("w", 100), 1, 3)
+	if len(lines) != 3 || lines[0] != "w" {
+		t.Errorf("lines = %q", lines)
+	}
+	if lines := wrap(face, "   ", width, 2); len(lines) != 0 {
+		t.Errorf("lines = %q", lines)
+	}
+}
@@ -0,0 +1,112 @@
+package mockup
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const ellipsis = "…"
+
+// Fonts 渲染使用的字体，可以在多个 goroutine 间共享
+type Fonts struct {
+	regular *opentype.Font
+	bold    *opentype.Font
+}
+
+// LoadFonts 读取 TTF/OTF 字体文件，路径为空时使用内置的 Go 字体（不含中日韩字形）
+func LoadFonts(regularPath, boldPath string) (*Fonts, error) {
+	regular, err := loadFont(regularPath, goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := loadFont(boldPath, gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	return &Fonts{regular: regular, bold: bold}, nil
+}
+
+func loadFont(path string, fallback []byte) (*opentype.Font, error) {
+	data := fallback
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse font %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// face 创建指定字号的字形，font.Face 不能并发使用，每次渲染单独创建
+func (f *Fonts) face(bold bool, size float64) (font.Face, error) {
+	src := f.regular
+	if bold {
+		src = f.bold
+	}
+	return opentype.NewFace(src, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// wrap 按宽度折行，优先在空白处断开，超过 maxLines 行时最后一行以省略号结尾
+func wrap(face font.Face, text string, width int, maxLines int) []string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	limit := fixed.I(width)
+	var lines []string
+	for len(runes) > 0 {
+		if maxLines > 0 && len(lines) == maxLines-1 {
+			lines = append(lines, truncate(face, string(runes), limit))
+			break
+		}
+		n := fit(face, runes, limit)
+		if n < len(runes) {
+			for i := n; i > 0; i-- {
+				if unicode.IsSpace(runes[i]) {
+					n = i
+					break
+				}
+			}
+		}
+		lines = append(lines, strings.TrimSpace(string(runes[:n])))
+		runes = []rune(strings.TrimLeftFunc(string(runes[n:]), unicode.IsSpace))
+	}
+	return lines
+}
+
+// truncate 放不下时截断并加省略号
+func truncate(face font.Face, text string, limit fixed.Int26_6) string {
+	if font.MeasureString(face, text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	n := fit(face, runes, limit-font.MeasureString(face, ellipsis))
+	return strings.TrimRightFunc(string(runes[:n]), unicode.IsSpace) + ellipsis
+}
+
+// fit 返回宽度不超过 limit 的最长前缀的字符数，至少为 1 避免死循环
+func fit(face font.Face, runes []rune, limit fixed.Int26_6) int {
+	var width fixed.Int26_6
+	prev := rune(-1)
+	for i, r := range runes {
+		if prev >= 0 {
+			width += face.Kern(prev, r)
+		}
+		advance, _ := face.GlyphAdvance(r)
+		width += advance
+		if width > limit {
+			return max(i, 1)
+		}
+		prev = r
+	}
+	return len(runes)
+}
@@ -0,0 +1,108 @@
+// Package oembed 请求 oEmbed 接口获取标题和缩略图，常见站点使用内置的接口地址，其余站点使用页面中的发现链接
+package oembed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("ogimg/pkg/oembed")
+
+const maxResponseSize = 1 << 20
+
+// Response oEmbed 响应中用到的字段，description 不在规范中，Vimeo 等站点会返回
+type Response struct {
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ProviderName string `json:"provider_name"`
+	Url          string `json:"url"`
+	ThumbnailUrl string `json:"thumbnail_url"`
+	Description  string `json:"description"`
+}
+
+// Getter 发起 GET 请求，4xx/5xx 视为错误，例如 extract.Extractor.Get
+type Getter func(ctx context.Context, url string) (*http.Response, error)
+
+type Client struct {
+	get Getter
+}
+
+func NewClient(get Getter) *Client {
+	return &Client{get: get}
+}
+
+// Endpoint 返回 pageUrl 的 oEmbed 请求地址，优先使用内置的提供方，其次使用页面中的发现链接，都没有时返回空
+func Endpoint(pageUrl, discovered string) string {
+	if p := Lookup(pageUrl); p != nil {
+		return p.EndpointFor(pageUrl)
+	}
+	return discovered
+}
+
+// Fetch 请求 oEmbed 接口，只支持 JSON 格式
+func (c *Client) Fetch(ctx context.Context, endpoint string) (res *Response, err error) {
+	ctx, span := tracer.Start(ctx, "oembed.Fetch", trace.WithAttributes(attribute.String("url.full", endpoint)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	resp, err := c.get(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, apierr.Upstream(err)
+	}
+	if len(body) > maxResponseSize {
+		return nil, apierr.TooLarge.WithMessage(fmt.Sprintf("oEmbed response is larger than %d bytes", maxResponseSize))
+	}
+	res = &Response{}
+	if err := json.Unmarshal(body, res); err != nil {
+		return nil, apierr.UnsupportedType.WithMessage("invalid oEmbed response").Wrap(err)
+	}
+	return res, nil
+}
+
+// Merge 把 oEmbed 的信息合并到 meta：有标题时以 oEmbed 为准，页面没有描述或 og:image 时使用 oEmbed 的描述和图片
+func Merge(meta *extract.Metadata, res *Response) {
+	switch {
+	case res.Title != "":
+		meta.Title = res.Title
+	case meta.Title == "" && res.AuthorName != "":
+		meta.Title = res.AuthorName
+	}
+	if meta.Description == "" {
+		meta.Description = res.Description
+	}
+
+	image := res.ThumbnailUrl
+	// photo 类型的 url 是原图
+	if res.Type == "photo" && res.Url != "" {
+		image = res.Url
+	}
+	if image == "" {
+		return
+	}
+	meta.Images = append(meta.Images, extract.Candidate{Url: image, Source: "oembed"})
+	if meta.Image == "" {
+		meta.Image = image
+	}
+}
@@ -0,0 +1,167 @@
+package oembed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/extract"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "YouTube"},
+		{"http://m.youtube.com/watch?v=dQw4w9WgXcQ", "YouTube"},
+		{"https://youtu.be/dQw4w9WgXcQ", "YouTube"},
+		{"https://youtube.com/shorts/abc", "YouTube"},
+		{"https://vimeo.com/76979871", "Vimeo"},
+		{"https://player.vimeo.com/video/76979871", "Vimeo"},
+		{"https://x.com/golang/status/1", "Twitter"},
+		{"https://mobile.twitter.com/golang/status/1", "Twitter"},
+		{"https://open.spotify.com/track/1", "Spotify"},
+		{"https://on.soundcloud.com/abc", "SoundCloud"},
+		{"https://www.flickr.com/photos/a/1", "Flickr"},
+		{"https://flic.kr/p/abc", "Flickr"},
+		{"https://codepen.io/team/pen/abc", "CodePen"},
+		// 不在 scheme 中的页面
+		{"https://www.youtube.com/", ""},
+		{"https://x.com/golang", ""},
+		{"https://vimeo.com.evil.example/1", ""},
+		{"https://evil.example/?u=https://vimeo.com/1", ""},
+		{"ftp://vimeo.com/1", ""},
+		// 域名中的 * 不能匹配到路径或查询参数里
+		{"https://evil.com/x.youtube.com/watch", ""},
+		{"https://evil.com?x.youtube.com/watch", ""},
+		{"https://evil.com#.twitter.com/a/status/1", ""},
+		{"https://evil.com/.flickr.com/photos/a", ""},
+		{"https://user@evil.com/.youtube.com/watch", ""},
+	}
+	for _, tt := range tests {
+		p := Lookup(tt.url)
+		var got string
+		if p != nil {
+			got = p.Name
+		}
+		if got != tt.want {
+			t.Errorf("Lookup(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	page := "https://vimeo.com/76979871"
+	want := "https://vimeo.com/api/oembed.json?" + url.Values{"url": {page}, "format": {"json"}}.Encode()
+	if got := Endpoint(page, "https://vimeo.com/discovered"); got != want {
+		t.Errorf("Endpoint = %q, want %q", got, want)
+	}
+	if got := Endpoint("https://example.com/post", "https://example.com/oembed"); got != "https://example.com/oembed" {
+		t.Errorf("discovered Endpoint = %q", got)
+	}
+	if got := Endpoint("https://example.com/post", ""); got != "" {
+		t.Errorf("Endpoint without discovery = %q", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		meta extract.Metadata
+		res  Response
+		want extract.Metadata
+	}{
+		{
+			name: "oembed title wins, page description and image are kept",
+			meta: extract.Metadata{Title: "page", Description: "page desc", Image: "https://example.com/og.png"},
+			res:  Response{Title: "video", Description: "oembed desc", ThumbnailUrl: "https://i.example.com/thumb.jpg"},
+			want: extract.Metadata{Title: "video", Description: "page desc", Image: "https://example.com/og.png"},
+		},
+		{
+			name: "thumbnail is used without og:image",
+			meta: extract.Metadata{},
+			res:  Response{Description: "oembed desc", AuthorName: "author", ThumbnailUrl: "https://i.example.com/thumb.jpg"},
+			want: extract.Metadata{Title: "author", Description: "oembed desc", Image: "https://i.example.com/thumb.jpg"},
+		},
+		{
+			name: "author does not replace the page title",
+			meta: extract.Metadata{Title: "page"},
+			res:  Response{AuthorName: "author"},
+			want: extract.Metadata{Title: "page"},
+		},
+		{
+			name: "photo url is the original image",
+			meta: extract.Metadata{},
+			res:  Response{Type: "photo", Url: "https://i.example.com/full.jpg", ThumbnailUrl: "https://i.example.com/thumb.jpg"},
+			want: extract.Metadata{Image: "https://i.example.com/full.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := tt.meta
+			Merge(&meta, &tt.res)
+			if meta.Title != tt.want.Title || meta.Description != tt.want.Description || meta.Image != tt.want.Image {
+				t.Errorf("Merge = %+v, want %+v", meta, tt.want)
+			}
+			image := tt.res.ThumbnailUrl
+			if tt.res.Type == "photo" {
+				image = tt.res.Url
+			}
+			var want []extract.Candidate
+			if image != "" {
+				want = []extract.Candidate{{Url: image, Source: "oembed"}}
+			}
+			if !reflect.DeepEqual(meta.Images, want) {
+				t.Errorf("Images = %+v, want %+v", meta.Images, want)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"type":"video","title":"video","thumbnail_url":"https://i.example.com/thumb.jpg","html":"<iframe></iframe>"}`))
+		case "/large":
+			w.Write([]byte(`{"title":"` + strings.Repeat("a", maxResponseSize) + `"}`))
+		default:
+			w.Write([]byte(`<oembed></oembed>`))
+		}
+	}))
+	defer srv.Close()
+	client := NewClient(func(ctx context.Context, url string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return http.DefaultClient.Do(req)
+	})
+
+	res, err := client.Fetch(context.Background(), srv.URL+"/ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Type != "video" || res.Title != "video" || res.ThumbnailUrl != "https://i.example.com/thumb.jpg" {
+		t.Errorf("Fetch = %+v", res)
+	}
+	if _, err := client.Fetch(context.Background(), srv.URL+"/large"); !errors.Is(err, apierr.TooLarge) {
+		t.Errorf("large response err = %v", err)
+	}
+	if _, err := client.Fetch(context.Background(), srv.URL+"/xml"); !errors.Is(err, apierr.UnsupportedType) {
+		t.Errorf("xml response err = %v", err)
+	}
+
+	getErr := errors.New("blocked")
+	client = NewClient(func(ctx context.Context, url string) (*http.Response, error) { return nil, getErr })
+	if _, err := client.Fetch(context.Background(), srv.URL+"/ok"); !errors.Is(err, getErr) {
+		t.Errorf("get err = %v", err)
+	}
+}
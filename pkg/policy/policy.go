@@ -0,0 +1,283 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Policy 域名白名单/黑名单，配置文件变更后自动重新加载
+//
+// 规则写法：
+//   - example.com        精确匹配
+//   - *.example.com      匹配所有子域名（不含 example.com 本身）
+//   - re:^img\d+\.cdn\.  正则匹配域名
+//   - 10.0.0.0/8         CIDR，匹配 IP 或解析后的 IP
+type Policy struct {
+	rules  atomic.Value // *ruleSet
+	logger *log.Logger
+}
+
+// Decision 检查结果，Rule 为命中的规则
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule"`
+}
+
+type ruleSet struct {
+	allow []matcher
+	deny  []matcher
+}
+
+type matcher struct {
+	raw    string
+	host   string
+	suffix string
+	re     *regexp.Regexp
+	cidr   *net.IPNet
+}
+
+// NewPolicy 规则有误时返回错误，启动失败
+func NewPolicy(conf *config.Config, logger *log.Logger, watcher *config.Watcher) (*Policy, error) {
+	p := &Policy{logger: logger}
+	if err := p.Load(conf); err != nil {
+		return nil, err
+	}
+	// 规则有误时拒绝整个配置重载
+	watcher.AddValidator(func(next *config.Config) error {
+		_, err := load(next)
+		return err
+	})
+	changes := watcher.Subscribe("policy.")
+	go func() {
+		for range changes {
+			if err := p.Load(conf); err != nil {
+				logger.Error("Reload policy error, keep previous rules", zap.Error(err))
+				continue
+			}
+			logger.Info("Policy reloaded")
+		}
+	}()
+	return p, nil
+}
+
+// Load 从配置中读取 policy.allow 和 policy.deny，规则有误时不替换当前规则
+func (p *Policy) Load(conf *config.Config) error {
+	rules, err := load(conf)
+	if err != nil {
+		return err
+	}
+	p.rules.Store(rules)
+	return nil
+}
+
+func load(conf *config.Config) (*ruleSet, error) {
+	allow, err := compile(conf.GetStringSlice("policy.allow"))
+	if err != nil {
+		return nil, err
+	}
+	deny, err := compile(conf.GetStringSlice("policy.deny"))
+	if err != nil {
+		return nil, err
+	}
+	return &ruleSet{allow: allow, deny: deny}, nil
+}
+
+// Check 检查 rawUrl 的域名是否允许访问：先匹配黑名单，白名单非空时必须命中白名单
+func (p *Policy) Check(ctx context.Context, rawUrl string) (Decision, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return Decision{}, err
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return Decision{}, fmt.Errorf("url has no host: %s", rawUrl)
+	}
+	return p.decide(host, lazyIPs(ctx, host)), nil
+}
+
+// Err 被拒绝时返回的接口错误，包含命中的规则
+func (d Decision) Err() *apierr.Error {
+	return apierr.Blocked.WithMessage("url blocked by policy rule " + d.Rule)
+}
+
+func (p *Policy) decide(host string, ips func() ([]net.IP, error)) Decision {
+	rules := p.rules.Load().(*ruleSet)
+	for _, m := range rules.deny {
+		matched, err := m.match(host, ips)
+		if err != nil {
+			// 域名无法解析时无法确认是否命中 CIDR，按命中处理
+			return Decision{Allowed: false, Rule: "deny:" + m.raw + " (unresolved)"}
+		}
+		if matched {
+			return Decision{Allowed: false, Rule: "deny:" + m.raw}
+		}
+	}
+	if len(rules.allow) == 0 {
+		return Decision{Allowed: true}
+	}
+	for _, m := range rules.allow {
+		if matched, _ := m.match(host, ips); matched {
+			return Decision{Allowed: true, Rule: "allow:" + m.raw}
+		}
+	}
+	return Decision{Allowed: false, Rule: "allow:<none>"}
+}
+
+// Transport 返回建立连接前按策略检查目标 IP 的 Transport，重定向、og:image、oEmbed 等
+// 所有上游请求都受策略限制；不使用环境变量中的代理，否则只能检查到代理的地址
+func (p *Policy) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = p.DialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	return t
+}
+
+// DialContext 解析域名后逐个检查 IP，只连接允许的 IP，避免 DNS 重绑定绕过 CIDR 规则
+func (p *Policy) DialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		host = strings.ToLower(host)
+		ips, err := lazyIPs(ctx, host)()
+		if err != nil {
+			return nil, err
+		}
+		var denied *Decision
+		var dialErr error
+		for _, ip := range ips {
+			ip := ip
+			decision := p.decide(host, func() ([]net.IP, error) { return []net.IP{ip}, nil })
+			if !decision.Allowed {
+				if denied == nil {
+					denied = &decision
+				}
+				continue
+			}
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			dialErr = err
+		}
+		if dialErr != nil || denied == nil {
+			return nil, dialErr
+		}
+		return nil, denied.Err()
+	}
+}
+
+// CheckRedirect 用于 http.Client，重定向到被拒绝的域名时停止
+func (p *Policy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	decision, err := p.Check(req.Context(), req.URL.String())
+	if err != nil {
+		return apierr.InvalidUrl.Wrap(err)
+	}
+	if !decision.Allowed {
+		return decision.Err()
+	}
+	return nil
+}
+
+func compile(raws []string) ([]matcher, error) {
+	matchers := make([]matcher, 0, len(raws))
+	for _, raw := range raws {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		m := matcher{raw: raw}
+		switch {
+		case strings.HasPrefix(raw, "re:"):
+			re, err := regexp.Compile(strings.TrimPrefix(raw, "re:"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid policy rule %q: %w", raw, err)
+			}
+			m.re = re
+		case strings.HasPrefix(raw, "*."):
+			m.suffix = strings.ToLower(raw[1:])
+		case strings.Contains(raw, "/"):
+			_, cidr, err := net.ParseCIDR(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid policy rule %q: %w", raw, err)
+			}
+			m.cidr = cidr
+		default:
+			if ip := net.ParseIP(raw); ip != nil {
+				if v4 := ip.To4(); v4 != nil {
+					ip = v4
+				}
+				m.cidr = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+			} else {
+				m.host = strings.ToLower(raw)
+			}
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func (m matcher) match(host string, ips func() ([]net.IP, error)) (bool, error) {
+	switch {
+	case m.re != nil:
+		return m.re.MatchString(host), nil
+	case m.suffix != "":
+		return strings.HasSuffix(host, m.suffix), nil
+	case m.cidr != nil:
+		resolved, err := ips()
+		if err != nil {
+			return false, err
+		}
+		for _, ip := range resolved {
+			if m.cidr.Contains(ip) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return host == m.host, nil
+	}
+}
+
+// lazyIPs 只有在需要匹配 CIDR 规则时才解析域名
+func lazyIPs(ctx context.Context, host string) func() ([]net.IP, error) {
+	var ips []net.IP
+	var err error
+	resolved := false
+	return func() ([]net.IP, error) {
+		if resolved {
+			return ips, err
+		}
+		resolved = true
+		if ip := net.ParseIP(host); ip != nil {
+			ips = []net.IP{ip}
+			return ips, nil
+		}
+		var addrs []net.IPAddr
+		addrs, err = net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err == nil && len(addrs) == 0 {
+			err = fmt.Errorf("no addresses for host %s", host)
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+		return ips, err
+	}
+}
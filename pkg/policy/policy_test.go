@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ogimg/pkg/apierr"
+	"ogimg/pkg/config"
+	"ogimg/pkg/log"
+
+	"github.com/spf13/viper"
+)
+
+func newTestPolicy(t *testing.T, allow, deny []string) *Policy {
+	t.Helper()
+	conf := config.New(viper.New())
+	conf.Set("policy.allow", allow)
+	conf.Set("policy.deny", deny)
+	p := &Policy{}
+	if err := p.Load(conf); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+type checkCase struct {
+	url     string
+	allowed bool
+	rule    string
+}
+
+func runChecks(t *testing.T, p *Policy, tests []checkCase) {
+	t.Helper()
+	for _, tt := range tests {
+		d, err := p.Check(context.Background(), tt.url)
+		if err != nil {
+			t.Errorf("Check(%q) error: %v", tt.url, err)
+			continue
+		}
+		if d.Allowed != tt.allowed || d.Rule != tt.rule {
+			t.Errorf("Check(%q) = %+v, want allowed=%v rule=%q", tt.url, d, tt.allowed, tt.rule)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	p := newTestPolicy(t,
+		[]string{"example.com", "*.example.org", `re:^img\d+\.cdn\.net$`},
+		[]string{"bad.example.org"},
+	)
+	runChecks(t, p, []checkCase{
+		{"https://example.com/a", true, "allow:example.com"},
+		{"https://EXAMPLE.com/a", true, "allow:example.com"},
+		{"https://www.example.com/a", false, "allow:<none>"},
+		{"https://a.example.org", true, "allow:*.example.org"},
+		// *. 不包含域名本身
+		{"https://example.org", false, "allow:<none>"},
+		// 先匹配黑名单
+		{"https://bad.example.org", false, "deny:bad.example.org"},
+		{"https://img12.cdn.net/x.png", true, `allow:re:^img\d+\.cdn\.net$`},
+		{"https://img.cdn.net/x.png", false, "allow:<none>"},
+	})
+}
+
+// CIDR 规则使用 IP 地址测试，不依赖 DNS
+func TestCheckCIDR(t *testing.T) {
+	p := newTestPolicy(t, []string{"127.0.0.0/8", "example.com"}, []string{"10.0.0.0/8", "::1"})
+	runChecks(t, p, []checkCase{
+		{"http://127.0.0.1:8080", true, "allow:127.0.0.0/8"},
+		{"http://10.1.2.3", false, "deny:10.0.0.0/8"},
+		{"http://[::1]/", false, "deny:::1"},
+		{"http://192.168.1.1", false, "allow:<none>"},
+	})
+}
+
+func TestCheckInvalid(t *testing.T) {
+	p := newTestPolicy(t, nil, nil)
+	for _, raw := range []string{"/relative", "http://[::1", ""} {
+		if _, err := p.Check(context.Background(), raw); err == nil {
+			t.Errorf("Check(%q) should fail", raw)
+		}
+	}
+	if d, _ := p.Check(context.Background(), "https://any.host"); !d.Allowed {
+		t.Error("empty policy should allow everything")
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	for _, rule := range []string{"re:(", "10.0.0.0/33"} {
+		if _, err := compile([]string{rule}); err == nil {
+			t.Errorf("compile(%q) should fail", rule)
+		}
+	}
+}
+
+// 启动时规则有误返回错误，不 panic
+func TestNewPolicyInvalidRule(t *testing.T) {
+	conf := config.New(viper.New())
+	conf.Set("log.log_level", "error")
+	conf.Set("log.log_file_name", filepath.Join(t.TempDir(), "server.log"))
+	conf.Set("policy.deny", []string{"re:("})
+	logger := log.NewLog(conf)
+	if p, err := NewPolicy(conf, logger, config.NewWatcher(conf, logger.Logger)); err == nil || p != nil {
+		t.Errorf("NewPolicy = %v, %v, want an error", p, err)
+	}
+}
+
+func TestUnresolvedHostDenied(t *testing.T) {
+	p := newTestPolicy(t, nil, []string{"10.0.0.0/8"})
+	// .invalid 保证无法解析
+	d, err := p.Check(context.Background(), "http://ogimg-test.invalid/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || !strings.Contains(d.Rule, "unresolved") {
+		t.Errorf("unresolved host should be denied, got %+v", d)
+	}
+
+	// 没有 CIDR 规则时不需要解析
+	p = newTestPolicy(t, nil, []string{"example.com"})
+	if d, _ := p.Check(context.Background(), "http://ogimg-test.invalid/"); !d.Allowed {
+		t.Errorf("host rules should not resolve, got %+v", d)
+	}
+}
+
+func newClient(p *Policy) *http.Client {
+	return &http.Client{Transport: p.Transport(), CheckRedirect: p.CheckRedirect}
+}
+
+func TestDialDenied(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	p := newTestPolicy(t, nil, []string{"127.0.0.0/8", "::1/128"})
+	// 用域名访问同样按解析后的 IP 检查
+	_, err := newClient(p).Get("http://localhost:" + u.Port())
+	if !errors.Is(err, apierr.Blocked) {
+		t.Fatalf("expected blocked error, got %v", err)
+	}
+	if got := apierr.Upstream(err); got.Reason != apierr.Blocked.Reason {
+		t.Errorf("Upstream reason = %s, want blocked", got.Reason)
+	}
+
+	p = newTestPolicy(t, nil, nil)
+	res, err := newClient(p).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+func TestRedirectDenied(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	u, _ := url.Parse(target.URL)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://localhost:"+u.Port()+"/internal", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	p := newTestPolicy(t, nil, []string{"localhost"})
+	_, err := newClient(p).Get(srv.URL)
+	var e *apierr.Error
+	if !errors.As(err, &e) || e.Reason != apierr.Blocked.Reason || !strings.Contains(e.Message, "deny:localhost") {
+		t.Fatalf("expected blocked redirect, got %v", err)
+	}
+}
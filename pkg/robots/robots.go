@@ -0,0 +1,170 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Robots 解析后的 robots.txt，规则匹配遵循 RFC 9309
+type Robots struct {
+	groups []group
+}
+
+type group struct {
+	agents []string
+	rules  []rule
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// AllowAll 返回一个不限制任何路径的 Robots（robots.txt 不存在时使用）
+func AllowAll() *Robots {
+	return &Robots{}
+}
+
+// DisallowAll 返回一个禁止所有路径的 Robots（robots.txt 无法访问时使用）
+func DisallowAll() *Robots {
+	return &Robots{groups: []group{{
+		agents: []string{"*"},
+		rules:  []rule{{allow: false, pattern: "/"}},
+	}}}
+}
+
+// Parse 解析 robots.txt 内容，无法识别的行会被忽略
+func Parse(r io.Reader) *Robots {
+	robots := &Robots{}
+	var current *group
+	// 连续的 user-agent 行属于同一个分组，出现规则后再遇到 user-agent 则开始新的分组
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := splitLine(line)
+		if !ok {
+			continue
+		}
+		switch key {
+		case "user-agent":
+			if current == nil || !lastWasAgent {
+				robots.groups = append(robots.groups, group{})
+				current = &robots.groups[len(robots.groups)-1]
+			}
+			if value == "*" {
+				current.agents = append(current.agents, value)
+			} else if token := productToken(value); token != "" {
+				current.agents = append(current.agents, token)
+			}
+			lastWasAgent = true
+		case "allow", "disallow":
+			lastWasAgent = false
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+		default:
+			lastWasAgent = false
+		}
+	}
+	return robots
+}
+
+// Allowed 判断 agent 是否可以访问 path（path 需包含 query）
+func (r *Robots) Allowed(agent, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	rules := r.rulesFor(productToken(agent))
+
+	// 最长匹配优先，长度相同时 allow 优先
+	matched, matchedLen := true, -1
+	for _, rl := range rules {
+		if !match(rl.pattern, path) {
+			continue
+		}
+		n := len(rl.pattern)
+		if n > matchedLen || (n == matchedLen && rl.allow) {
+			matched, matchedLen = rl.allow, n
+		}
+	}
+	return matched
+}
+
+// rulesFor 合并名称与 agent 相同的分组，没有时使用 * 分组
+func (r *Robots) rulesFor(agent string) []rule {
+	var rules, wildcard []rule
+	found := false
+	for _, g := range r.groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				wildcard = append(wildcard, g.rules...)
+				continue
+			}
+			if agent != "" && a == agent {
+				rules = append(rules, g.rules...)
+				found = true
+			}
+		}
+	}
+	if found {
+		return rules
+	}
+	return wildcard
+}
+
+// productToken 取 User-Agent 开头的产品名并转为小写，例如 "ogimg/1.0" 为 "ogimg"，
+// RFC 9309 要求按产品名不区分大小写匹配
+func productToken(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r == '_' || r == '-')
+	})
+	if end >= 0 {
+		s = s[:end]
+	}
+	return s
+}
+
+func splitLine(line string) (string, string, bool) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	key := strings.ToLower(strings.TrimSpace(line[:i]))
+	value := strings.TrimSpace(line[i+1:])
+	return key, value, key != ""
+}
+
+// match 支持 * 通配符和 $ 结尾锚定
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	if anchored {
+		return pos == len(path)
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package robots
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupSelection(t *testing.T) {
+	txt := `
+User-agent: *
+Disallow: /all
+
+User-agent: OGIMG
+Disallow: /ogimg
+
+User-agent: og
+Disallow: /og
+
+User-agent: Googlebot/2.1
+User-agent: bingbot
+Disallow: /search
+`
+	r := Parse(strings.NewReader(txt))
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		// 不区分大小写匹配产品名
+		{"ogimg", "/ogimg", false},
+		{"OgImg", "/ogimg", false},
+		{"ogimg/1.0", "/ogimg", false},
+		// 匹配到具体分组后不再使用 * 分组
+		{"ogimg", "/all", true},
+		// 名称是前缀的分组不匹配
+		{"ogimg", "/og", true},
+		{"og", "/og", false},
+		{"og", "/all", true},
+		// 分组名中的版本号被忽略，同一分组的多个 user-agent 共享规则
+		{"googlebot", "/search", false},
+		{"bingbot", "/search", false},
+		// 没有对应分组时使用 *
+		{"other", "/all", false},
+		{"other", "/ogimg", true},
+		{"", "/all", false},
+	}
+	for _, tt := range tests {
+		if got := r.Allowed(tt.agent, tt.path); got != tt.want {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", tt.agent, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyGroupName(t *testing.T) {
+	r := Parse(strings.NewReader("User-agent:\nDisallow: /\n"))
+	if !r.Allowed("ogimg", "/") {
+		t.Error("empty group name should not match any crawler")
+	}
+}
+
+func TestLongestMatch(t *testing.T) {
+	txt := `
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /private/public/secret
+Allow: /page
+Disallow: /page
+Disallow: /*.pdf$
+Disallow: /tmp*/cache
+Allow: /
+`
+	r := Parse(strings.NewReader(txt))
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"", true},
+		{"/index.html", true},
+		{"/private", false},
+		{"/private/x", false},
+		{"/private/public", true},
+		{"/private/public/page", true},
+		{"/private/public/secret/x", false},
+		// 长度相同时 allow 优先
+		{"/page", true},
+		{"/docs/a.pdf", false},
+		{"/docs/a.pdf?x=1", true},
+		{"/tmp1/cache", false},
+		{"/tmp/a/cache/x", false},
+		{"/tmp/a", true},
+	}
+	for _, tt := range tests {
+		if got := r.Allowed("ogimg", tt.path); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	txt := "# comment\nUser-Agent: ogimg # inline\nDISALLOW: /a\nunknown: x\nDisallow:\n\nUser-agent: other\nDisallow: /b\n"
+	r := Parse(strings.NewReader(txt))
+	if len(r.groups) != 2 {
+		t.Fatalf("groups = %d, want 2", len(r.groups))
+	}
+	if got := r.groups[0].agents; len(got) != 1 || got[0] != "ogimg" {
+		t.Errorf("agents = %v", got)
+	}
+	// 空的 Disallow 不产生规则
+	if got := len(r.groups[0].rules); got != 1 {
+		t.Errorf("rules = %d, want 1", got)
+	}
+	if r.Allowed("ogimg", "/a") || !r.Allowed("ogimg", "/b") {
+		t.Error("unexpected rules for ogimg")
+	}
+}
+
+func TestAllowAllDisallowAll(t *testing.T) {
+	if !AllowAll().Allowed("ogimg", "/x") {
+		t.Error("AllowAll disallowed /x")
+	}
+	if DisallowAll().Allowed("ogimg", "/x") {
+		t.Error("DisallowAll allowed /x")
+	}
+}
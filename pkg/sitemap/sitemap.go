@@ -0,0 +1,161 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	TypeList         = "list"
+	TypeUrlSet       = "urlset"
+	TypeSitemapIndex = "sitemapindex"
+	TypeRss          = "rss"
+	TypeAtom         = "atom"
+)
+
+// Document 解析结果，sitemap index 的子 sitemap 地址放在 Sitemaps 中，由调用方继续获取
+type Document struct {
+	Type     string
+	Urls     []string
+	Sitemaps []string
+}
+
+type locs []struct {
+	Loc string `xml:"loc"`
+}
+
+type urlSet struct {
+	Urls locs `xml:"url"`
+}
+
+type sitemapIndex struct {
+	Sitemaps locs `xml:"sitemap"`
+}
+
+type rss struct {
+	Items []struct {
+		Link string `xml:"link"`
+	} `xml:"channel>item"`
+}
+
+type atom struct {
+	Entries []struct {
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// Parse 根据根节点识别 sitemap.xml、sitemap index、RSS 或 Atom；
+// 内容不是 XML 时按每行一个 url 处理（忽略空行和 # 开头的行）
+func Parse(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return &Document{Type: TypeList, Urls: parseList(data)}, nil
+	}
+
+	root, err := rootName(data)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{Type: root}
+	switch root {
+	case TypeUrlSet:
+		var v urlSet
+		if err := decode(data, &v); err != nil {
+			return nil, err
+		}
+		doc.Urls = v.Urls.values()
+	case TypeSitemapIndex:
+		var v sitemapIndex
+		if err := decode(data, &v); err != nil {
+			return nil, err
+		}
+		doc.Sitemaps = v.Sitemaps.values()
+	case TypeRss:
+		var v rss
+		if err := decode(data, &v); err != nil {
+			return nil, err
+		}
+		for _, item := range v.Items {
+			doc.Urls = appendNonEmpty(doc.Urls, item.Link)
+		}
+	case "feed":
+		doc.Type = TypeAtom
+		var v atom
+		if err := decode(data, &v); err != nil {
+			return nil, err
+		}
+		for _, entry := range v.Entries {
+			// 优先使用 rel="alternate"（未写 rel 时默认即为 alternate）
+			for _, link := range entry.Links {
+				if link.Rel == "" || link.Rel == "alternate" {
+					doc.Urls = appendNonEmpty(doc.Urls, link.Href)
+					break
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported document <%s>", root)
+	}
+	return doc, nil
+}
+
+// decode 支持 encoding 声明不是 UTF-8 的文档
+func decode(data []byte, v interface{}) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder.Decode(v)
+}
+
+func rootName(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func (l locs) values() []string {
+	var urls []string
+	for _, u := range l {
+		urls = appendNonEmpty(urls, u.Loc)
+	}
+	return urls
+}
+
+func appendNonEmpty(urls []string, u string) []string {
+	if u = strings.TrimSpace(u); u != "" {
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+func parseList(data []byte) []string {
+	var urls []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls
+}
@@ -0,0 +1,88 @@
+package sitemap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		typ      string
+		urls     []string
+		sitemaps []string
+	}{
+		{
+			name: "urlset",
+			doc: `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/a </loc></url>
+  <url><loc></loc></url>
+  <url><loc>https://example.com/b</loc><lastmod>2024-01-01</lastmod></url>
+</urlset>`,
+			typ:  TypeUrlSet,
+			urls: []string{"https://example.com/a", "https://example.com/b"},
+		},
+		{
+			name: "sitemap index",
+			doc: `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/s1.xml</loc></sitemap>
+  <sitemap><loc>https://example.com/s2.xml</loc></sitemap>
+</sitemapindex>`,
+			typ:      TypeSitemapIndex,
+			sitemaps: []string{"https://example.com/s1.xml", "https://example.com/s2.xml"},
+		},
+		{
+			name: "rss",
+			doc: `<rss version="2.0"><channel><title>t</title>
+  <item><link>https://example.com/post/1</link></item>
+  <item><title>no link</title></item>
+  <item><link>https://example.com/post/2</link></item>
+</channel></rss>`,
+			typ:  TypeRss,
+			urls: []string{"https://example.com/post/1", "https://example.com/post/2"},
+		},
+		{
+			name: "atom",
+			doc: `<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry><link rel="self" href="https://example.com/self"/><link rel="alternate" href="https://example.com/1"/></entry>
+  <entry><link href="https://example.com/2"/></entry>
+  <entry><link rel="edit" href="https://example.com/edit"/></entry>
+</feed>`,
+			typ:  TypeAtom,
+			urls: []string{"https://example.com/1", "https://example.com/2"},
+		},
+		{
+			name: "list",
+			doc:  "# comment\nhttps://example.com/a\n\n  https://example.com/b  \n",
+			typ:  TypeList,
+			urls: []string{"https://example.com/a", "https://example.com/b"},
+		},
+		{
+			name: "non utf-8",
+			doc:  "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<urlset><url><loc>https://example.com/caf\xe9</loc></url></urlset>",
+			typ:  TypeUrlSet,
+			urls: []string{"https://example.com/café"},
+		},
+	}
+	for _, tt := range tests {
+		doc, err := Parse(strings.NewReader(tt.doc))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if doc.Type != tt.typ || !reflect.DeepEqual(doc.Urls, tt.urls) || !reflect.DeepEqual(doc.Sitemaps, tt.sitemaps) {
+			t.Errorf("%s: got %+v", tt.name, doc)
+		}
+	}
+}
+
+func TestParseUnsupported(t *testing.T) {
+	for _, doc := range []string{`<html><body></body></html>`, `<urlset><url>`} {
+		if _, err := Parse(strings.NewReader(doc)); err == nil {
+			t.Errorf("Parse(%q) should fail", doc)
+		}
+	}
+}
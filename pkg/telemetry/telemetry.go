@@ -0,0 +1,92 @@
+// Package telemetry 初始化 OpenTelemetry 链路追踪，未开启时使用 otel 默认的 noop 实现
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ogimg/pkg/config"
+	"ogimg/pkg/version"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterOtlp   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+
+	tracerName = "ogimg"
+)
+
+// Tracer 返回全局 TracerProvider 上的 tracer，Setup 之前调用也可以，span 会在 Setup 后生效
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Setup 按 telemetry 配置设置全局 TracerProvider 和 W3C traceparent 传播，
+// 返回的 shutdown 会导出尚未发送的 span，退出前需要调用
+func Setup(conf *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !conf.GetBool("telemetry.enabled") {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closer, err := newExporter(conf)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(context.Background(), resource.WithAttributes(
+		attribute.String("service.name", conf.GetString("telemetry.service_name")),
+		attribute.String("service.version", version.Get().Version),
+	))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.GetFloat64("telemetry.sample_ratio")))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+func newExporter(conf *config.Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch exporter := conf.GetString("telemetry.exporter"); exporter {
+	case ExporterOtlp:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(conf.GetString("telemetry.otlp.endpoint"))}
+		if conf.GetBool("telemetry.otlp.insecure") {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		e, err := otlptracehttp.New(context.Background(), opts...)
+		return e, nil, err
+	case ExporterStdout:
+		e, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return e, nil, err
+	case ExporterFile:
+		f, err := os.OpenFile(conf.GetString("telemetry.file"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		return e, f, err
+	default:
+		return nil, nil, fmt.Errorf("unknown telemetry.exporter %q", exporter)
+	}
+}
@@ -0,0 +1,154 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ogimg/pkg/config"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return recorder
+}
+
+func spanNamed(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTransport(t *testing.T) {
+	recorder := newRecorder(t)
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	client := &http.Client{Transport: Transport(nil, true)}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/page?token=secret", nil)
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	parent.End()
+
+	spans := recorder.Ended()
+	fetch := spanNamed(spans, "HTTP GET")
+	if fetch == nil {
+		t.Fatalf("no fetch span in %d spans", len(spans))
+	}
+	if fetch.Parent().SpanID() != parent.SpanContext().SpanID() || fetch.SpanKind() != trace.SpanKindClient {
+		t.Errorf("fetch span parent = %v, kind = %v", fetch.Parent().SpanID(), fetch.SpanKind())
+	}
+	if got := attr(fetch, "http.response.status_code").AsInt64(); got != http.StatusOK {
+		t.Errorf("status attribute = %d", got)
+	}
+	// 查询参数可能带有凭据，不写入 span
+	if got := attr(fetch, "url.full").AsString(); got != srv.URL+"/page" {
+		t.Errorf("url.full = %q", got)
+	}
+	if connect := spanNamed(spans, "connect"); connect == nil || connect.Parent().SpanID() != fetch.SpanContext().SpanID() {
+		t.Errorf("connect span = %v", connect)
+	}
+	if !strings.Contains(traceparent, fetch.SpanContext().TraceID().String()) || !strings.Contains(traceparent, fetch.SpanContext().SpanID().String()) {
+		t.Errorf("traceparent = %q, want the fetch span", traceparent)
+	}
+
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/missing", nil)
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if spans := recorder.Ended(); spans[len(spans)-1].Status().Code != codes.Error {
+		t.Errorf("404 span status = %v", spans[len(spans)-1].Status())
+	}
+}
+
+func TestTransportWithoutPropagation(t *testing.T) {
+	newRecorder(t)
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	ctx, span := Tracer().Start(context.Background(), "request")
+	defer span.End()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	res, err := (&http.Client{Transport: Transport(nil, false)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if traceparent != "" {
+		t.Errorf("traceparent sent upstream: %q", traceparent)
+	}
+}
+
+func TestSetupFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.json")
+	conf := config.New(viper.New())
+	conf.Set("telemetry.enabled", true)
+	conf.Set("telemetry.exporter", ExporterFile)
+	conf.Set("telemetry.file", file)
+	conf.Set("telemetry.service_name", "ogimg-test")
+	conf.Set("telemetry.sample_ratio", 1)
+
+	shutdown, err := Setup(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := Tracer().Start(context.Background(), "exported")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || !strings.Contains(string(data), `"exported"`) || !strings.Contains(string(data), "ogimg-test") {
+		t.Errorf("trace file = %s, %v", data, err)
+	}
+
+	conf.Set("telemetry.exporter", "zipkin")
+	if _, err := Setup(conf); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
@@ -0,0 +1,117 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport 为每个上游请求创建 client span，DNS、建连和 TLS 握手作为子 span，
+// propagate 为 true 时向上游注入 traceparent。next 为 nil 时使用 http.DefaultTransport
+func Transport(next http.RoundTripper, propagate bool) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next, propagate: propagate}
+}
+
+type transport struct {
+	next      http.RoundTripper
+	propagate bool
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.full", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path),
+		),
+	)
+	defer span.End()
+
+	ctx = httptrace.WithClientTrace(ctx, clientTrace(ctx))
+	req = req.Clone(ctx)
+	if t.propagate {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, res.Status)
+	}
+	return res, nil
+}
+
+// clientTrace 把 httptrace 的回调转换为子 span，并发建连时按地址区分
+func clientTrace(ctx context.Context) *httptrace.ClientTrace {
+	var (
+		mu       sync.Mutex
+		dns      trace.Span
+		tlsSpan  trace.Span
+		connects = map[string]trace.Span{}
+	)
+	end := func(span trace.Span, err error) {
+		if span == nil {
+			return
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			_, dns = Tracer().Start(ctx, "dns", trace.WithAttributes(attribute.String("net.host.name", info.Host)))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			end(dns, info.Err)
+			dns = nil
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			defer mu.Unlock()
+			_, connects[network+addr] = Tracer().Start(ctx, "connect", trace.WithAttributes(attribute.String("net.peer.address", addr)))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			end(connects[network+addr], err)
+			delete(connects, network+addr)
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			_, tlsSpan = Tracer().Start(ctx, "tls")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			end(tlsSpan, err)
+			tlsSpan = nil
+		},
+		GotFirstResponseByte: func() {
+			trace.SpanFromContext(ctx).AddEvent("first response byte")
+		},
+	}
+}
@@ -0,0 +1,94 @@
+// Package webhook 对 webhook 请求体进行签名和校验，接收方可以直接使用 Verify
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Ogimg-Signature"
+	EventHeader     = "X-Ogimg-Event"
+	DeliveryHeader  = "X-Ogimg-Delivery"
+)
+
+var (
+	ErrInvalidHeader    = errors.New("webhook: invalid signature header")
+	ErrInvalidSignature = errors.New("webhook: signature mismatch")
+	ErrExpired          = errors.New("webhook: timestamp outside tolerance")
+)
+
+// NewSecret 生成 32 字节的随机签名密钥
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign 返回 SignatureHeader 的值，格式为 t=<unix 秒>,v1=<hex(HMAC-SHA256(secret, "<t>.<body>"))>
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, signature(secret, ts, body))
+}
+
+// Verify 校验签名，tolerance 大于 0 时拒绝时间戳与当前时间相差超过 tolerance 的请求以防重放
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrInvalidHeader
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidHeader
+	}
+	if tolerance > 0 {
+		if d := time.Since(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+			return ErrExpired
+		}
+	}
+
+	expected := signature(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// RequestPayload 请求签名时参与签名的内容：<method> <path?query>\n<body>
+func RequestPayload(method, requestURI string, body []byte) []byte {
+	payload := make([]byte, 0, len(method)+len(requestURI)+len(body)+2)
+	payload = append(payload, method...)
+	payload = append(payload, ' ')
+	payload = append(payload, requestURI...)
+	payload = append(payload, '\n')
+	return append(payload, body...)
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
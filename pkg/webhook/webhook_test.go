@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"event":"job.completed"}`)
+	now := time.Now()
+	header := Sign("secret", now, body)
+	if !strings.HasPrefix(header, "t=") || !strings.Contains(header, ",v1=") {
+		t.Fatalf("header = %q", header)
+	}
+	if err := Verify("secret", header, body, time.Minute); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		body      string
+		tolerance time.Duration
+		want      error
+	}{
+		{"wrong secret", "other", header, string(body), time.Minute, ErrInvalidSignature},
+		{"tampered body", "secret", header, `{"event":"x"}`, time.Minute, ErrInvalidSignature},
+		{"expired", "secret", Sign("secret", now.Add(-time.Hour), body), string(body), time.Minute, ErrExpired},
+		{"future", "secret", Sign("secret", now.Add(time.Hour), body), string(body), time.Minute, ErrExpired},
+		{"no tolerance", "secret", Sign("secret", now.Add(-time.Hour), body), string(body), 0, nil},
+		{"missing signature", "secret", "t=123", string(body), 0, ErrInvalidHeader},
+		{"bad timestamp", "secret", "t=abc,v1=00", string(body), 0, ErrInvalidHeader},
+		{"malformed", "secret", "garbage", string(body), 0, ErrInvalidHeader},
+		// 轮换密钥时可以同时携带多个签名
+		{"multiple signatures", "secret", header + ",v1=deadbeef", string(body), time.Minute, nil},
+	}
+	for _, tt := range tests {
+		if err := Verify(tt.secret, tt.header, []byte(tt.body), tt.tolerance); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRequestPayload(t *testing.T) {
+	got := string(RequestPayload("POST", "/v1/desc/batch?x=1", []byte(`["a"]`)))
+	if got != "POST /v1/desc/batch?x=1\n[\"a\"]" {
+		t.Errorf("RequestPayload = %q", got)
+	}
+	if got := string(RequestPayload("GET", "/v1/desc", nil)); got != "GET /v1/desc\n" {
+		t.Errorf("RequestPayload without body = %q", got)
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSecret()
+	if !strings.HasPrefix(a, "whsec_") || len(a) != len("whsec_")+64 || a == b {
+		t.Errorf("secrets %q, %q", a, b)
+	}
+}